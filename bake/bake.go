@@ -3,6 +3,7 @@ package bake
 import (
 	"context"
 	"io"
+	"maps"
 	"os"
 	"path"
 	"path/filepath"
@@ -21,8 +22,10 @@ import (
 	"github.com/docker/buildx/util/platformutil"
 	"github.com/docker/buildx/util/progress"
 	"github.com/docker/cli/cli/config"
+	"github.com/docker/cli/cli/config/configfile"
 	dockeropts "github.com/docker/cli/opts"
 	hcl "github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
 	"github.com/moby/buildkit/client"
 	"github.com/moby/buildkit/client/llb"
 	"github.com/moby/buildkit/session/auth/authprovider"
@@ -41,11 +44,146 @@ var (
 type File struct {
 	Name string
 	Data []byte
+
+	// source is the remote source the file is imported from
+	source string
+	// vars is set for the variable files read with ReadVarFiles
+	vars bool
+	// remote is the URL of the remote definition the file is read from
+	remote string
 }
 
 type Override struct {
 	Value    string
 	ArrValue []string
+	// Append, Prepend and Remove are the values of the +=, ^= and -=
+	// operators, applied in this order after ArrValue.
+	Append  []string
+	Prepend []string
+	Remove  []string
+
+	// specs are the overrides setting the value, for --explain
+	specs []string
+}
+
+// listAttributes are the list attributes of a target that can be
+// overridden, with the key of their override.
+var listAttributes = map[string]struct{}{
+	"annotations":     {},
+	"attest":          {},
+	"cache-from":      {},
+	"cache-to":        {},
+	"entitlements":    {},
+	"no-cache-filter": {},
+	"output":          {},
+	"platform":        {},
+	"registry-auth":   {},
+	"secrets":         {},
+	"ssh":             {},
+	"tags":            {},
+	"ulimits":         {},
+}
+
+// mapAttributes are the map attributes of a target that can be overridden
+// by key.
+var mapAttributes = map[string]struct{}{
+	"args":     {},
+	"contexts": {},
+	"hooks":    {},
+	"labels":   {},
+}
+
+// cutOverrideOp returns the key of an override without the trailing
+// operator, and the operator.
+func cutOverrideOp(key string) (string, string) {
+	for _, op := range []string{"+", "^", "-"} {
+		if k, ok := strings.CutSuffix(key, op); ok {
+			return k, op
+		}
+	}
+	return key, ""
+}
+
+// apply returns the list l overridden. The values of the override are
+// appended to l instead of replacing it if appendValues is true, unless
+// they reset the list with an empty value.
+func (o Override) apply(l []string, appendValues bool) []string {
+	switch {
+	case o.ArrValue != nil && len(o.ArrValue) == 0:
+		l = nil
+	case o.ArrValue != nil && appendValues:
+		l = append(slices.Clone(l), o.ArrValue...)
+	case o.ArrValue != nil:
+		l = slices.Clone(o.ArrValue)
+	default:
+		l = slices.Clone(l)
+	}
+	l = append(slices.Clone(o.Prepend), l...)
+	l = append(l, o.Append...)
+	for _, pattern := range o.Remove {
+		l = slices.DeleteFunc(l, func(v string) bool {
+			return matchOverrideValue(v, pattern)
+		})
+	}
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// values returns the values added by the override.
+func (o Override) values() []string {
+	return slices.Concat(o.ArrValue, o.Prepend, o.Append)
+}
+
+// removeKeys removes the keys of m matching the values removed by the
+// override.
+func removeKeys[V any](m map[string]V, o Override) {
+	for k := range m {
+		if slices.ContainsFunc(o.Remove, func(pattern string) bool {
+			return matchOverrideValue(k, pattern)
+		}) {
+			delete(m, k)
+		}
+	}
+}
+
+// matchOverrideValue reports whether the value v matches a pattern removed
+// with the -= operator. The pattern is either a glob, or the comma-separated
+// fields to match for structured values such as outputs and attestations,
+// e.g. type=sbom.
+func matchOverrideValue(v, pattern string) bool {
+	if ok, _ := path.Match(pattern, v); ok || v == pattern {
+		return true
+	}
+	if !strings.Contains(pattern, "=") {
+		return false
+	}
+	pfields, err := csvvalue.Fields(pattern, nil)
+	if err != nil {
+		return false
+	}
+	vfields, err := csvvalue.Fields(v, nil)
+	if err != nil {
+		return false
+	}
+	for _, pf := range pfields {
+		pk, pv, ok := strings.Cut(pf, "=")
+		if !ok {
+			return false
+		}
+		if !slices.ContainsFunc(vfields, func(vf string) bool {
+			k, v, ok := strings.Cut(vf, "=")
+			if !ok || k != pk {
+				return false
+			}
+			m, _ := path.Match(pv, v)
+			return m || v == pv
+		}) {
+			return false
+		}
+	}
+	return true
 }
 
 func defaultFilenames() []string {
@@ -193,19 +331,46 @@ func ListTargets(files []File) ([]string, error) {
 	return dedupSlice(targets), nil
 }
 
+// Definition is the definition of the targets to build read from the Bake
+// files.
+type Definition struct {
+	Targets map[string]*Target
+	Groups  map[string]*Group
+	// Tests are the tests of the enabled targets, sorted by name.
+	Tests []*Test
+	// Origins are the origins of the values of the attributes of the
+	// targets, by target and attribute.
+	Origins map[string]map[string][]Origin
+}
+
 func ReadTargets(ctx context.Context, files []File, targets, overrides []string, defaults map[string]string, ent *EntitlementConf) (map[string]*Target, map[string]*Group, error) {
-	c, _, err := ParseFiles(files, defaults)
+	def, err := ReadDefinition(ctx, files, targets, overrides, defaults, ent)
 	if err != nil {
 		return nil, nil, err
 	}
+	return def.Targets, def.Groups, nil
+}
+
+// ReadDefinition is like ReadTargets, and also returns the tests of the
+// targets.
+func ReadDefinition(ctx context.Context, files []File, targets, overrides []string, defaults map[string]string, ent *EntitlementConf) (*Definition, error) {
+	c, pm, err := ParseFiles(files, defaults)
+	if err != nil {
+		return nil, err
+	}
 
 	for i, t := range targets {
+		// imported targets and groups are namespaced with a dot
+		if slices.ContainsFunc(c.Targets, func(target *Target) bool { return target.Name == t }) ||
+			slices.ContainsFunc(c.Groups, func(group *Group) bool { return group.Name == t }) {
+			continue
+		}
 		targets[i] = sanitizeTargetName(t)
 	}
 
 	o, err := c.newOverrides(overrides)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	m := map[string]*Target{}
 	n := map[string]*Group{}
@@ -214,9 +379,12 @@ func ReadTargets(ctx context.Context, files []File, targets, overrides []string,
 		for _, tname := range ts {
 			t, err := c.ResolveTarget(tname, o, ent)
 			if err != nil {
-				return nil, nil, err
+				return nil, err
 			}
 			if t != nil {
+				if err := t.validateHooks(); err != nil {
+					return nil, err
+				}
 				m[tname] = t
 			}
 		}
@@ -244,12 +412,49 @@ func ReadTargets(ctx context.Context, files []File, targets, overrides []string,
 	}
 
 	for name, t := range m {
+		if !t.IsEnabled() {
+			continue
+		}
 		if err := c.loadLinks(name, t, m, o, nil, ent); err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 	}
 
-	return m, n, nil
+	if len(c.Auths) > 0 {
+		auths := make([]string, 0, len(c.Auths))
+		for _, a := range c.Auths {
+			auths = append(auths, a.spec())
+		}
+		var authOrigins []Origin
+		for _, a := range c.Auths {
+			for _, def := range pm.Definitions["auth"][a.Name] {
+				authOrigins = append(authOrigins, Origin{File: def.Range.Filename, Line: def.Range.Start.Line})
+			}
+		}
+		for _, t := range m {
+			// credentials set on the target override the auth blocks
+			t.RegistryAuth = append(slices.Clone(auths), t.RegistryAuth...)
+			if t.origins == nil {
+				t.origins = origins{}
+			}
+			t.origins["registry-auth"] = append(slices.Clone(authOrigins), t.origins["registry-auth"]...)
+		}
+	}
+
+	tests, err := c.readTests(m)
+	if err != nil {
+		return nil, err
+	}
+
+	explain := make(map[string]map[string][]Origin, len(m))
+	for name, t := range m {
+		explain[name] = maps.Clone(t.origins)
+		if explain[name] == nil {
+			explain[name] = map[string][]Origin{}
+		}
+	}
+
+	return &Definition{Targets: m, Groups: n, Tests: tests, Origins: explain}, nil
 }
 
 func dedupSlice(s []string) []string {
@@ -301,6 +506,12 @@ func sliceToMap(env []string) (res map[string]string) {
 }
 
 func ParseFiles(files []File, defaults map[string]string) (_ *Config, _ *hclparser.ParseMeta, err error) {
+	return parseFiles(files, defaults, "", nil)
+}
+
+// parseFiles parses the files of the remote source, or the local files if
+// source is empty. imported is the chain of sources being imported.
+func parseFiles(files []File, defaults map[string]string, source string, imported []string) (_ *Config, _ *hclparser.ParseMeta, err error) {
 	defer func() {
 		err = formatHCLError(err, files)
 	}()
@@ -308,7 +519,14 @@ func ParseFiles(files []File, defaults map[string]string) (_ *Config, _ *hclpars
 	var c Config
 	var composeFiles []File
 	var hclFiles []*hcl.File
+	// git functions read the repository of the first HCL file, and are not
+	// supported for remote files
+	var workingDir string
+	remoteSource := source
 	for _, f := range files {
+		if f.source != source || f.vars {
+			continue
+		}
 		isCompose, composeErr := validateComposeFile(f.Data, f.Name)
 		if isCompose {
 			if composeErr != nil {
@@ -323,6 +541,12 @@ func ParseFiles(files []File, defaults map[string]string) (_ *Config, _ *hclpars
 					return nil, nil, err
 				}
 				hclFiles = append(hclFiles, hf)
+				if remoteSource == "" {
+					remoteSource = f.remote
+				}
+				if workingDir == "" {
+					workingDir = filepath.Dir(f.Name)
+				}
 			} else if composeErr != nil {
 				return nil, nil, errors.Wrapf(err, "failed to parse %s: parsing yaml: %v, parsing hcl", f.Name, composeErr)
 			} else {
@@ -336,21 +560,67 @@ func ParseFiles(files []File, defaults map[string]string) (_ *Config, _ *hclpars
 		if cmperr != nil {
 			return nil, nil, errors.Wrap(cmperr, "failed to parse compose file")
 		}
+		names := make([]string, 0, len(composeFiles))
+		for _, f := range composeFiles {
+			names = append(names, f.Name)
+		}
+		for _, t := range cfg.Targets {
+			t.origins = origins{}
+			for _, attr := range targetAttributes(t) {
+				t.origins.add(attr, Origin{File: strings.Join(names, ", ")})
+			}
+		}
 		c = mergeConfig(c, *cfg)
 		c = dedupeConfig(c)
 	}
 
 	var pm hclparser.ParseMeta
+	// imports referenced by the files are parsed with their namespace
+	imports := map[string]*Config{}
 	if len(hclFiles) > 0 {
+		values, verr := varValues(files)
+		if verr != nil {
+			return nil, nil, verr
+		}
 		res, err := hclparser.Parse(hclparser.MergeFiles(hclFiles), hclparser.Opt{
-			LookupVar:     os.LookupEnv,
-			Vars:          defaults,
-			ValidateLabel: validateTargetName,
+			LookupVar:      os.LookupEnv,
+			Vars:           defaults,
+			ValidateLabel:  validateTargetName,
+			WorkingDir:     workingDir,
+			RemoteSource:   remoteSource,
+			VarValues:      values,
+			NamespaceBlock: "import",
+			LoadNamespace: func(block *hcl.Block, ectx *hcl.EvalContext) (*hclparser.Namespace, error) {
+				imp := &Import{Name: block.Labels[0]}
+				if diags := gohcl.DecodeBody(block.Body, ectx, imp); diags.HasErrors() {
+					return nil, diags
+				}
+				ic, ipm, err := parseImport(imp, files, source, block.DefRange.Filename, defaults, imported)
+				if err != nil {
+					return nil, err
+				}
+				imports[imp.Name] = ic
+				return &ipm.Namespace, nil
+			},
 		}, &c)
 		if err.HasErrors() {
 			return nil, nil, err
 		}
 
+		varSources := map[string]string{}
+		for _, v := range res.AllVariables {
+			varSources[v.Name] = v.Source
+		}
+		// the definitions are merged into the targets of the compose files
+		for _, t := range c.Targets {
+			if defs := res.Definitions["target"][t.Name]; len(defs) > 0 {
+				if t.origins == nil {
+					t.origins = origins{}
+				}
+				t.origins.merge(definitionOrigins(defs, varSources))
+			}
+		}
+
 		for _, renamed := range res.Renamed {
 			for oldName, newNames := range renamed {
 				newNames = dedupSlice(newNames)
@@ -367,6 +637,21 @@ func ParseFiles(files []File, defaults map[string]string) (_ *Config, _ *hclpars
 		pm = *res
 	}
 
+	if len(c.Imports) > 0 {
+		defs := map[string]string{}
+		for name, v := range pm.Definitions["import"] {
+			defs[name] = v[len(v)-1].Range.Filename
+		}
+		if err := c.loadImports(files, source, defs, defaults, imported, imports); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// the groups of compose profiles don't shadow the targets of other files
+	c.Groups = slices.DeleteFunc(c.Groups, func(g *Group) bool {
+		return g.profile && slices.ContainsFunc(c.Targets, func(t *Target) bool { return t.Name == g.Name })
+	})
+
 	return &c, &pm, nil
 }
 
@@ -399,6 +684,9 @@ func ParseFile(dt []byte, fn string) (*Config, error) {
 type Config struct {
 	Groups  []*Group  `json:"group" hcl:"group,block" cty:"group"`
 	Targets []*Target `json:"target" hcl:"target,block" cty:"target"`
+	Auths   []*Auth   `json:"auth,omitempty" hcl:"auth,block" cty:"auth"`
+	Tests   []*Test   `json:"test,omitempty" hcl:"test,block" cty:"test"`
+	Imports []*Import `json:"-" hcl:"import,block" cty:"import"`
 }
 
 func mergeConfig(c1, c2 Config) Config {
@@ -500,6 +788,9 @@ func (c Config) loadLinks(name string, t *Target, m map[string]*Target, o map[st
 				t2.linked = true
 				m[target] = t2
 			}
+			if !t2.IsEnabled() {
+				return errors.Errorf("target %s can't be used by %s because it is disabled", target, name)
+			}
 			if err := c.loadLinks(target, t2, m, o, visited, ent); err != nil {
 				return err
 			}
@@ -525,22 +816,41 @@ func (c Config) newOverrides(v []string) (map[string]map[string]Override, error)
 	m := map[string]map[string]Override{}
 	for _, v := range v {
 		parts := strings.SplitN(v, "=", 2)
-		keys := strings.SplitN(parts[0], ".", 3)
-		if len(keys) < 2 {
+		var op string
+		if len(parts) == 2 {
+			parts[0], op = cutOverrideOp(parts[0])
+		}
+		pattern, key, ok := c.splitOverrideKey(parts[0])
+		if !ok {
 			return nil, errors.Errorf("invalid override key %s, expected target.name", parts[0])
 		}
+		keys := append([]string{pattern}, strings.SplitN(key, ".", 2)...)
+		if keys[1] == "platforms" {
+			// platforms is the name of the attribute in the Bake file
+			keys[1], key = "platform", "platform"
+		}
 
-		pattern := keys[0]
 		if len(parts) != 2 && keys[1] != "args" {
 			return nil, errors.Errorf("invalid override %s, expected target.name=value", v)
 		}
+		if op != "" {
+			_, isList := listAttributes[keys[1]]
+			_, isMap := mapAttributes[keys[1]]
+			switch {
+			case isList && len(keys) == 2:
+			case keys[1] == "hooks" && len(keys) == 3:
+			case isMap && len(keys) == 2 && op == "-":
+			default:
+				return nil, errors.Errorf("invalid override %s, operator %s= is not supported for %s", v, op, key)
+			}
+		}
 
 		names, err := c.expandTargets(pattern)
 		if err != nil {
 			return nil, err
 		}
 
-		kk := strings.SplitN(parts[0], ".", 2)
+		kk := []string{pattern, key}
 
 		for _, name := range names {
 			t, ok := m[name]
@@ -550,12 +860,36 @@ func (c Config) newOverrides(v []string) (map[string]map[string]Override, error)
 			}
 
 			o := t[kk[1]]
+			o.specs = append(o.specs, v)
+
+			switch op {
+			case "+":
+				o.Append = append(o.Append, parts[1])
+			case "^":
+				o.Prepend = append(o.Prepend, parts[1])
+			case "-":
+				o.Remove = append(o.Remove, parts[1])
+			}
+			if op != "" {
+				t[kk[1]] = o
+				continue
+			}
 
-			switch keys[1] {
-			case "output", "cache-to", "cache-from", "tags", "platform", "secrets", "ssh", "attest", "entitlements", "network":
-				if len(parts) == 2 {
+			if _, ok := listAttributes[keys[1]]; ok || keys[1] == "hooks" {
+				if keys[1] == "hooks" && len(keys) != 3 {
+					return nil, errors.Errorf("invalid key %s, hooks requires name", parts[0])
+				}
+				if parts[1] == "" {
+					// an empty value resets the list
+					o.ArrValue = []string{}
+				} else {
 					o.ArrValue = append(o.ArrValue, parts[1])
 				}
+				t[kk[1]] = o
+				continue
+			}
+
+			switch keys[1] {
 			case "args":
 				if len(keys) != 3 {
 					return nil, errors.Errorf("invalid key %s, args requires name", parts[0])
@@ -585,7 +919,26 @@ func (c Config) newOverrides(v []string) (map[string]map[string]Override, error)
 	return m, nil
 }
 
+// splitOverrideKey splits the key of an override into the target pattern
+// and the attribute key. The names of imported targets contain dots, so the
+// longest imported target name matching the key takes precedence.
+func (c Config) splitOverrideKey(k string) (string, string, bool) {
+	var pattern string
+	for _, t := range c.Targets {
+		if strings.Contains(t.Name, ".") && strings.HasPrefix(k, t.Name+".") && len(t.Name) > len(pattern) {
+			pattern = t.Name
+		}
+	}
+	if pattern != "" {
+		return pattern, strings.TrimPrefix(k, pattern+"."), true
+	}
+	return strings.Cut(k, ".")
+}
+
 func (c Config) ResolveGroup(name string) ([]string, []string) {
+	if c.groupDisabled(name) {
+		return nil, []string{name}
+	}
 	targets, groups := c.group(name, map[string]visit{})
 	return dedupSlice(targets), dedupSlice(groups)
 }
@@ -613,6 +966,9 @@ func (c Config) group(name string, visited map[string]visit) ([]string, []string
 	targets := make([]string, 0, len(g.Targets))
 	groups := []string{name}
 	for _, t := range g.Targets {
+		if c.groupDisabled(t) {
+			continue
+		}
 		ttarget, tgroup := c.group(t, visited)
 		if len(ttarget) > 0 {
 			targets = append(targets, ttarget...)
@@ -627,6 +983,17 @@ func (c Config) group(name string, visited map[string]visit) ([]string, []string
 	return targets, groups
 }
 
+// groupDisabled returns true if name is a group disabled with the enabled
+// attribute.
+func (c Config) groupDisabled(name string) bool {
+	for _, g := range c.Groups {
+		if g.Name == name {
+			return g.Enabled != nil && !*g.Enabled
+		}
+	}
+	return false
+}
+
 func (c Config) ResolveTarget(name string, overrides map[string]map[string]Override, ent *EntitlementConf) (*Target, error) {
 	t, err := c.target(name, map[string]*Target{}, overrides, ent)
 	if err != nil {
@@ -666,13 +1033,16 @@ func (c Config) target(name string, visited map[string]*Target, overrides map[st
 			return nil, err
 		}
 		if t != nil {
-			tt.Merge(t)
+			inherited := *t
+			inherited.origins = t.origins.inherited(name)
+			tt.Merge(&inherited)
 		}
 	}
 	m := defaultTarget()
 	m.Merge(tt)
 	m.Merge(t)
 	tt = m
+	tt.Enabled = t.Enabled
 	if err := tt.AddOverrides(overrides[name], ent); err != nil {
 		return nil, err
 	}
@@ -684,45 +1054,95 @@ func (c Config) target(name string, visited map[string]*Target, overrides map[st
 type Group struct {
 	Name        string   `json:"-" hcl:"name,label" cty:"name"`
 	Description string   `json:"description,omitempty" hcl:"description,optional" cty:"description"`
+	Enabled     *bool    `json:"enabled,omitempty" hcl:"enabled,optional" cty:"enabled"`
 	Targets     []string `json:"targets" hcl:"targets" cty:"targets"`
 	// Target // TODO?
+
+	// profile is set for the groups of the profiles of compose files
+	profile bool
+}
+
+// Auth supplies the credentials of a registry to all the targets of a build.
+type Auth struct {
+	Name     string  `json:"-" hcl:"name,label" cty:"name"`
+	Host     *string `json:"host,omitempty" hcl:"host,optional" cty:"host"`
+	Username *string `json:"username,omitempty" hcl:"username,optional" cty:"username"`
+	Cmd      *string `json:"cmd,omitempty" hcl:"cmd,optional" cty:"cmd"`
+	Env      *string `json:"env,omitempty" hcl:"env,optional" cty:"env"`
+	Src      *string `json:"src,omitempty" hcl:"src,optional" cty:"src"`
+}
+
+// spec returns the auth in the format of the registry-auth attribute of a
+// target.
+func (a *Auth) spec() string {
+	host := a.Name
+	if a.Host != nil {
+		host = *a.Host
+	}
+	fields := []string{"host=" + host}
+	for _, f := range []struct {
+		key   string
+		value *string
+	}{
+		{"username", a.Username},
+		{"cmd", a.Cmd},
+		{"env", a.Env},
+		{"src", a.Src},
+	} {
+		if f.value != nil {
+			fields = append(fields, f.key+"="+*f.value)
+		}
+	}
+	for i, f := range fields {
+		if strings.ContainsAny(f, `,"`) {
+			fields[i] = `"` + strings.ReplaceAll(f, `"`, `""`) + `"`
+		}
+	}
+	return strings.Join(fields, ",")
 }
 
 type Target struct {
 	Name        string `json:"-" hcl:"name,label" cty:"name"`
 	Description string `json:"description,omitempty" hcl:"description,optional" cty:"description"`
+	// Enabled is not inherited, a disabled target is not built
+	Enabled *bool `json:"enabled,omitempty" hcl:"enabled,optional" cty:"enabled"`
 
 	// Inherits is the only field that cannot be overridden with --set
 	Inherits []string `json:"inherits,omitempty" hcl:"inherits,optional" cty:"inherits"`
 
-	Annotations      []string           `json:"annotations,omitempty" hcl:"annotations,optional" cty:"annotations"`
-	Attest           []string           `json:"attest,omitempty" hcl:"attest,optional" cty:"attest"`
-	Context          *string            `json:"context,omitempty" hcl:"context,optional" cty:"context"`
-	Contexts         map[string]string  `json:"contexts,omitempty" hcl:"contexts,optional" cty:"contexts"`
-	Dockerfile       *string            `json:"dockerfile,omitempty" hcl:"dockerfile,optional" cty:"dockerfile"`
-	DockerfileInline *string            `json:"dockerfile-inline,omitempty" hcl:"dockerfile-inline,optional" cty:"dockerfile-inline"`
-	Args             map[string]*string `json:"args,omitempty" hcl:"args,optional" cty:"args"`
-	Labels           map[string]*string `json:"labels,omitempty" hcl:"labels,optional" cty:"labels"`
-	Tags             []string           `json:"tags,omitempty" hcl:"tags,optional" cty:"tags"`
-	CacheFrom        []string           `json:"cache-from,omitempty"  hcl:"cache-from,optional" cty:"cache-from"`
-	CacheTo          []string           `json:"cache-to,omitempty"  hcl:"cache-to,optional" cty:"cache-to"`
-	Target           *string            `json:"target,omitempty" hcl:"target,optional" cty:"target"`
-	Secrets          []string           `json:"secret,omitempty" hcl:"secret,optional" cty:"secret"`
-	SSH              []string           `json:"ssh,omitempty" hcl:"ssh,optional" cty:"ssh"`
-	Platforms        []string           `json:"platforms,omitempty" hcl:"platforms,optional" cty:"platforms"`
-	Outputs          []string           `json:"output,omitempty" hcl:"output,optional" cty:"output"`
-	Pull             *bool              `json:"pull,omitempty" hcl:"pull,optional" cty:"pull"`
-	NoCache          *bool              `json:"no-cache,omitempty" hcl:"no-cache,optional" cty:"no-cache"`
-	NetworkMode      *string            `json:"network,omitempty" hcl:"network,optional" cty:"network"`
-	NoCacheFilter    []string           `json:"no-cache-filter,omitempty" hcl:"no-cache-filter,optional" cty:"no-cache-filter"`
-	ShmSize          *string            `json:"shm-size,omitempty" hcl:"shm-size,optional"`
-	Ulimits          []string           `json:"ulimits,omitempty" hcl:"ulimits,optional"`
-	Call             *string            `json:"call,omitempty" hcl:"call,optional" cty:"call"`
-	Entitlements     []string           `json:"entitlements,omitempty" hcl:"entitlements,optional" cty:"entitlements"`
+	Annotations      []string            `json:"annotations,omitempty" hcl:"annotations,optional" cty:"annotations"`
+	Attest           []string            `json:"attest,omitempty" hcl:"attest,optional" cty:"attest"`
+	Context          *string             `json:"context,omitempty" hcl:"context,optional" cty:"context"`
+	Contexts         map[string]string   `json:"contexts,omitempty" hcl:"contexts,optional" cty:"contexts"`
+	Dockerfile       *string             `json:"dockerfile,omitempty" hcl:"dockerfile,optional" cty:"dockerfile"`
+	DockerfileInline *string             `json:"dockerfile-inline,omitempty" hcl:"dockerfile-inline,optional" cty:"dockerfile-inline"`
+	Args             map[string]*string  `json:"args,omitempty" hcl:"args,optional" cty:"args"`
+	Labels           map[string]*string  `json:"labels,omitempty" hcl:"labels,optional" cty:"labels"`
+	Tags             []string            `json:"tags,omitempty" hcl:"tags,optional" cty:"tags"`
+	CacheFrom        []string            `json:"cache-from,omitempty"  hcl:"cache-from,optional" cty:"cache-from"`
+	CacheTo          []string            `json:"cache-to,omitempty"  hcl:"cache-to,optional" cty:"cache-to"`
+	Target           *string             `json:"target,omitempty" hcl:"target,optional" cty:"target"`
+	Secrets          []string            `json:"secret,omitempty" hcl:"secret,optional" cty:"secret"`
+	SSH              []string            `json:"ssh,omitempty" hcl:"ssh,optional" cty:"ssh"`
+	Platforms        []string            `json:"platforms,omitempty" hcl:"platforms,optional" cty:"platforms"`
+	Outputs          []string            `json:"output,omitempty" hcl:"output,optional" cty:"output"`
+	Pull             *bool               `json:"pull,omitempty" hcl:"pull,optional" cty:"pull"`
+	NoCache          *bool               `json:"no-cache,omitempty" hcl:"no-cache,optional" cty:"no-cache"`
+	NetworkMode      *string             `json:"network,omitempty" hcl:"network,optional" cty:"network"`
+	NoCacheFilter    []string            `json:"no-cache-filter,omitempty" hcl:"no-cache-filter,optional" cty:"no-cache-filter"`
+	ShmSize          *string             `json:"shm-size,omitempty" hcl:"shm-size,optional"`
+	Ulimits          []string            `json:"ulimits,omitempty" hcl:"ulimits,optional"`
+	Call             *string             `json:"call,omitempty" hcl:"call,optional" cty:"call"`
+	Entitlements     []string            `json:"entitlements,omitempty" hcl:"entitlements,optional" cty:"entitlements"`
+	RegistryAuth     []string            `json:"registry-auth,omitempty" hcl:"registry-auth,optional" cty:"registry-auth"`
+	Hooks            map[string][]string `json:"hooks,omitempty" hcl:"hooks,optional" cty:"hooks"`
 	// IMPORTANT: if you add more fields here, do not forget to update newOverrides/AddOverrides and docs/bake-reference.md.
 
 	// linked is a private field to mark a target used as a linked one
 	linked bool
+	// origins are the definitions and overrides that set the values of the
+	// attributes, for --explain
+	origins origins
 }
 
 var (
@@ -732,6 +1152,12 @@ var (
 	_ hclparser.WithGetName      = &Group{}
 )
 
+// IsEnabled returns false if the target is disabled with the enabled
+// attribute.
+func (t *Target) IsEnabled() bool {
+	return t.Enabled == nil || *t.Enabled
+}
+
 func (t *Target) normalize() {
 	t.Annotations = removeDupes(t.Annotations)
 	t.Attest = removeAttestDupes(t.Attest)
@@ -744,6 +1170,7 @@ func (t *Target) normalize() {
 	t.Outputs = removeDupes(t.Outputs)
 	t.NoCacheFilter = removeDupes(t.NoCacheFilter)
 	t.Ulimits = removeDupes(t.Ulimits)
+	t.RegistryAuth = removeDupes(t.RegistryAuth)
 
 	if t.NetworkMode != nil && *t.NetworkMode == "host" {
 		t.Entitlements = append(t.Entitlements, "network.host")
@@ -850,16 +1277,62 @@ func (t *Target) Merge(t2 *Target) {
 	if t2.Description != "" {
 		t.Description = t2.Description
 	}
+	if t2.Enabled != nil {
+		t.Enabled = t2.Enabled
+	}
 	if t2.Entitlements != nil { // merge
 		t.Entitlements = append(t.Entitlements, t2.Entitlements...)
 	}
+	if t2.RegistryAuth != nil { // merge
+		t.RegistryAuth = append(t.RegistryAuth, t2.RegistryAuth...)
+	}
+	for k, v := range t2.Hooks {
+		if t.Hooks == nil {
+			t.Hooks = map[string][]string{}
+		}
+		t.Hooks[k] = v
+	}
 	t.Inherits = append(t.Inherits, t2.Inherits...)
+	if len(t2.origins) > 0 {
+		if t.origins == nil {
+			t.origins = origins{}
+		}
+		t.origins.merge(t2.origins)
+	}
 }
 
 func (t *Target) AddOverrides(overrides map[string]Override, ent *EntitlementConf) error {
+	keys := make([]string, 0, len(overrides))
+	for key := range overrides {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		if specs := overrides[key].specs; len(specs) > 0 {
+			if t.origins == nil {
+				t.origins = origins{}
+			}
+			t.origins.override(attrName(key), specs)
+		}
+	}
+
 	for key, o := range overrides {
 		value := o.Value
 		keys := strings.SplitN(key, ".", 2)
+		if _, ok := mapAttributes[keys[0]]; ok && len(keys) == 1 {
+			// keys removed with the -= operator
+			switch keys[0] {
+			case "args":
+				removeKeys(t.Args, o)
+			case "contexts":
+				removeKeys(t.Contexts, o)
+			case "hooks":
+				removeKeys(t.Hooks, o)
+			case "labels":
+				removeKeys(t.Labels, o)
+			}
+			continue
+		}
 		switch keys[0] {
 		case "context":
 			t.Context = &value
@@ -890,10 +1363,10 @@ func (t *Target) AddOverrides(overrides map[string]Override, ent *EntitlementCon
 			}
 			t.Labels[keys[1]] = &value
 		case "tags":
-			t.Tags = o.ArrValue
+			t.Tags = o.apply(t.Tags, false)
 		case "cache-from":
-			t.CacheFrom = o.ArrValue
-			cacheFrom, err := buildflags.ParseCacheEntry(o.ArrValue)
+			t.CacheFrom = o.apply(t.CacheFrom, false)
+			cacheFrom, err := buildflags.ParseCacheEntry(o.values())
 			if err != nil {
 				return err
 			}
@@ -905,8 +1378,8 @@ func (t *Target) AddOverrides(overrides map[string]Override, ent *EntitlementCon
 				}
 			}
 		case "cache-to":
-			t.CacheTo = o.ArrValue
-			cacheTo, err := buildflags.ParseCacheEntry(o.ArrValue)
+			t.CacheTo = o.apply(t.CacheTo, false)
+			cacheTo, err := buildflags.ParseCacheEntry(o.values())
 			if err != nil {
 				return err
 			}
@@ -919,11 +1392,29 @@ func (t *Target) AddOverrides(overrides map[string]Override, ent *EntitlementCon
 			}
 		case "target":
 			t.Target = &value
+		case "hooks":
+			if len(keys) != 2 {
+				return errors.Errorf("invalid format for hooks, expecting hooks.<name>=<command>")
+			}
+			if t.Hooks == nil {
+				t.Hooks = map[string][]string{}
+			}
+			t.Hooks[keys[1]] = o.apply(t.Hooks[keys[1]], false)
+			for _, cmd := range o.values() {
+				// hooks set from the command line are allowed
+				ent.grant(EntitlementKeyHooks, keys[1]+"="+cmd)
+			}
+		case "enabled":
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return errors.Errorf("invalid value %s for boolean key enabled", value)
+			}
+			t.Enabled = &enabled
 		case "call":
 			t.Call = &value
 		case "secrets":
-			t.Secrets = o.ArrValue
-			secrets, err := buildflags.ParseSecretSpecs(o.ArrValue)
+			t.Secrets = o.apply(t.Secrets, false)
+			secrets, err := buildflags.ParseSecretSpecs(o.values())
 			if err != nil {
 				return errors.Wrap(err, "invalid value for outputs")
 			}
@@ -931,10 +1422,33 @@ func (t *Target) AddOverrides(overrides map[string]Override, ent *EntitlementCon
 				if s.FilePath != "" {
 					ent.FSRead = append(ent.FSRead, s.FilePath)
 				}
+				if s.Cmd != "" {
+					ent.grant(EntitlementKeySecretCmd, s.Cmd)
+				}
+				if s.Registry != "" {
+					ent.grant(EntitlementKeySecretRegistry, s.Registry)
+				}
+				if s.Keyring != "" {
+					ent.grant(EntitlementKeySecretKeyring, s.Keyring)
+				}
+			}
+		case "registry-auth":
+			t.RegistryAuth = o.apply(t.RegistryAuth, false)
+			auths, err := buildflags.ParseRegistryAuthSpecs(o.values())
+			if err != nil {
+				return errors.Wrap(err, "invalid value for registry-auth")
+			}
+			for _, a := range auths {
+				if a.FilePath != "" {
+					ent.FSRead = append(ent.FSRead, a.FilePath)
+				}
+				if a.Cmd != "" {
+					ent.grant(EntitlementKeySecretCmd, a.Cmd)
+				}
 			}
 		case "ssh":
-			t.SSH = o.ArrValue
-			ssh, err := buildflags.ParseSSHSpecs(o.ArrValue)
+			t.SSH = o.apply(t.SSH, false)
+			ssh, err := buildflags.ParseSSHSpecs(o.values())
 			if err != nil {
 				return errors.Wrap(err, "invalid value for outputs")
 			}
@@ -942,10 +1456,10 @@ func (t *Target) AddOverrides(overrides map[string]Override, ent *EntitlementCon
 				ent.FSRead = append(ent.FSRead, s.Paths...)
 			}
 		case "platform":
-			t.Platforms = o.ArrValue
+			t.Platforms = o.apply(t.Platforms, false)
 		case "output":
-			t.Outputs = o.ArrValue
-			outputs, err := buildflags.ParseExports(o.ArrValue)
+			t.Outputs = o.apply(t.Outputs, false)
+			outputs, err := buildflags.ParseExports(o.values())
 			if err != nil {
 				return errors.Wrap(err, "invalid value for outputs")
 			}
@@ -955,8 +1469,8 @@ func (t *Target) AddOverrides(overrides map[string]Override, ent *EntitlementCon
 				}
 			}
 		case "entitlements":
-			t.Entitlements = append(t.Entitlements, o.ArrValue...)
-			for _, v := range o.ArrValue {
+			t.Entitlements = o.apply(t.Entitlements, true)
+			for _, v := range o.values() {
 				if v == string(EntitlementKeyNetworkHost) {
 					ent.NetworkHost = true
 				} else if v == string(EntitlementKeySecurityInsecure) {
@@ -964,9 +1478,9 @@ func (t *Target) AddOverrides(overrides map[string]Override, ent *EntitlementCon
 				}
 			}
 		case "annotations":
-			t.Annotations = append(t.Annotations, o.ArrValue...)
+			t.Annotations = o.apply(t.Annotations, true)
 		case "attest":
-			t.Attest = append(t.Attest, o.ArrValue...)
+			t.Attest = o.apply(t.Attest, true)
 		case "no-cache":
 			noCache, err := strconv.ParseBool(value)
 			if err != nil {
@@ -974,11 +1488,11 @@ func (t *Target) AddOverrides(overrides map[string]Override, ent *EntitlementCon
 			}
 			t.NoCache = &noCache
 		case "no-cache-filter":
-			t.NoCacheFilter = o.ArrValue
+			t.NoCacheFilter = o.apply(t.NoCacheFilter, false)
 		case "shm-size":
 			t.ShmSize = &value
 		case "ulimits":
-			t.Ulimits = o.ArrValue
+			t.Ulimits = o.apply(t.Ulimits, false)
 		case "network":
 			t.NetworkMode = &value
 		case "pull":
@@ -1116,18 +1630,29 @@ func (t *Target) GetName(ectx *hcl.EvalContext, block *hcl.Block, loadDeps func(
 	return value.AsString(), nil
 }
 
-func TargetsToBuildOpt(m map[string]*Target, inp *Input) (map[string]build.Options, error) {
+// TargetsToBuildOpt returns the build options of the enabled targets. If
+// isolatedAuth is set, the registry credentials are only the ones of the
+// registry-auth attribute of each target and not the ones of the docker config.
+func TargetsToBuildOpt(ctx context.Context, m map[string]*Target, inp *Input, isolatedAuth bool) (map[string]build.Options, error) {
 	// make sure local credentials are loaded multiple times for different targets
-	dockerConfig := config.LoadDefaultConfigFile(os.Stderr)
+	var dockerConfig *configfile.ConfigFile
+	if !isolatedAuth {
+		dockerConfig = config.LoadDefaultConfigFile(os.Stderr)
+	}
 	authProvider := authprovider.NewDockerAuthProvider(dockerConfig, nil)
 
 	m2 := make(map[string]build.Options, len(m))
 	for k, v := range m {
-		bo, err := toBuildOpt(v, inp)
+		if !v.IsEnabled() {
+			continue
+		}
+		bo, err := toBuildOpt(ctx, v, inp, dockerConfig, isolatedAuth)
 		if err != nil {
 			return nil, err
 		}
-		bo.Session = append(bo.Session, authProvider)
+		if bo.RegistryAuth == nil {
+			bo.Session = append(bo.Session, authProvider)
+		}
 		m2[k] = *bo
 	}
 	return m2, nil
@@ -1201,7 +1726,7 @@ func isLocalPath(p string) (string, bool) {
 	return strings.TrimPrefix(p, "cwd://"), true
 }
 
-func toBuildOpt(t *Target, inp *Input) (*build.Options, error) {
+func toBuildOpt(ctx context.Context, t *Target, inp *Input, dockerConfig *configfile.ConfigFile, isolatedAuth bool) (*build.Options, error) {
 	if v := t.Context; v != nil && *v == "-" {
 		return nil, errors.Errorf("context from stdin not allowed in bake")
 	}
@@ -1336,7 +1861,21 @@ func toBuildOpt(t *Target, inp *Input) (*build.Options, error) {
 	}
 	bo.SecretSpecs = secrets
 
-	secretAttachment, err := controllerapi.CreateSecrets(secrets)
+	registryAuth, err := buildflags.ParseRegistryAuthSpecs(t.RegistryAuth)
+	if err != nil {
+		return nil, err
+	}
+	bo.RegistryAuthSpecs = registryAuth
+
+	var registryCreds controllerapi.RegistryCredentials = dockerConfig
+	if len(registryAuth) > 0 || isolatedAuth {
+		authConfig := controllerapi.NewRegistryAuthConfig(ctx, dockerConfig, registryAuth)
+		bo.Session = append(bo.Session, authConfig.AuthProvider())
+		bo.RegistryAuth = authConfig
+		registryCreds = authConfig
+	}
+
+	secretAttachment, err := controllerapi.CreateSecrets(secrets, registryCreds)
 	if err != nil {
 		return nil, err
 	}
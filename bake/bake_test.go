@@ -603,7 +603,7 @@ func TestHCLContextCwdPrefix(t *testing.T) {
 	m, g, err := ReadTargets(ctx, []File{fp}, []string{"app"}, nil, nil, &EntitlementConf{})
 	require.NoError(t, err)
 
-	bo, err := TargetsToBuildOpt(m, &Input{})
+	bo, err := TargetsToBuildOpt(context.TODO(), m, &Input{}, false)
 	require.NoError(t, err)
 
 	require.Equal(t, 1, len(g))
@@ -634,7 +634,7 @@ func TestHCLDockerfileCwdPrefix(t *testing.T) {
 	m, g, err := ReadTargets(ctx, []File{fp}, []string{"app"}, nil, nil, &EntitlementConf{})
 	require.NoError(t, err)
 
-	bo, err := TargetsToBuildOpt(m, &Input{})
+	bo, err := TargetsToBuildOpt(context.TODO(), m, &Input{}, false)
 	require.NoError(t, err)
 
 	require.Equal(t, 1, len(g))
@@ -669,7 +669,7 @@ func TestOverrideMerge(t *testing.T) {
 	_, ok := m["app"]
 	require.True(t, ok)
 
-	_, err = TargetsToBuildOpt(m, &Input{})
+	_, err = TargetsToBuildOpt(context.TODO(), m, &Input{}, false)
 	require.NoError(t, err)
 
 	require.Equal(t, []string{"linux/arm", "linux/ppc64le"}, m["app"].Platforms)
@@ -704,7 +704,7 @@ func TestReadContexts(t *testing.T) {
 	_, ok := m["app"]
 	require.True(t, ok)
 
-	bo, err := TargetsToBuildOpt(m, &Input{})
+	bo, err := TargetsToBuildOpt(context.TODO(), m, &Input{}, false)
 	require.NoError(t, err)
 
 	ctxs := bo["app"].Inputs.NamedContexts
@@ -720,7 +720,7 @@ func TestReadContexts(t *testing.T) {
 	_, ok = m["app"]
 	require.True(t, ok)
 
-	bo, err = TargetsToBuildOpt(m, &Input{})
+	bo, err = TargetsToBuildOpt(context.TODO(), m, &Input{}, false)
 	require.NoError(t, err)
 
 	ctxs = bo["app"].Inputs.NamedContexts
@@ -738,7 +738,7 @@ func TestReadContexts(t *testing.T) {
 	_, ok = m["app"]
 	require.True(t, ok)
 
-	bo, err = TargetsToBuildOpt(m, &Input{})
+	bo, err = TargetsToBuildOpt(context.TODO(), m, &Input{}, false)
 	require.NoError(t, err)
 
 	ctxs = bo["app"].Inputs.NamedContexts
@@ -1574,7 +1574,7 @@ func TestHCLNullVars(t *testing.T) {
 	_, ok := m["default"]
 	require.True(t, ok)
 
-	_, err = TargetsToBuildOpt(m, &Input{})
+	_, err = TargetsToBuildOpt(context.TODO(), m, &Input{}, false)
 	require.NoError(t, err)
 	require.Equal(t, map[string]*string{"bar": ptrstr("baz")}, m["default"].Args)
 	require.Equal(t, map[string]*string{"com.docker.app.baz": ptrstr("foo")}, m["default"].Labels)
@@ -1609,7 +1609,7 @@ func TestJSONNullVars(t *testing.T) {
 	_, ok := m["default"]
 	require.True(t, ok)
 
-	_, err = TargetsToBuildOpt(m, &Input{})
+	_, err = TargetsToBuildOpt(context.TODO(), m, &Input{}, false)
 	require.NoError(t, err)
 	require.Equal(t, map[string]*string{"bar": ptrstr("baz")}, m["default"].Args)
 }
@@ -1681,7 +1681,7 @@ func TestAttestDuplicates(t *testing.T) {
 	require.Equal(t, []string{"type=sbom,foo=bar", "type=provenance,mode=max"}, m["default"].Attest)
 	require.NoError(t, err)
 
-	opts, err := TargetsToBuildOpt(m, &Input{})
+	opts, err := TargetsToBuildOpt(context.TODO(), m, &Input{}, false)
 	require.NoError(t, err)
 	require.Equal(t, map[string]*string{
 		"sbom":       ptrstr("type=sbom,foo=bar"),
@@ -1692,7 +1692,7 @@ func TestAttestDuplicates(t *testing.T) {
 	require.Equal(t, []string{"type=sbom,disabled=true", "type=provenance,mode=max"}, m["default"].Attest)
 	require.NoError(t, err)
 
-	opts, err = TargetsToBuildOpt(m, &Input{})
+	opts, err = TargetsToBuildOpt(context.TODO(), m, &Input{}, false)
 	require.NoError(t, err)
 	require.Equal(t, map[string]*string{
 		"sbom":       nil,
@@ -1713,7 +1713,7 @@ func TestAnnotations(t *testing.T) {
 	m, g, err := ReadTargets(ctx, []File{fp}, []string{"app"}, nil, nil, &EntitlementConf{})
 	require.NoError(t, err)
 
-	bo, err := TargetsToBuildOpt(m, &Input{})
+	bo, err := TargetsToBuildOpt(context.TODO(), m, &Input{}, false)
 	require.NoError(t, err)
 
 	require.Equal(t, 1, len(g))
@@ -1740,7 +1740,7 @@ func TestHCLEntitlements(t *testing.T) {
 	m, g, err := ReadTargets(ctx, []File{fp}, []string{"app"}, nil, nil, &EntitlementConf{})
 	require.NoError(t, err)
 
-	bo, err := TargetsToBuildOpt(m, &Input{})
+	bo, err := TargetsToBuildOpt(context.TODO(), m, &Input{}, false)
 	require.NoError(t, err)
 
 	require.Equal(t, 1, len(g))
@@ -1780,7 +1780,7 @@ func TestEntitlementsForNetHostCompose(t *testing.T) {
 	m, g, err := ReadTargets(ctx, []File{fp, fp2}, []string{"app"}, nil, nil, &EntitlementConf{})
 	require.NoError(t, err)
 
-	bo, err := TargetsToBuildOpt(m, &Input{})
+	bo, err := TargetsToBuildOpt(context.TODO(), m, &Input{}, false)
 	require.NoError(t, err)
 
 	require.Equal(t, 1, len(g))
@@ -1811,7 +1811,7 @@ func TestEntitlementsForNetHost(t *testing.T) {
 	m, g, err := ReadTargets(ctx, []File{fp}, []string{"app"}, nil, nil, &EntitlementConf{})
 	require.NoError(t, err)
 
-	bo, err := TargetsToBuildOpt(m, &Input{})
+	bo, err := TargetsToBuildOpt(context.TODO(), m, &Input{}, false)
 	require.NoError(t, err)
 
 	require.Equal(t, 1, len(g))
@@ -1842,7 +1842,7 @@ func TestNetNone(t *testing.T) {
 	m, g, err := ReadTargets(ctx, []File{fp}, []string{"app"}, nil, nil, &EntitlementConf{})
 	require.NoError(t, err)
 
-	bo, err := TargetsToBuildOpt(m, &Input{})
+	bo, err := TargetsToBuildOpt(context.TODO(), m, &Input{}, false)
 	require.NoError(t, err)
 
 	require.Equal(t, 1, len(g))
@@ -2045,3 +2045,219 @@ target "app" {
 	_, _, err := ReadTargets(ctx, []File{fp}, []string{"app"}, []string{"app.output="}, nil, &EntitlementConf{})
 	require.NoError(t, err)
 }
+
+func TestAuthBlocks(t *testing.T) {
+	fp := File{
+		Name: "docker-bake.hcl",
+		Data: []byte(`
+auth "ghcr" {
+  host = "ghcr.io"
+  username = "bot"
+  env = "GHCR_TOKEN"
+}
+auth "localhost" {
+  cmd = "pass show registry, local"
+}
+target "app" {
+  registry-auth = ["host=ghcr.io,env=APP_TOKEN"]
+}
+target "other" {
+}
+`),
+	}
+
+	ctx := context.TODO()
+	m, _, err := ReadTargets(ctx, []File{fp}, []string{"app", "other"}, []string{"other.registry-auth=host=docker.io,src=token.txt"}, nil, &EntitlementConf{})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{
+		"host=ghcr.io,username=bot,env=GHCR_TOKEN",
+		`host=localhost,"cmd=pass show registry, local"`,
+		"host=ghcr.io,env=APP_TOKEN",
+	}, m["app"].RegistryAuth)
+	require.Equal(t, []string{
+		"host=ghcr.io,username=bot,env=GHCR_TOKEN",
+		`host=localhost,"cmd=pass show registry, local"`,
+		"host=docker.io,src=token.txt",
+	}, m["other"].RegistryAuth)
+
+	bo, err := TargetsToBuildOpt(context.TODO(), m, &Input{}, false)
+	require.NoError(t, err)
+	require.Len(t, bo["app"].RegistryAuthSpecs, 3)
+	require.Equal(t, "pass show registry, local", bo["app"].RegistryAuthSpecs[1].Cmd)
+	require.NotNil(t, bo["app"].RegistryAuth)
+}
+
+func TestRegistryAuthIsolated(t *testing.T) {
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+	t.Setenv("APP_TOKEN", "s3cret")
+	require.NoError(t, os.WriteFile(filepath.Join(os.Getenv("DOCKER_CONFIG"), "config.json"), []byte(`{"auths":{"docker.io":{"auth":"dXNlcjpwYXNz"}}}`), 0600))
+
+	m := map[string]*Target{
+		"app":   {Name: "app", RegistryAuth: []string{"host=ghcr.io,username=bot,env=APP_TOKEN"}},
+		"other": {Name: "other"},
+	}
+	bo, err := TargetsToBuildOpt(context.TODO(), m, &Input{}, true)
+	require.NoError(t, err)
+
+	ac, err := bo["app"].RegistryAuth.GetAuthConfig("ghcr.io")
+	require.NoError(t, err)
+	require.Equal(t, "bot", ac.Username)
+
+	for _, name := range []string{"app", "other"} {
+		require.NotNil(t, bo[name].RegistryAuth)
+		ac, err := bo[name].RegistryAuth.GetAuthConfig("docker.io")
+		require.NoError(t, err)
+		require.Empty(t, ac.Username)
+	}
+}
+
+func TestReadTargetsEnabled(t *testing.T) {
+	fp := File{
+		Name: "docker-bake.hcl",
+		Data: []byte(`
+variable "ARM64" {
+  default = false
+}
+
+group "default" {
+  targets = ["app", "arm64", "tests"]
+}
+
+group "tests" {
+  enabled = false
+  targets = ["test"]
+}
+
+target "base" {
+  enabled = false
+}
+
+target "app" {
+  inherits = ["base"]
+}
+
+target "arm64" {
+  enabled = ARM64
+  platforms = ["linux/arm64"]
+}
+
+target "test" {}
+
+target "linked" {
+  contexts = {
+    arm = "target:arm64"
+  }
+}
+`),
+	}
+
+	ctx := context.TODO()
+
+	t.Run("Default", func(t *testing.T) {
+		m, g, err := ReadTargets(ctx, []File{fp}, []string{"default"}, nil, nil, &EntitlementConf{})
+		require.NoError(t, err)
+		require.Len(t, m, 2)
+		require.True(t, m["app"].IsEnabled())
+		require.False(t, m["arm64"].IsEnabled())
+		require.NotContains(t, m, "test")
+		require.Equal(t, []string{"app", "arm64", "tests"}, g["default"].Targets)
+
+		bo, err := TargetsToBuildOpt(context.TODO(), m, &Input{}, false)
+		require.NoError(t, err)
+		require.Len(t, bo, 1)
+		require.Contains(t, bo, "app")
+	})
+
+	t.Run("Variable", func(t *testing.T) {
+		t.Setenv("ARM64", "true")
+		m, _, err := ReadTargets(ctx, []File{fp}, []string{"arm64"}, nil, nil, &EntitlementConf{})
+		require.NoError(t, err)
+		require.True(t, m["arm64"].IsEnabled())
+	})
+
+	t.Run("Override", func(t *testing.T) {
+		m, _, err := ReadTargets(ctx, []File{fp}, []string{"arm64"}, []string{"arm64.enabled=true"}, nil, &EntitlementConf{})
+		require.NoError(t, err)
+		require.True(t, m["arm64"].IsEnabled())
+	})
+
+	t.Run("DisabledGroup", func(t *testing.T) {
+		m, g, err := ReadTargets(ctx, []File{fp}, []string{"tests"}, nil, nil, &EntitlementConf{})
+		require.NoError(t, err)
+		require.Empty(t, m)
+		require.Contains(t, g, "tests")
+	})
+
+	t.Run("Linked", func(t *testing.T) {
+		_, _, err := ReadTargets(ctx, []File{fp}, []string{"linked"}, nil, nil, &EntitlementConf{})
+		require.ErrorContains(t, err, "target arm64 can't be used by linked because it is disabled")
+	})
+}
+
+func TestOverrideListOperators(t *testing.T) {
+	fp := File{
+		Name: "docker-bake.hcl",
+		Data: []byte(`
+target "app" {
+  tags = ["app:latest"]
+  platforms = ["linux/amd64", "linux/arm64", "linux/386"]
+  output = ["type=image,push=true", "type=local,dest=./bin"]
+  attest = ["type=sbom", "type=provenance,mode=max"]
+  args = {
+    FOO = "foo"
+    BAR = "bar"
+  }
+  labels = {
+    "org.opencontainers.image.title" = "app"
+    "org.opencontainers.image.source" = "https://github.com/docker/buildx"
+  }
+}
+`),
+	}
+	ctx := context.TODO()
+
+	t.Run("Append", func(t *testing.T) {
+		m, _, err := ReadTargets(ctx, []File{fp}, []string{"app"}, []string{"*.tags+=reg/app:pr-1", "app.tags^=reg/app:first"}, nil, &EntitlementConf{})
+		require.NoError(t, err)
+		require.Equal(t, []string{"reg/app:first", "app:latest", "reg/app:pr-1"}, m["app"].Tags)
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		m, _, err := ReadTargets(ctx, []File{fp}, []string{"app"}, []string{
+			"app.platforms-=linux/386",
+			"app.output-=type=local",
+			"app.attest-=type=provenance",
+			"app.args-=BAR",
+			"app.labels-=org.opencontainers.image.*",
+		}, nil, &EntitlementConf{})
+		require.NoError(t, err)
+		require.Equal(t, []string{"linux/amd64", "linux/arm64"}, m["app"].Platforms)
+		require.Equal(t, []string{"type=image,push=true"}, m["app"].Outputs)
+		require.Equal(t, []string{"type=sbom"}, m["app"].Attest)
+		require.Equal(t, map[string]*string{"FOO": ptrstr("foo")}, m["app"].Args)
+		require.Empty(t, m["app"].Labels)
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		m, _, err := ReadTargets(ctx, []File{fp}, []string{"app"}, []string{"app.platform=linux/arm64", "app.platforms+=linux/riscv64", "app.tags=", "*.args.FOO="}, nil, &EntitlementConf{})
+		require.NoError(t, err)
+		require.Equal(t, []string{"linux/arm64", "linux/riscv64"}, m["app"].Platforms)
+		require.Empty(t, m["app"].Tags)
+		require.Equal(t, ptrstr(""), m["app"].Args["FOO"])
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		_, _, err := ReadTargets(ctx, []File{fp}, []string{"app"}, []string{"app.context+=./foo"}, nil, &EntitlementConf{})
+		require.ErrorContains(t, err, "operator += is not supported for context")
+		_, _, err = ReadTargets(ctx, []File{fp}, []string{"app"}, []string{"app.args+=FOO"}, nil, &EntitlementConf{})
+		require.ErrorContains(t, err, "operator += is not supported for args")
+	})
+
+	t.Run("Entitlements", func(t *testing.T) {
+		ent := EntitlementConf{}
+		_, _, err := ReadTargets(ctx, []File{fp}, []string{"app"}, []string{"app.output+=type=local,dest=/tmp/out"}, nil, &ent)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/tmp/out"}, ent.FSWrite)
+	})
+}
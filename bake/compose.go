@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 
@@ -18,6 +19,12 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// dependsOnContextPrefix is the prefix of the named contexts linking the
+// services a service depends on. "#" is not allowed in stage names nor in
+// image references, so these contexts never replace a stage or an image of
+// the Dockerfile.
+const dependsOnContextPrefix = "depends_on#"
+
 func ParseComposeFiles(fs []File) (*Config, error) {
 	envs, err := composeEnv()
 	if err != nil {
@@ -48,6 +55,9 @@ func ParseCompose(cfgs []composetypes.ConfigFile, envs map[string]string) (*Conf
 		options.SetProjectName(projectName, false)
 		options.SkipNormalization = true
 		options.Profiles = []string{"*"}
+		if v, ok := envs[consts.ComposeProfiles]; ok && v != "" {
+			options.Profiles = strings.Split(v, ",")
+		}
 	})
 	if err != nil {
 		return nil, err
@@ -59,6 +69,7 @@ func ParseCompose(cfgs []composetypes.ConfigFile, envs map[string]string) (*Conf
 		c.Targets = []*Target{}
 
 		g := &Group{Name: "default"}
+		profiles := map[string][]string{}
 
 		for _, s := range cfg.Services {
 			s := s
@@ -91,9 +102,25 @@ func ParseCompose(cfgs []composetypes.ConfigFile, envs map[string]string) (*Conf
 			if s.Build.AdditionalContexts != nil {
 				additionalContexts = map[string]string{}
 				for k, v := range s.Build.AdditionalContexts {
+					if name, ok := strings.CutPrefix(v, "service:"); ok {
+						// image built for another service
+						v = "target:" + sanitizeTargetName(name)
+					}
 					additionalContexts[k] = v
 				}
 			}
+			// services the service depends on are built before it, linked
+			// as named contexts with a key that can't shadow a stage or an
+			// image of the Dockerfile
+			for name := range s.DependsOn {
+				if dep, ok := cfg.Services[name]; !ok || dep.Build == nil {
+					continue
+				}
+				if additionalContexts == nil {
+					additionalContexts = map[string]string{}
+				}
+				additionalContexts[dependsOnContextPrefix+name] = "target:" + sanitizeTargetName(name)
+			}
 
 			var shmSize *string
 			if s.Build.ShmSize > 0 {
@@ -126,6 +153,11 @@ func ParseCompose(cfgs []composetypes.ConfigFile, envs map[string]string) (*Conf
 			}
 			sort.Strings(ssh)
 
+			entitlements := slices.Clone(s.Build.Entitlements)
+			if s.Build.Privileged {
+				entitlements = append(entitlements, "security.insecure")
+			}
+
 			var secrets []string
 			for _, bs := range s.Build.Secrets {
 				secret, err := composeToBuildkitSecret(bs, cfg.Secrets[bs.Source])
@@ -143,6 +175,11 @@ func ParseCompose(cfgs []composetypes.ConfigFile, envs map[string]string) (*Conf
 			}
 
 			g.Targets = append(g.Targets, targetName)
+			for _, p := range s.Profiles {
+				if slices.Contains(cfg.Profiles, "*") || slices.Contains(cfg.Profiles, p) {
+					profiles[p] = append(profiles[p], targetName)
+				}
+			}
 			t := &Target{
 				Name:             targetName,
 				Context:          contextPathP,
@@ -158,13 +195,14 @@ func ParseCompose(cfgs []composetypes.ConfigFile, envs map[string]string) (*Conf
 					val, ok := cfg.Environment[val]
 					return val, ok
 				})),
-				CacheFrom:   s.Build.CacheFrom,
-				CacheTo:     s.Build.CacheTo,
-				NetworkMode: networkModeP,
-				SSH:         ssh,
-				Secrets:     secrets,
-				ShmSize:     shmSize,
-				Ulimits:     ulimits,
+				CacheFrom:    s.Build.CacheFrom,
+				CacheTo:      s.Build.CacheTo,
+				NetworkMode:  networkModeP,
+				SSH:          ssh,
+				Secrets:      secrets,
+				ShmSize:      shmSize,
+				Ulimits:      ulimits,
+				Entitlements: entitlements,
 			}
 			if err = t.composeExtTarget(s.Build.Extensions); err != nil {
 				return nil, err
@@ -179,6 +217,25 @@ func ParseCompose(cfgs []composetypes.ConfigFile, envs map[string]string) (*Conf
 			c.Targets = append(c.Targets, t)
 		}
 		c.Groups = append(c.Groups, g)
+
+		// each active profile is a group of its services, unless the name
+		// is already used by a service or by a target of another file
+		profileNames := make([]string, 0, len(profiles))
+		for p := range profiles {
+			profileNames = append(profileNames, p)
+		}
+		sort.Strings(profileNames)
+		for _, p := range profileNames {
+			name := sanitizeTargetName(p)
+			if name == "default" || slices.ContainsFunc(c.Targets, func(t *Target) bool { return t.Name == name }) {
+				continue
+			}
+			if err := validateTargetName(name); err != nil {
+				return nil, errors.Wrapf(err, "invalid profile name %q", p)
+			}
+			sort.Strings(profiles[p])
+			c.Groups = append(c.Groups, &Group{Name: name, Targets: profiles[p], profile: true})
+		}
 	}
 
 	return &c, nil
@@ -282,6 +339,9 @@ func flatten(in composetypes.MappingWithEquals) map[string]*string {
 // xbake Compose build extension provides fields not (yet) available in
 // Compose build specification: https://github.com/compose-spec/compose-spec/blob/master/build.md
 type xbake struct {
+	Description   *string     `yaml:"description,omitempty"`
+	Annotations   stringArray `yaml:"annotations,omitempty"`
+	Attest        stringArray `yaml:"attest,omitempty"`
 	Tags          stringArray `yaml:"tags,omitempty"`
 	CacheFrom     stringArray `yaml:"cache-from,omitempty"`
 	CacheTo       stringArray `yaml:"cache-to,omitempty"`
@@ -293,6 +353,12 @@ type xbake struct {
 	NoCache       *bool       `yaml:"no-cache,omitempty"`
 	NoCacheFilter stringArray `yaml:"no-cache-filter,omitempty"`
 	Contexts      stringMap   `yaml:"contexts,omitempty"`
+	NetworkMode   *string     `yaml:"network,omitempty"`
+	ShmSize       *string     `yaml:"shm-size,omitempty"`
+	Ulimits       stringArray `yaml:"ulimits,omitempty"`
+	Call          *string     `yaml:"call,omitempty"`
+	Entitlements  stringArray `yaml:"entitlements,omitempty"`
+	RegistryAuth  stringArray `yaml:"registry-auth,omitempty"`
 	// don't forget to update documentation if you add a new field:
 	// https://github.com/docker/docs/blob/main/content/build/bake/compose-file.md#extension-field-with-x-bake
 }
@@ -330,6 +396,15 @@ func (t *Target) composeExtTarget(exts map[string]interface{}) error {
 		return err
 	}
 
+	if xb.Description != nil {
+		t.Description = *xb.Description
+	}
+	if len(xb.Annotations) > 0 {
+		t.Annotations = dedupSlice(append(t.Annotations, xb.Annotations...))
+	}
+	if len(xb.Attest) > 0 {
+		t.Attest = dedupSlice(append(t.Attest, xb.Attest...))
+	}
 	if len(xb.Tags) > 0 {
 		t.Tags = dedupSlice(append(t.Tags, xb.Tags...))
 	}
@@ -364,6 +439,24 @@ func (t *Target) composeExtTarget(exts map[string]interface{}) error {
 	if len(xb.Contexts) > 0 {
 		t.Contexts = dedupMap(t.Contexts, xb.Contexts)
 	}
+	if xb.NetworkMode != nil {
+		t.NetworkMode = xb.NetworkMode
+	}
+	if xb.ShmSize != nil {
+		t.ShmSize = xb.ShmSize
+	}
+	if len(xb.Ulimits) > 0 {
+		t.Ulimits = dedupSlice(append(t.Ulimits, xb.Ulimits...))
+	}
+	if xb.Call != nil {
+		t.Call = xb.Call
+	}
+	if len(xb.Entitlements) > 0 {
+		t.Entitlements = dedupSlice(append(t.Entitlements, xb.Entitlements...))
+	}
+	if len(xb.RegistryAuth) > 0 {
+		t.RegistryAuth = dedupSlice(append(t.RegistryAuth, xb.RegistryAuth...))
+	}
 
 	return nil
 }
@@ -1,6 +1,7 @@
 package bake
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"sort"
@@ -55,10 +56,12 @@ secrets:
 	c, err := ParseCompose([]composetypes.ConfigFile{{Content: dt}}, nil)
 	require.NoError(t, err)
 
-	require.Equal(t, 1, len(c.Groups))
+	require.Equal(t, 2, len(c.Groups))
 	require.Equal(t, "default", c.Groups[0].Name)
 	sort.Strings(c.Groups[0].Targets)
 	require.Equal(t, []string{"db", "webapp", "webapp2"}, c.Groups[0].Targets)
+	require.Equal(t, "test", c.Groups[1].Name)
+	require.Equal(t, []string{"webapp2"}, c.Groups[1].Targets)
 
 	require.Equal(t, 3, len(c.Targets))
 	sort.Slice(c.Targets, func(i, j int) bool {
@@ -304,6 +307,14 @@ services:
         cache-to:
           - type=local,dest=path/to/cache
         pull: true
+        annotations:
+          - index,manifest:org.opencontainers.image.authors=dvdksn
+        attest:
+          - type=provenance,mode=max
+        call: check
+        network: host
+        entitlements:
+          - network.host
 
   aws:
     image: ct-fake-aws:bar
@@ -341,6 +352,11 @@ services:
 	require.Equal(t, []string{"default", "key=path/to/key", "other=path/to/otherkey"}, c.Targets[0].SSH)
 	require.Equal(t, newBool(true), c.Targets[0].Pull)
 	require.Equal(t, map[string]string{"alpine": "docker-image://alpine:3.13"}, c.Targets[0].Contexts)
+	require.Equal(t, []string{"index,manifest:org.opencontainers.image.authors=dvdksn"}, c.Targets[0].Annotations)
+	require.Equal(t, []string{"type=provenance,mode=max"}, c.Targets[0].Attest)
+	require.Equal(t, ptrstr("check"), c.Targets[0].Call)
+	require.Equal(t, ptrstr("host"), c.Targets[0].NetworkMode)
+	require.Equal(t, []string{"network.host"}, c.Targets[0].Entitlements)
 	require.Equal(t, []string{"ct-fake-aws:bar"}, c.Targets[1].Tags)
 	require.Equal(t, []string{"id=mysecret,src=/local/secret", "id=mysecret2,src=/local/secret2"}, c.Targets[1].Secrets)
 	require.Equal(t, []string{"default"}, c.Targets[1].SSH)
@@ -351,6 +367,162 @@ services:
 	require.Equal(t, []string{"nofile=1024:1024"}, c.Targets[1].Ulimits)
 }
 
+func TestComposeProfiles(t *testing.T) {
+	var dt = []byte(`
+services:
+  app:
+    build:
+      context: .
+  debug:
+    build:
+      context: .
+      target: debug
+    profiles:
+      - dev
+  docs:
+    build:
+      context: ./docs
+    profiles:
+      - dev
+      - release
+`)
+
+	t.Run("all", func(t *testing.T) {
+		c, err := ParseCompose([]composetypes.ConfigFile{{Content: dt}}, nil)
+		require.NoError(t, err)
+		require.Len(t, c.Targets, 3)
+		require.Len(t, c.Groups, 3)
+		require.Equal(t, "default", c.Groups[0].Name)
+		require.Equal(t, "dev", c.Groups[1].Name)
+		require.Equal(t, []string{"debug", "docs"}, c.Groups[1].Targets)
+		require.Equal(t, "release", c.Groups[2].Name)
+		require.Equal(t, []string{"docs"}, c.Groups[2].Targets)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		c, err := ParseCompose([]composetypes.ConfigFile{{Content: dt}}, map[string]string{"COMPOSE_PROFILES": "release"})
+		require.NoError(t, err)
+		require.Len(t, c.Targets, 2)
+		sort.Slice(c.Targets, func(i, j int) bool {
+			return c.Targets[i].Name < c.Targets[j].Name
+		})
+		require.Equal(t, "app", c.Targets[0].Name)
+		require.Equal(t, "docs", c.Targets[1].Name)
+		require.Len(t, c.Groups, 2)
+		require.Equal(t, "release", c.Groups[1].Name)
+	})
+}
+
+func TestComposeProfilesTargetClash(t *testing.T) {
+	fps := []File{{
+		Name: "compose.yml",
+		Data: []byte(`
+services:
+  debug:
+    build:
+      context: .
+    profiles:
+      - dev
+  docs:
+    build:
+      context: ./docs
+    profiles:
+      - release
+`),
+	}, {
+		Name: "docker-bake.hcl",
+		Data: []byte(`
+target "dev" {
+  context = "./dev"
+}
+`),
+	}}
+
+	c, _, err := ParseFiles(fps, nil)
+	require.NoError(t, err)
+	var groups []string
+	for _, g := range c.Groups {
+		groups = append(groups, g.Name)
+	}
+	require.Equal(t, []string{"default", "release"}, groups)
+
+	m, _, err := ReadTargets(context.TODO(), fps, []string{"dev"}, nil, nil, &EntitlementConf{})
+	require.NoError(t, err)
+	require.Len(t, m, 1)
+	require.Equal(t, "./dev", *m["dev"].Context)
+}
+
+func TestComposeServiceLinks(t *testing.T) {
+	var dt = []byte(`
+services:
+  base:
+    build:
+      context: ./base
+  tools:
+    build:
+      context: ./tools
+  db:
+    image: postgres
+  app:
+    build:
+      context: .
+      privileged: true
+      additional_contexts:
+        toolchain: service:tools
+        alpine: docker-image://alpine
+    depends_on:
+      - base
+      - db
+`)
+
+	c, err := ParseCompose([]composetypes.ConfigFile{{Content: dt}}, nil)
+	require.NoError(t, err)
+	require.Len(t, c.Targets, 3)
+	sort.Slice(c.Targets, func(i, j int) bool {
+		return c.Targets[i].Name < c.Targets[j].Name
+	})
+	require.Equal(t, "app", c.Targets[0].Name)
+	require.Equal(t, map[string]string{
+		"alpine":          "docker-image://alpine",
+		"depends_on#base": "target:base",
+		"toolchain":       "target:tools",
+	}, c.Targets[0].Contexts)
+	require.Equal(t, []string{"security.insecure"}, c.Targets[0].Entitlements)
+}
+
+func TestComposeDependsOnStage(t *testing.T) {
+	// the Dockerfile of app has a stage named like the service it depends on
+	var dt = []byte(`
+services:
+  base:
+    build:
+      context: ./base
+  app:
+    build:
+      context: .
+      dockerfile_inline: |
+        FROM alpine AS base
+        FROM base
+      additional_contexts:
+        alpine: docker-image://alpine:3.20
+    depends_on:
+      - base
+`)
+
+	c, err := ParseCompose([]composetypes.ConfigFile{{Content: dt}}, nil)
+	require.NoError(t, err)
+	require.Len(t, c.Targets, 2)
+	sort.Slice(c.Targets, func(i, j int) bool {
+		return c.Targets[i].Name < c.Targets[j].Name
+	})
+	require.Equal(t, "app", c.Targets[0].Name)
+	require.Equal(t, map[string]string{
+		"alpine":          "docker-image://alpine:3.20",
+		"depends_on#base": "target:base",
+	}, c.Targets[0].Contexts)
+	require.NotContains(t, c.Targets[0].Contexts, "base")
+}
+
 func TestComposeExtDedup(t *testing.T) {
 	var dt = []byte(`
 services:
@@ -34,6 +34,10 @@ const (
 	EntitlementKeyImageLoad        EntitlementKey = "image.load"
 	EntitlementKeyImage            EntitlementKey = "image"
 	EntitlementKeySSH              EntitlementKey = "ssh"
+	EntitlementKeySecretCmd        EntitlementKey = "secret.cmd"
+	EntitlementKeySecretRegistry   EntitlementKey = "secret.registry"
+	EntitlementKeySecretKeyring    EntitlementKey = "secret.keyring"
+	EntitlementKeyHooks            EntitlementKey = "hooks"
 )
 
 type EntitlementConf struct {
@@ -44,6 +48,23 @@ type EntitlementConf struct {
 	ImagePush        []string
 	ImageLoad        []string
 	SSH              bool
+	SecretCmd        bool
+	SecretRegistry   bool
+	SecretKeyring    bool
+	Hooks            bool
+
+	// granted holds the commands and secret sources set on the command line,
+	// that are allowed without granting the entitlement to the whole build
+	granted []string
+}
+
+// grant allows a value of an entitlement set on the command line.
+func (c *EntitlementConf) grant(key EntitlementKey, value string) {
+	c.granted = append(c.granted, string(key)+"="+value)
+}
+
+func (c EntitlementConf) isGranted(key EntitlementKey, value string) bool {
+	return slices.Contains(c.granted, string(key)+"="+value)
 }
 
 func ParseEntitlements(in []string) (EntitlementConf, error) {
@@ -56,6 +77,14 @@ func ParseEntitlements(in []string) (EntitlementConf, error) {
 			conf.SecurityInsecure = true
 		case string(EntitlementKeySSH):
 			conf.SSH = true
+		case string(EntitlementKeySecretCmd):
+			conf.SecretCmd = true
+		case string(EntitlementKeySecretRegistry):
+			conf.SecretRegistry = true
+		case string(EntitlementKeySecretKeyring):
+			conf.SecretKeyring = true
+		case string(EntitlementKeyHooks):
+			conf.Hooks = true
 		default:
 			k, v, _ := strings.Cut(e, "=")
 			switch k {
@@ -93,6 +122,28 @@ func (c EntitlementConf) Validate(m map[string]build.Options) (EntitlementConf,
 	return expected, nil
 }
 
+// ValidateHooks sets the hooks entitlement of expected if the enabled
+// targets have hooks that are not allowed. Only the hooks set on the command
+// line are allowed without the entitlement.
+func (c EntitlementConf) ValidateHooks(m map[string]*Target, expected *EntitlementConf) {
+	if c.Hooks {
+		return
+	}
+	for _, t := range m {
+		if !t.IsEnabled() {
+			continue
+		}
+		for kind, cmds := range t.Hooks {
+			for _, cmd := range cmds {
+				if !c.isGranted(EntitlementKeyHooks, kind+"="+cmd) {
+					expected.Hooks = true
+					return
+				}
+			}
+		}
+	}
+}
+
 func (c EntitlementConf) check(bo build.Options, expected *EntitlementConf) error {
 	for _, e := range bo.Allow {
 		switch e {
@@ -138,6 +189,24 @@ func (c EntitlementConf) check(bo build.Options, expected *EntitlementConf) erro
 		if secret.FilePath != "" {
 			roPaths[secret.FilePath] = struct{}{}
 		}
+		if secret.Cmd != "" && !c.SecretCmd && !c.isGranted(EntitlementKeySecretCmd, secret.Cmd) {
+			expected.SecretCmd = true
+		}
+		if secret.Registry != "" && !c.SecretRegistry && !c.isGranted(EntitlementKeySecretRegistry, secret.Registry) {
+			expected.SecretRegistry = true
+		}
+		if secret.Keyring != "" && !c.SecretKeyring && !c.isGranted(EntitlementKeySecretKeyring, secret.Keyring) {
+			expected.SecretKeyring = true
+		}
+	}
+
+	for _, a := range bo.RegistryAuthSpecs {
+		if a.FilePath != "" {
+			roPaths[a.FilePath] = struct{}{}
+		}
+		if a.Cmd != "" && !c.SecretCmd && !c.isGranted(EntitlementKeySecretCmd, a.Cmd) {
+			expected.SecretCmd = true
+		}
 	}
 
 	for _, ssh := range bo.SSHSpecs {
@@ -184,6 +253,22 @@ func (c EntitlementConf) Prompt(ctx context.Context, isRemote bool, out io.Write
 		msgs = append(msgs, " - Running privileged containers that can make system changes")
 		flags = append(flags, string(EntitlementKeySecurityInsecure))
 	}
+	if c.SecretCmd {
+		msgs = append(msgs, " - Running local commands to read secrets and registry credentials")
+		flags = append(flags, string(EntitlementKeySecretCmd))
+	}
+	if c.SecretRegistry {
+		msgs = append(msgs, " - Reading registry credentials as build secrets")
+		flags = append(flags, string(EntitlementKeySecretRegistry))
+	}
+	if c.SecretKeyring {
+		msgs = append(msgs, " - Reading build secrets from the system keyring")
+		flags = append(flags, string(EntitlementKeySecretKeyring))
+	}
+	if c.Hooks {
+		msgs = append(msgs, " - Running local commands before and after building targets")
+		flags = append(flags, string(EntitlementKeyHooks))
+	}
 
 	if c.SSH {
 		msgsFS = append(msgsFS, " - Forwarding default SSH agent socket")
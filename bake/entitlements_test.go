@@ -1,6 +1,7 @@
 package bake
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -363,6 +364,103 @@ func TestValidateEntitlements(t *testing.T) {
 			},
 			expected: EntitlementConf{},
 		},
+		{
+			name: "SecretFromCmd",
+			opt: build.Options{
+				SecretSpecs: []*pb.Secret{
+					{
+						Cmd: "pass show npm",
+					},
+				},
+			},
+			conf: EntitlementConf{
+				FSRead: []string{wd},
+			},
+			expected: EntitlementConf{
+				SecretCmd: true,
+			},
+		},
+		{
+			name: "SecretFromCmdAllowed",
+			opt: build.Options{
+				SecretSpecs: []*pb.Secret{
+					{
+						Cmd: "pass show npm",
+					},
+				},
+			},
+			conf: EntitlementConf{
+				FSRead:    []string{wd},
+				SecretCmd: true,
+			},
+			expected: EntitlementConf{},
+		},
+		{
+			name: "SecretFromRegistry",
+			opt: build.Options{
+				SecretSpecs: []*pb.Secret{
+					{
+						ID:       "GITHUB_TOKEN",
+						Registry: "ghcr.io",
+					},
+				},
+			},
+			conf: EntitlementConf{
+				FSRead: []string{wd},
+			},
+			expected: EntitlementConf{
+				SecretRegistry: true,
+			},
+		},
+		{
+			name: "SecretFromRegistryAllowed",
+			opt: build.Options{
+				SecretSpecs: []*pb.Secret{
+					{
+						ID:       "GITHUB_TOKEN",
+						Registry: "ghcr.io",
+					},
+				},
+			},
+			conf: EntitlementConf{
+				FSRead:         []string{wd},
+				SecretRegistry: true,
+			},
+			expected: EntitlementConf{},
+		},
+		{
+			name: "SecretFromKeyring",
+			opt: build.Options{
+				SecretSpecs: []*pb.Secret{
+					{
+						ID:      "npm",
+						Keyring: "npm",
+					},
+				},
+			},
+			conf: EntitlementConf{
+				FSRead: []string{wd},
+			},
+			expected: EntitlementConf{
+				SecretKeyring: true,
+			},
+		},
+		{
+			name: "SecretFromKeyringAllowed",
+			opt: build.Options{
+				SecretSpecs: []*pb.Secret{
+					{
+						ID:      "npm",
+						Keyring: "npm",
+					},
+				},
+			},
+			conf: EntitlementConf{
+				FSRead:        []string{wd},
+				SecretKeyring: true,
+			},
+			expected: EntitlementConf{},
+		},
 		{
 			name: "NonExistingAllowedPathSubpath",
 			opt: build.Options{
@@ -416,6 +514,39 @@ func TestValidateEntitlements(t *testing.T) {
 	}
 }
 
+func TestValidateEntitlementsCommandLine(t *testing.T) {
+	fp := File{
+		Name: "docker-bake.hcl",
+		Data: []byte(`
+target "app" {
+  secret = ["id=npm,cmd=pass show npm", "id=gh,registry=ghcr.io"]
+}
+`),
+	}
+	ctx := context.TODO()
+
+	// the secrets of the file are still not allowed when a secret is appended
+	ent := EntitlementConf{}
+	m, _, err := ReadTargets(ctx, []File{fp}, []string{"app"}, []string{"app.secrets+=id=token,cmd=echo foo"}, nil, &ent)
+	require.NoError(t, err)
+	bo, err := TargetsToBuildOpt(context.TODO(), m, &Input{}, false)
+	require.NoError(t, err)
+	exp, err := ent.Validate(bo)
+	require.NoError(t, err)
+	require.True(t, exp.SecretCmd)
+	require.True(t, exp.SecretRegistry)
+
+	ent = EntitlementConf{}
+	m, _, err = ReadTargets(ctx, []File{fp}, []string{"app"}, []string{"app.secrets=id=token,cmd=echo foo"}, nil, &ent)
+	require.NoError(t, err)
+	bo, err = TargetsToBuildOpt(context.TODO(), m, &Input{}, false)
+	require.NoError(t, err)
+	exp, err = ent.Validate(bo)
+	require.NoError(t, err)
+	require.False(t, exp.SecretCmd)
+	require.False(t, exp.SecretRegistry)
+}
+
 func TestGroupSamePaths(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -0,0 +1,156 @@
+package bake
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/docker/buildx/bake/hclparser"
+)
+
+// Origin is the definition or the override that set the value of a target
+// attribute.
+type Origin struct {
+	// Target is the target the value is inherited from, empty for the
+	// target itself.
+	Target   string `json:"target,omitempty"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Override string `json:"override,omitempty"`
+	Env      string `json:"env,omitempty"`
+}
+
+// mergedAttributes are the attributes whose values are merged with the
+// inherited ones instead of replacing them, see Target.Merge.
+var mergedAttributes = map[string]struct{}{
+	"annotations":     {},
+	"args":            {},
+	"attest":          {},
+	"cache-from":      {},
+	"contexts":        {},
+	"entitlements":    {},
+	"hooks":           {},
+	"labels":          {},
+	"no-cache-filter": {},
+	"registry-auth":   {},
+	"secret":          {},
+	"ssh":             {},
+	"ulimits":         {},
+}
+
+// overrideAttributes maps the override keys that differ from the name of
+// the attribute they set.
+var overrideAttributes = map[string]string{
+	"load":     "output",
+	"platform": "platforms",
+	"push":     "output",
+	"secrets":  "secret",
+}
+
+// origins holds the origins of the values of the attributes of a target.
+type origins map[string][]Origin
+
+// add records the origins of a value of the attribute, that are appended to
+// the previous ones if the values of the attribute are merged.
+func (o origins) add(attr string, v ...Origin) {
+	if _, ok := mergedAttributes[attr]; ok {
+		o[attr] = append(slices.Clone(o[attr]), v...)
+	} else {
+		o[attr] = slices.Clone(v)
+	}
+}
+
+// merge records the origins of the values of a target merged with
+// Target.Merge.
+func (o origins) merge(o2 origins) {
+	for attr, v := range o2 {
+		o.add(attr, v...)
+	}
+}
+
+// inherited returns the origins of the target name, as inherited by another
+// target.
+func (o origins) inherited(name string) origins {
+	res := make(origins, len(o))
+	for attr, v := range o {
+		v2 := make([]Origin, len(v))
+		for i, o := range v {
+			if o.Target == "" {
+				o.Target = name
+			}
+			v2[i] = o
+		}
+		res[attr] = v2
+	}
+	return res
+}
+
+// override records the overrides of the attribute. The overrides also apply
+// to the inherited targets, so their origins are replaced. The values of the
+// same override key, and the ones of list operators, are appended.
+func (o origins) override(attr string, specs []string) {
+	var replaced bool
+	for _, v := range specs {
+		o[attr] = slices.DeleteFunc(slices.Clone(o[attr]), func(o Origin) bool {
+			return o.Override == v
+		})
+		key, _, _ := strings.Cut(v, "=")
+		if _, op := cutOverrideOp(key); op != "" || replaced {
+			o[attr] = append(o[attr], Origin{Override: v})
+		} else {
+			o.add(attr, Origin{Override: v})
+			replaced = true
+		}
+	}
+}
+
+// definitionOrigins returns the origins of the attributes of the HCL
+// definitions of a target, in the order they are merged, with the variable
+// files and the environment variables setting the variables they reference.
+func definitionOrigins(defs []*hclparser.Definition, varSources map[string]string) origins {
+	res := origins{}
+	for _, def := range defs {
+		for attr, rng := range def.Attributes {
+			if attr == "inherits" || attr == "name" {
+				continue
+			}
+			v := []Origin{{File: rng.Filename, Line: rng.Start.Line}}
+			for _, name := range def.Variables[attr] {
+				switch src := varSources[name]; src {
+				case "":
+				case hclparser.VarSourceEnv:
+					v = append(v, Origin{Env: name})
+				default:
+					v = append(v, Origin{File: src})
+				}
+			}
+			res.add(attr, v...)
+		}
+	}
+	return res
+}
+
+// attrName returns the name of the attribute set by the override key.
+func attrName(key string) string {
+	attr, _, _ := strings.Cut(key, ".")
+	if a, ok := overrideAttributes[attr]; ok {
+		return a
+	}
+	return attr
+}
+
+// targetAttributes returns the names of the attributes set on the target.
+func targetAttributes(t *Target) []string {
+	var attrs []string
+	v := reflect.ValueOf(t).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" || name == "inherits" {
+			continue
+		}
+		if !v.Field(i).IsZero() {
+			attrs = append(attrs, name)
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,132 @@
+package bake
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplainTargets(t *testing.T) {
+	fc := File{
+		Name: "compose.yml",
+		Data: []byte(`
+services:
+  app:
+    build:
+      context: ./app
+      args:
+        FOO: bar
+`),
+	}
+	fp := File{
+		Name: "docker-bake.hcl",
+		Data: []byte(`
+variable "EXPLAIN_TAG" {
+  default = "latest"
+}
+
+target "base" {
+  args = {
+    BASE = "1"
+  }
+  platforms = ["linux/amd64"]
+}
+
+target "app" {
+  inherits = ["base"]
+  tags = ["app:${EXPLAIN_TAG}"]
+  platforms = ["linux/arm64"]
+}
+`),
+	}
+	files := []File{fc, fp}
+	overrides := []string{"app.tags=app:foo", "app.tags=app:bar", "*.args.BAR=baz"}
+
+	def, err := ReadDefinition(context.TODO(), files, []string{"app"}, overrides, nil, &EntitlementConf{})
+	require.NoError(t, err)
+	require.Contains(t, def.Origins, "app")
+
+	app := def.Origins["app"]
+	require.Equal(t, []Origin{{File: "compose.yml"}}, app["context"])
+	require.Equal(t, []Origin{
+		{Target: "base", File: "docker-bake.hcl", Line: 7},
+		{File: "compose.yml"},
+		{Override: "*.args.BAR=baz"},
+	}, app["args"])
+	require.Equal(t, []Origin{{File: "docker-bake.hcl", Line: 16}}, app["platforms"])
+	require.Equal(t, []Origin{
+		{Override: "app.tags=app:foo"},
+		{Override: "app.tags=app:bar"},
+	}, app["tags"])
+}
+
+func TestExplainTargetsEnv(t *testing.T) {
+	t.Setenv("EXPLAIN_TAG", "v1")
+
+	fp := File{
+		Name: "docker-bake.hcl",
+		Data: []byte(`
+variable "EXPLAIN_TAG" {
+  default = "latest"
+}
+
+target "app" {
+  tags = ["app:${EXPLAIN_TAG}"]
+}
+`),
+	}
+
+	def, err := ReadDefinition(context.TODO(), []File{fp}, []string{"app"}, nil, nil, &EntitlementConf{})
+	require.NoError(t, err)
+	require.Equal(t, []Origin{
+		{File: "docker-bake.hcl", Line: 7},
+		{Env: "EXPLAIN_TAG"},
+	}, def.Origins["app"]["tags"])
+}
+
+// TestMergedAttributes checks that mergedAttributes lists the attributes
+// whose values are merged by Target.Merge.
+func TestMergedAttributes(t *testing.T) {
+	value := func(typ reflect.Type, s string) reflect.Value {
+		switch typ {
+		case reflect.TypeOf(""):
+			return reflect.ValueOf(s)
+		case reflect.TypeOf(ptrstr("")):
+			return reflect.ValueOf(ptrstr(s))
+		case reflect.TypeOf(new(bool)):
+			b := s == "a"
+			return reflect.ValueOf(&b)
+		case reflect.TypeOf([]string{}):
+			return reflect.ValueOf([]string{"type=" + s})
+		case reflect.TypeOf(map[string]string{}):
+			return reflect.ValueOf(map[string]string{s: s})
+		case reflect.TypeOf(map[string]*string{}):
+			return reflect.ValueOf(map[string]*string{s: ptrstr(s)})
+		case reflect.TypeOf(map[string][]string{}):
+			return reflect.ValueOf(map[string][]string{s: {s}})
+		}
+		require.FailNow(t, "unsupported field type", "%s", typ)
+		return reflect.Value{}
+	}
+
+	typ := reflect.TypeOf(Target{})
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "" || name == "-" || name == "inherits" {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			t1, t2 := &Target{}, &Target{}
+			reflect.ValueOf(t1).Elem().Field(i).Set(value(f.Type, "a"))
+			reflect.ValueOf(t2).Elem().Field(i).Set(value(f.Type, "b"))
+			t1.Merge(t2)
+			merged := !reflect.DeepEqual(reflect.ValueOf(t1).Elem().Field(i).Interface(), reflect.ValueOf(t2).Elem().Field(i).Interface())
+			_, ok := mergedAttributes[name]
+			require.Equal(t, merged, ok, "mergedAttributes disagrees with Target.Merge for %s", name)
+		})
+	}
+}
@@ -1,9 +1,12 @@
 package bake
 
 import (
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
+	"github.com/docker/buildx/util/gitutil"
 	"github.com/stretchr/testify/require"
 )
 
@@ -1486,3 +1489,67 @@ func ptrstr(s interface{}) *string {
 	}
 	return n
 }
+
+func TestHCLGitFunctions(t *testing.T) {
+	dir := t.TempDir()
+	gitc, err := gitutil.New(gitutil.WithWorkingDir(dir))
+	require.NoError(t, err)
+	gitutil.GitInit(gitc, t)
+	gitutil.GitCommit(gitc, t, "initial commit")
+	gitutil.GitTag(gitc, t, "v1.2.0")
+
+	sha, err := gitc.FullCommit()
+	require.NoError(t, err)
+
+	dt := []byte(`
+		target "app" {
+			tags = docker_meta_tags("app")
+			args = {
+				GIT_SHA = git_sha()
+				GIT_TAG = git_tag()
+				GIT_BRANCH = git_branch()
+				GIT_DIRTY = git_dirty()
+				NEXT_VERSION = semver_bump(git_tag(), "minor")
+			}
+		}
+		`)
+
+	c, err := ParseFile(dt, filepath.Join(dir, "docker-bake.hcl"))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(c.Targets))
+	require.Equal(t, []string{"app:sha-" + sha[:7], "app:main", "app:1.2.0", "app:1.2", "app:1", "app:latest"}, c.Targets[0].Tags)
+	require.Equal(t, map[string]*string{
+		"GIT_SHA":      ptrstr(sha),
+		"GIT_TAG":      ptrstr("v1.2.0"),
+		"GIT_BRANCH":   ptrstr("main"),
+		"GIT_DIRTY":    ptrstr("false"),
+		"NEXT_VERSION": ptrstr("v1.3.0"),
+	}, c.Targets[0].Args)
+
+	// ignored files don't make the repository dirty
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("ignored\n"), 0644))
+	gitutil.GitAdd(gitc, t, ".gitignore")
+	gitutil.GitCommit(gitc, t, "ignore")
+	gitutil.GitTag(gitc, t, "v1.2.1")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ignored"), []byte("foo"), 0644))
+	c, err = ParseFile(dt, filepath.Join(dir, "docker-bake.hcl"))
+	require.NoError(t, err)
+	require.Equal(t, ptrstr("false"), c.Targets[0].Args["GIT_DIRTY"])
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "untracked"), []byte("foo"), 0644))
+	c, err = ParseFile(dt, filepath.Join(dir, "docker-bake.hcl"))
+	require.NoError(t, err)
+	require.Equal(t, ptrstr("true"), c.Targets[0].Args["GIT_DIRTY"])
+}
+
+func TestHCLGitFunctionsRemote(t *testing.T) {
+	dt := []byte(`
+		target "app" {
+			args = {
+				GIT_SHA = git_sha()
+			}
+		}
+		`)
+	_, _, err := ParseFiles([]File{{Name: "docker-bake.hcl", Data: dt, remote: "https://github.com/docker/buildx.git"}}, nil)
+	require.ErrorContains(t, err, "git functions are not supported in the Bake files of remote source https://github.com/docker/buildx.git")
+}
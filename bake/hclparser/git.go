@@ -0,0 +1,182 @@
+package hclparser
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/docker/buildx/util/gitutil"
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+type gitMeta struct {
+	sha    string
+	tag    string
+	branch string
+	dirty  bool
+}
+
+// gitFunctions returns the functions reading the metadata of the Git
+// repository of the directory dir. The metadata is read once, when one of
+// the functions is first called, and is empty outside a repository. The
+// functions return an error for the files of a remote source, that are not
+// in a local repository.
+func gitFunctions(dir string, remoteSource string) map[string]function.Function {
+	meta := sync.OnceValues(func() (gitMeta, error) {
+		if remoteSource != "" {
+			return gitMeta{}, errors.Errorf("git functions are not supported in the Bake files of remote source %s", remoteSource)
+		}
+		return readGitMeta(dir)
+	})
+	return map[string]function.Function{
+		"docker_meta_tags": dockerMetaTagsFunc(meta),
+		"git_branch": gitStringFunc(meta, func(m gitMeta) string {
+			return m.branch
+		}),
+		"git_dirty": function.New(&function.Spec{
+			Type: function.StaticReturnType(cty.Bool),
+			Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+				m, err := meta()
+				if err != nil {
+					return cty.NilVal, err
+				}
+				return cty.BoolVal(m.dirty), nil
+			},
+		}),
+		"git_sha": gitStringFunc(meta, func(m gitMeta) string {
+			return m.sha
+		}),
+		"git_tag": gitStringFunc(meta, func(m gitMeta) string {
+			return m.tag
+		}),
+	}
+}
+
+func readGitMeta(dir string) (gitMeta, error) {
+	var m gitMeta
+	gitc, err := gitutil.New(gitutil.WithWorkingDir(dir))
+	if err != nil {
+		return m, err
+	}
+	if !gitc.IsInsideWorkTree() {
+		return m, nil
+	}
+	if m.sha, err = gitc.FullCommit(); err != nil {
+		if gitutil.IsUnknownRevision(err) {
+			// no commit yet
+			return m, nil
+		}
+		return m, err
+	}
+	if m.tag, err = gitc.TagAtHead(); err != nil {
+		return m, err
+	}
+	if m.branch, err = gitc.Branch(); err != nil {
+		return m, err
+	}
+	if m.dirty, err = gitc.HasChanges(); err != nil {
+		return m, err
+	}
+	return m, nil
+}
+
+func gitStringFunc(meta func() (gitMeta, error), fn func(gitMeta) string) function.Function {
+	return function.New(&function.Spec{
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			m, err := meta()
+			if err != nil {
+				return cty.NilVal, err
+			}
+			return cty.StringVal(fn(m)), nil
+		},
+	})
+}
+
+// dockerMetaTagsFunc constructs a function that returns the tags of an image
+// for the Git metadata: the short commit, the branch, and for a semantic
+// version tag, the version, the major and minor versions and latest.
+func dockerMetaTagsFunc(meta func() (gitMeta, error)) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name: "image",
+				Type: cty.String,
+			},
+		},
+		Type: function.StaticReturnType(cty.List(cty.String)),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			m, err := meta()
+			if err != nil {
+				return cty.NilVal, err
+			}
+			image := args[0].AsString()
+
+			var tags []string
+			add := func(tag string) {
+				tag = image + ":" + sanitizeTag(tag)
+				if !slices.Contains(tags, tag) {
+					tags = append(tags, tag)
+				}
+			}
+			if len(m.sha) >= 7 {
+				add("sha-" + m.sha[:7])
+			}
+			if m.branch != "" {
+				add(m.branch)
+			}
+			if m.tag != "" {
+				if v, err := semver.NewVersion(m.tag); err == nil {
+					add(v.String())
+					if v.Prerelease() == "" {
+						add(fmt.Sprintf("%d.%d", v.Major(), v.Minor()))
+						if v.Major() > 0 {
+							add(fmt.Sprintf("%d", v.Major()))
+						}
+						add("latest")
+					}
+				} else {
+					add(m.tag)
+				}
+			}
+
+			if len(tags) == 0 {
+				return cty.ListValEmpty(cty.String), nil
+			}
+			vals := make([]cty.Value, len(tags))
+			for i, tag := range tags {
+				vals[i] = cty.StringVal(tag)
+			}
+			return cty.ListVal(vals), nil
+		},
+	})
+}
+
+// sanitizeTag replaces the characters that are not valid in an image tag,
+// such as the slashes of branch names, and truncates the tag to 128
+// characters.
+func sanitizeTag(tag string) string {
+	var b strings.Builder
+	for i, r := range tag {
+		switch {
+		case r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_':
+			b.WriteRune(r)
+		case i == 0:
+			// tags can't start with a dot or a dash
+			b.WriteRune('_')
+		case r == '.':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('-')
+		}
+	}
+	tag = b.String()
+	if len(tag) > 128 {
+		tag = tag[:128]
+	}
+	return tag
+}
@@ -0,0 +1,55 @@
+package hclparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestDockerMetaTags(t *testing.T) {
+	const sha = "8b6c2f1d4f0e6d43a6e0f4d2c8f8a2e5d8a0b1c2"
+	type testCase struct {
+		meta gitMeta
+		want []string
+	}
+	tests := map[string]testCase{
+		"branch": {
+			meta: gitMeta{sha: sha, branch: "feature/foo"},
+			want: []string{"app:sha-8b6c2f1", "app:feature-foo"},
+		},
+		"version": {
+			meta: gitMeta{sha: sha, tag: "v1.2.3"},
+			want: []string{"app:sha-8b6c2f1", "app:1.2.3", "app:1.2", "app:1", "app:latest"},
+		},
+		"prerelease": {
+			meta: gitMeta{sha: sha, tag: "v1.2.3-rc.1"},
+			want: []string{"app:sha-8b6c2f1", "app:1.2.3-rc.1"},
+		},
+		"major zero": {
+			meta: gitMeta{sha: sha, tag: "v0.4.0", branch: "main"},
+			want: []string{"app:sha-8b6c2f1", "app:main", "app:0.4.0", "app:0.4", "app:latest"},
+		},
+		"not semver": {
+			meta: gitMeta{sha: sha, tag: "nightly"},
+			want: []string{"app:sha-8b6c2f1", "app:nightly"},
+		},
+		"no repository": {},
+	}
+
+	for name, test := range tests {
+		name, test := name, test
+		t.Run(name, func(t *testing.T) {
+			fn := dockerMetaTagsFunc(func() (gitMeta, error) {
+				return test.meta, nil
+			})
+			got, err := fn.Call([]cty.Value{cty.StringVal("app")})
+			require.NoError(t, err)
+			var tags []string
+			for _, v := range got.AsValueSlice() {
+				tags = append(tags, v.AsString())
+			}
+			require.Equal(t, test.want, tags)
+		})
+	}
+}
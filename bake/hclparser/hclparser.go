@@ -7,6 +7,7 @@ import (
 	"math"
 	"math/big"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -15,6 +16,8 @@ import (
 	"github.com/hashicorp/hcl/v2/gohcl"
 	"github.com/pkg/errors"
 	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
 	"github.com/zclconf/go-cty/cty/gocty"
 )
 
@@ -22,8 +25,41 @@ type Opt struct {
 	LookupVar     func(string) (string, bool)
 	Vars          map[string]string
 	ValidateLabel func(string) error
+	// WorkingDir is the directory of the Git repository read by the git
+	// functions, the current working directory if empty.
+	WorkingDir string
+	// RemoteSource is the remote source of the files, if any, for which the
+	// git functions return an error.
+	RemoteSource string
+	// VarValues holds the values of variables read from variable files,
+	// that take precedence over the ones of LookupVar.
+	VarValues map[string]VarValue
+	// NamespaceBlock is the type of the blocks defining namespaces. The
+	// variables and functions of a namespace are referenced with the label
+	// of its block, as <name>.<variable> and <name>::<function>().
+	NamespaceBlock string
+	// LoadNamespace returns the namespace defined by a block of type
+	// NamespaceBlock, whose attributes are evaluated with ectx. It is called
+	// the first time the namespace is referenced.
+	LoadNamespace func(block *hcl.Block, ectx *hcl.EvalContext) (*Namespace, error)
 }
 
+// Namespace holds the variables and functions of a namespace.
+type Namespace struct {
+	Variables map[string]cty.Value
+	Functions map[string]function.Function
+}
+
+// VarValue is the value of a variable set in a variable file.
+type VarValue struct {
+	Value cty.Value
+	// File is the name of the variable file.
+	File string
+}
+
+// VarSourceEnv is the source of the variables set by LookupVar.
+const VarSourceEnv = "env"
+
 type variable struct {
 	Name        string                `json:"-" hcl:"name,label"`
 	Default     *hcl.Attribute        `json:"default,omitempty" hcl:"default,optional"`
@@ -55,9 +91,11 @@ type inputs struct {
 type parser struct {
 	opt Opt
 
-	vars  map[string]*variable
-	attrs map[string]*hcl.Attribute
-	funcs map[string]*functionDef
+	vars       map[string]*variable
+	varSources map[string]string
+	attrs      map[string]*hcl.Attribute
+	funcs      map[string]*functionDef
+	namespaces map[string]*hcl.Block
 
 	blocks       map[string]map[string][]*hcl.Block
 	blockValues  map[*hcl.Block][]reflect.Value
@@ -69,6 +107,7 @@ type parser struct {
 
 	progressV map[uint64]struct{}
 	progressF map[uint64]struct{}
+	progressN map[string]struct{}
 	progressB map[uint64]map[string]struct{}
 	doneB     map[uint64]map[string]struct{}
 }
@@ -180,6 +219,13 @@ func (p *parser) resolveFunction(ectx *hcl.EvalContext, name string) error {
 	}
 	f, ok := p.funcs[name]
 	if !ok {
+		if ns, _, ok := strings.Cut(name, "::"); ok {
+			if ok, err := p.resolveNamespace(ns); err != nil {
+				return err
+			} else if _, defined := p.ectx.Functions[name]; ok && defined {
+				return nil
+			}
+		}
 		return errors.Wrapf(errUndefined{}, "function %q does not exist", name)
 	}
 	if _, ok := p.progressF[key(ectx, name)]; ok {
@@ -245,6 +291,46 @@ func (p *parser) resolveFunction(ectx *hcl.EvalContext, name string) error {
 	return nil
 }
 
+// varValue converts the value of a variable set in the environment or in a
+// variable file to the type of its default value. Strings are parsed for
+// booleans and numbers.
+func varValue(name string, v cty.Value, typ cty.Type) (cty.Value, error) {
+	if !typ.IsPrimitiveType() {
+		if v.Type().Equals(cty.String) && !typ.Equals(cty.DynamicPseudoType) {
+			// TODO: support lists with csv values
+			return cty.NilVal, errors.Errorf("unsupported type %s for variable %s", typ.FriendlyName(), name)
+		}
+		return v, nil
+	}
+	if !v.Type().Equals(cty.String) || v.IsNull() || !v.IsKnown() {
+		vv, err := convert.Convert(v, typ)
+		if err != nil {
+			return cty.NilVal, errors.Wrapf(err, "invalid value for variable %s", name)
+		}
+		return vv, nil
+	}
+	s := v.AsString()
+	switch {
+	case typ.Equals(cty.Bool):
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return cty.NilVal, errors.Wrapf(err, "failed to parse %s as bool", name)
+		}
+		return cty.BoolVal(b), nil
+	case typ.Equals(cty.Number):
+		n, err := strconv.ParseFloat(s, 64)
+		if err == nil && (math.IsNaN(n) || math.IsInf(n, 0)) {
+			err = errors.Errorf("invalid number value")
+		}
+		if err != nil {
+			return cty.NilVal, errors.Wrapf(err, "failed to parse %s as number", name)
+		}
+		return cty.NumberVal(big.NewFloat(n)), nil
+	default:
+		return v, nil
+	}
+}
+
 // resolveValue forces evaluation of a named value, storing the result into the
 // parser.
 func (p *parser) resolveValue(ectx *hcl.EvalContext, name string) (err error) {
@@ -270,6 +356,9 @@ func (p *parser) resolveValue(ectx *hcl.EvalContext, name string) (err error) {
 	if _, builtin := p.opt.Vars[name]; !ok && !builtin {
 		vr, ok := p.vars[name]
 		if !ok {
+			if ok, err := p.resolveNamespace(name); ok {
+				return err
+			}
 			return errors.Wrapf(errUndefined{}, "variable %q does not exist", name)
 		}
 		def = vr.Default
@@ -279,7 +368,14 @@ func (p *parser) resolveValue(ectx *hcl.EvalContext, name string) (err error) {
 	if def == nil {
 		val, ok := p.opt.Vars[name]
 		if !ok {
-			val, _ = p.opt.LookupVar(name)
+			if vf, ok := p.opt.VarValues[name]; ok {
+				p.varSources[name] = vf.File
+				v = &vf.Value
+				return
+			}
+			if val, ok = p.opt.LookupVar(name); ok {
+				p.varSources[name] = VarSourceEnv
+			}
 		}
 		vv := cty.StringVal(val)
 		v = &vv
@@ -296,34 +392,57 @@ func (p *parser) resolveValue(ectx *hcl.EvalContext, name string) (err error) {
 
 	_, isVar := p.vars[name]
 
-	if envv, ok := p.opt.LookupVar(name); ok && isVar {
-		switch {
-		case vv.Type().Equals(cty.Bool):
-			b, err := strconv.ParseBool(envv)
-			if err != nil {
-				return errors.Wrapf(err, "failed to parse %s as bool", name)
-			}
-			vv = cty.BoolVal(b)
-		case vv.Type().Equals(cty.String), vv.Type().Equals(cty.DynamicPseudoType):
-			vv = cty.StringVal(envv)
-		case vv.Type().Equals(cty.Number):
-			n, err := strconv.ParseFloat(envv, 64)
-			if err == nil && (math.IsNaN(n) || math.IsInf(n, 0)) {
-				err = errors.Errorf("invalid number value")
-			}
-			if err != nil {
-				return errors.Wrapf(err, "failed to parse %s as number", name)
-			}
-			vv = cty.NumberVal(big.NewFloat(n))
-		default:
-			// TODO: support lists with csv values
-			return errors.Errorf("unsupported type %s for variable %s", vv.Type().FriendlyName(), name)
+	if vf, ok := p.opt.VarValues[name]; ok && isVar {
+		if vv, err = varValue(name, vf.Value, vv.Type()); err != nil {
+			return err
+		}
+		p.varSources[name] = vf.File
+	} else if envv, ok := p.opt.LookupVar(name); ok && isVar {
+		if vv, err = varValue(name, cty.StringVal(envv), vv.Type()); err != nil {
+			return err
 		}
+		p.varSources[name] = VarSourceEnv
 	}
 	v = &vv
 	return nil
 }
 
+// resolveNamespace forces loading of the namespace name, storing its
+// variables and functions into the parser. It returns false if no block
+// defines the namespace.
+func (p *parser) resolveNamespace(name string) (bool, error) {
+	block, ok := p.namespaces[name]
+	if !ok {
+		return false, nil
+	}
+	if _, ok := p.ectx.Variables[name]; ok {
+		return true, nil
+	}
+	if _, ok := p.progressN[name]; ok {
+		return true, errors.Errorf("namespace cycle not allowed for %s", name)
+	}
+	p.progressN[name] = struct{}{}
+
+	attrs, diags := block.Body.JustAttributes()
+	if diags.HasErrors() {
+		return true, diags
+	}
+	for _, attr := range attrs {
+		if diags := p.loadDeps(p.ectx, attr.Expr, nil, false); diags.HasErrors() {
+			return true, diags
+		}
+	}
+	ns, err := p.opt.LoadNamespace(block, p.ectx)
+	if err != nil {
+		return true, err
+	}
+	p.ectx.Variables[name] = cty.ObjectVal(ns.Variables)
+	for fn, f := range ns.Functions {
+		p.ectx.Functions[name+"::"+fn] = f
+	}
+	return true, nil
+}
+
 // resolveBlock force evaluates a block, storing the result in the parser. If a
 // target schema is provided, only the attributes and blocks present in the
 // schema will be evaluated.
@@ -578,11 +697,28 @@ type Variable struct {
 	Name        string
 	Description string
 	Value       *string
+	// Source is the variable file or the environment (VarSourceEnv) that
+	// set the value, empty for the default value.
+	Source string
 }
 
 type ParseMeta struct {
 	Renamed      map[string]map[string][]string
 	AllVariables []*Variable
+	// Namespace holds the variables and functions defined by the files, to
+	// be referenced by the files importing them.
+	Namespace Namespace
+	// Definitions holds the definitions of the blocks by type and name, in
+	// the order they are merged.
+	Definitions map[string]map[string][]*Definition
+}
+
+// Definition is the location of a block definition and of its attributes.
+type Definition struct {
+	Range      hcl.Range
+	Attributes map[string]hcl.Range
+	// Variables holds the variables referenced by each attribute.
+	Variables map[string][]string
 }
 
 func Parse(b hcl.Body, opt Opt, val interface{}) (*ParseMeta, hcl.Diagnostics) {
@@ -617,9 +753,11 @@ func Parse(b hcl.Body, opt Opt, val interface{}) (*ParseMeta, hcl.Diagnostics) {
 	p := &parser{
 		opt: opt,
 
-		vars:  map[string]*variable{},
-		attrs: map[string]*hcl.Attribute{},
-		funcs: map[string]*functionDef{},
+		vars:       map[string]*variable{},
+		varSources: map[string]string{},
+		attrs:      map[string]*hcl.Attribute{},
+		funcs:      map[string]*functionDef{},
+		namespaces: map[string]*hcl.Block{},
 
 		blocks:       map[string]map[string][]*hcl.Block{},
 		blockValues:  map[*hcl.Block][]reflect.Value{},
@@ -633,9 +771,13 @@ func Parse(b hcl.Body, opt Opt, val interface{}) (*ParseMeta, hcl.Diagnostics) {
 
 		progressV: map[uint64]struct{}{},
 		progressF: map[uint64]struct{}{},
+		progressN: map[string]struct{}{},
 		progressB: map[uint64]map[string]struct{}{},
 		doneB:     map[uint64]map[string]struct{}{},
 	}
+	for name, fn := range gitFunctions(opt.WorkingDir, opt.RemoteSource) {
+		p.ectx.Functions[name] = fn
+	}
 
 	for _, v := range defs.Variables {
 		// TODO: validate name
@@ -656,6 +798,13 @@ func Parse(b hcl.Body, opt Opt, val interface{}) (*ParseMeta, hcl.Diagnostics) {
 	if diags.HasErrors() {
 		return nil, diags
 	}
+	if opt.NamespaceBlock != "" && opt.LoadNamespace != nil {
+		for _, b := range content.Blocks {
+			if b.Type == opt.NamespaceBlock && len(b.Labels) == 1 {
+				p.namespaces[b.Labels[0]] = b
+			}
+		}
+	}
 
 	blocks, b, diags := b.PartialContent(defsSchema)
 	if diags.HasErrors() {
@@ -706,6 +855,7 @@ func Parse(b hcl.Body, opt Opt, val interface{}) (*ParseMeta, hcl.Diagnostics) {
 		v := &Variable{
 			Name:        p.vars[k].Name,
 			Description: p.vars[k].Description,
+			Source:      p.varSources[k],
 		}
 		if vv := p.ectx.Variables[k]; !vv.IsNull() {
 			var s string
@@ -772,6 +922,7 @@ func Parse(b hcl.Body, opt Opt, val interface{}) (*ParseMeta, hcl.Diagnostics) {
 	}
 
 	tmpBlocks := map[string]map[string][]*hcl.Block{}
+	definitions := map[string]map[string][]*Definition{}
 	for _, b := range content.Blocks {
 		if len(b.Labels) == 0 || len(b.Labels) > 1 {
 			return nil, hcl.Diagnostics{
@@ -795,9 +946,14 @@ func Parse(b hcl.Body, opt Opt, val interface{}) (*ParseMeta, hcl.Diagnostics) {
 		if err != nil {
 			return nil, wrapErrorDiagnostic("Invalid name", err, &b.LabelRanges[0], &b.LabelRanges[0])
 		}
+		def := p.definition(b)
+		if _, ok := definitions[b.Type]; !ok {
+			definitions[b.Type] = map[string][]*Definition{}
+		}
 		for _, name := range names {
 			bm[name] = append(bm[name], b)
 			renamed[b.Type][b.Labels[0]] = append(renamed[b.Type][b.Labels[0]], name)
+			definitions[b.Type][name] = append(definitions[b.Type][name], def)
 		}
 	}
 	p.blocks = tmpBlocks
@@ -865,12 +1021,51 @@ func Parse(b hcl.Body, opt Opt, val interface{}) (*ParseMeta, hcl.Diagnostics) {
 		}
 	}
 
+	ns := Namespace{
+		Variables: map[string]cty.Value{},
+		Functions: map[string]function.Function{},
+	}
+	for k := range p.vars {
+		ns.Variables[k] = p.ectx.Variables[k]
+	}
+	for k := range p.attrs {
+		ns.Variables[k] = p.ectx.Variables[k]
+	}
+	for k := range p.funcs {
+		ns.Functions[k] = p.ectx.Functions[k]
+	}
+
 	return &ParseMeta{
 		Renamed:      renamed,
 		AllVariables: vars,
+		Namespace:    ns,
+		Definitions:  definitions,
 	}, nil
 }
 
+// definition returns the location of the block and of its attributes.
+func (p *parser) definition(block *hcl.Block) *Definition {
+	def := &Definition{
+		Range:      block.DefRange,
+		Attributes: map[string]hcl.Range{},
+		Variables:  map[string][]string{},
+	}
+	schema, _ := gohcl.ImpliedBodySchema(reflect.New(p.blockTypes[block.Type]).Interface())
+	content, _, _ := block.Body.PartialContent(schema)
+	if content == nil {
+		return def
+	}
+	for name, a := range content.Attributes {
+		def.Attributes[name] = a.Range
+		for _, v := range a.Expr.Variables() {
+			if _, ok := p.vars[v.RootName()]; ok && !slices.Contains(def.Variables[name], v.RootName()) {
+				def.Variables[name] = append(def.Variables[name], v.RootName())
+			}
+		}
+	}
+	return def
+}
+
 // wrapErrorDiagnostic wraps an error into a hcl.Diagnostics object.
 // If the error is already an hcl.Diagnostics object, it is returned as is.
 func wrapErrorDiagnostic(message string, err error, subject *hcl.Range, context *hcl.Range) hcl.Diagnostics {
@@ -2,10 +2,12 @@ package hclparser
 
 import (
 	"errors"
+	"fmt"
 	"path"
 	"strings"
 	"time"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/hashicorp/go-cty-funcs/cidr"
 	"github.com/hashicorp/go-cty-funcs/crypto"
 	"github.com/hashicorp/go-cty-funcs/encoding"
@@ -96,6 +98,9 @@ var stdlibFunctions = []funcDef{
 	{name: "reverselist", fn: stdlib.ReverseListFunc},
 	{name: "rsadecrypt", fn: crypto.RsaDecryptFunc},
 	{name: "sanitize", factory: sanitizeFunc},
+	{name: "semver_bump", factory: semverBumpFunc},
+	{name: "semver_compare", factory: semverCompareFunc},
+	{name: "semver_parse", factory: semverParseFunc},
 	{name: "sethaselement", fn: stdlib.SetHasElementFunc},
 	{name: "setintersection", fn: stdlib.SetIntersectionFunc},
 	{name: "setproduct", fn: stdlib.SetProductFunc},
@@ -235,6 +240,107 @@ func sanitizeFunc() function.Function {
 	})
 }
 
+// semverParseFunc constructs a function that returns the components of a
+// semantic version.
+func semverParseFunc() function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name: "version",
+				Type: cty.String,
+			},
+		},
+		Type: function.StaticReturnType(cty.Object(map[string]cty.Type{
+			"major":      cty.Number,
+			"minor":      cty.Number,
+			"patch":      cty.Number,
+			"prerelease": cty.String,
+			"metadata":   cty.String,
+			"version":    cty.String,
+		})),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			v, err := semver.NewVersion(args[0].AsString())
+			if err != nil {
+				return cty.NilVal, function.NewArgError(0, err)
+			}
+			return cty.ObjectVal(map[string]cty.Value{
+				"major":      cty.NumberUIntVal(v.Major()),
+				"minor":      cty.NumberUIntVal(v.Minor()),
+				"patch":      cty.NumberUIntVal(v.Patch()),
+				"prerelease": cty.StringVal(v.Prerelease()),
+				"metadata":   cty.StringVal(v.Metadata()),
+				"version":    cty.StringVal(v.String()),
+			}), nil
+		},
+	})
+}
+
+// semverBumpFunc constructs a function that increments the major, minor or
+// patch component of a semantic version, keeping its v prefix.
+func semverBumpFunc() function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name: "version",
+				Type: cty.String,
+			},
+			{
+				Name: "component",
+				Type: cty.String,
+			},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			v, err := semver.NewVersion(args[0].AsString())
+			if err != nil {
+				return cty.NilVal, function.NewArgError(0, err)
+			}
+			var next semver.Version
+			switch c := args[1].AsString(); c {
+			case "major":
+				next = v.IncMajor()
+			case "minor":
+				next = v.IncMinor()
+			case "patch":
+				next = v.IncPatch()
+			default:
+				return cty.NilVal, function.NewArgError(1, fmt.Errorf("invalid component %q, expected major, minor or patch", c))
+			}
+			return cty.StringVal(next.Original()), nil
+		},
+	})
+}
+
+// semverCompareFunc constructs a function that compares two semantic
+// versions, returning -1, 0 or 1 if the first one is lower, equal or greater
+// than the second one.
+func semverCompareFunc() function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name: "v1",
+				Type: cty.String,
+			},
+			{
+				Name: "v2",
+				Type: cty.String,
+			},
+		},
+		Type: function.StaticReturnType(cty.Number),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			v1, err := semver.NewVersion(args[0].AsString())
+			if err != nil {
+				return cty.NilVal, function.NewArgError(0, err)
+			}
+			v2, err := semver.NewVersion(args[1].AsString())
+			if err != nil {
+				return cty.NilVal, function.NewArgError(1, err)
+			}
+			return cty.NumberIntVal(int64(v1.Compare(v2))), nil
+		},
+	})
+}
+
 // timestampFunc constructs a function that returns a string representation of the current date and time.
 //
 // This function was imported from terraform's datetime utilities.
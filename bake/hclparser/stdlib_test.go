@@ -197,3 +197,97 @@ func TestSanitize(t *testing.T) {
 		})
 	}
 }
+
+func TestSemverParse(t *testing.T) {
+	got, err := semverParseFunc().Call([]cty.Value{cty.StringVal("v1.2.3-rc.1+build.5")})
+	require.NoError(t, err)
+	require.Equal(t, cty.ObjectVal(map[string]cty.Value{
+		"major":      cty.NumberUIntVal(1),
+		"minor":      cty.NumberUIntVal(2),
+		"patch":      cty.NumberUIntVal(3),
+		"prerelease": cty.StringVal("rc.1"),
+		"metadata":   cty.StringVal("build.5"),
+		"version":    cty.StringVal("1.2.3-rc.1+build.5"),
+	}), got)
+
+	_, err = semverParseFunc().Call([]cty.Value{cty.StringVal("latest")})
+	require.Error(t, err)
+}
+
+func TestSemverBump(t *testing.T) {
+	type testCase struct {
+		version   string
+		component string
+		want      string
+		wantErr   bool
+	}
+	tests := map[string]testCase{
+		"major": {
+			version:   "v1.2.3",
+			component: "major",
+			want:      "v2.0.0",
+		},
+		"minor": {
+			version:   "1.2.3",
+			component: "minor",
+			want:      "1.3.0",
+		},
+		"patch prerelease": {
+			version:   "v1.2.3-rc.1",
+			component: "patch",
+			want:      "v1.2.3",
+		},
+		"invalid component": {
+			version:   "v1.2.3",
+			component: "build",
+			wantErr:   true,
+		},
+	}
+
+	for name, test := range tests {
+		name, test := name, test
+		t.Run(name, func(t *testing.T) {
+			got, err := semverBumpFunc().Call([]cty.Value{cty.StringVal(test.version), cty.StringVal(test.component)})
+			if test.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, cty.StringVal(test.want), got)
+			}
+		})
+	}
+}
+
+func TestSemverCompare(t *testing.T) {
+	type testCase struct {
+		v1   string
+		v2   string
+		want int64
+	}
+	tests := map[string]testCase{
+		"lower": {
+			v1:   "v1.2.3",
+			v2:   "v1.10.0",
+			want: -1,
+		},
+		"equal": {
+			v1:   "v1.2.3",
+			v2:   "1.2.3",
+			want: 0,
+		},
+		"prerelease": {
+			v1:   "v1.2.3",
+			v2:   "v1.2.3-rc.1",
+			want: 1,
+		},
+	}
+
+	for name, test := range tests {
+		name, test := name, test
+		t.Run(name, func(t *testing.T) {
+			got, err := semverCompareFunc().Call([]cty.Value{cty.StringVal(test.v1), cty.StringVal(test.v2)})
+			require.NoError(t, err)
+			require.Equal(t, cty.NumberIntVal(test.want), got)
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package bake
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/docker/buildx/util/progress"
+	"github.com/google/shlex"
+	"github.com/pkg/errors"
+)
+
+const (
+	hookPre  = "pre"
+	hookPost = "post"
+)
+
+func (t *Target) validateHooks() error {
+	for k := range t.Hooks {
+		if k != hookPre && k != hookPost {
+			return errors.Errorf("invalid hook %q for target %s, expected %s or %s", k, t.Name, hookPre, hookPost)
+		}
+	}
+	return nil
+}
+
+// RunPreHooks runs the pre hooks of the enabled targets, before they are
+// built.
+func RunPreHooks(ctx context.Context, m map[string]*Target, pw progress.Writer) error {
+	return runHooks(ctx, hookPre, m, nil, pw)
+}
+
+// RunPostHooks runs the post hooks of the built targets. metadata holds the
+// metadata of the build result of each target, as written with
+// --metadata-file.
+func RunPostHooks(ctx context.Context, m map[string]*Target, metadata map[string]map[string]any, pw progress.Writer) error {
+	return runHooks(ctx, hookPost, m, metadata, pw)
+}
+
+func runHooks(ctx context.Context, kind string, m map[string]*Target, metadata map[string]map[string]any, pw progress.Writer) error {
+	names := make([]string, 0, len(m))
+	for name, t := range m {
+		if t.IsEnabled() && len(t.Hooks[kind]) > 0 {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		if kind == hookPost {
+			if _, ok := metadata[name]; !ok {
+				continue
+			}
+		}
+		env, err := hookEnv(name, m[name], metadata[name])
+		if err != nil {
+			return err
+		}
+		for _, command := range m[name].Hooks[kind] {
+			args, err := shlex.Split(command)
+			if err != nil {
+				return errors.Wrapf(err, "invalid %s hook command for target %s", kind, name)
+			}
+			if len(args) == 0 {
+				continue
+			}
+			err = progress.Wrap(fmt.Sprintf("[%s %s] %s", name, kind, command), pw.Write, func(l progress.SubLogger) error {
+				cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+				cmd.Env = env
+				cmd.Stdout = &hookLogWriter{l: l, stream: 1}
+				cmd.Stderr = &hookLogWriter{l: l, stream: 2}
+				return cmd.Run()
+			})
+			if err != nil {
+				return errors.Wrapf(err, "%s hook of target %s failed", kind, name)
+			}
+		}
+	}
+	return nil
+}
+
+// hookEnv returns the environment of the hooks of a target, with the
+// metadata of the target and of its build result.
+func hookEnv(name string, t *Target, metadata map[string]any) ([]string, error) {
+	env := append(os.Environ(),
+		"BAKE_TARGET="+name,
+		"BAKE_TARGET_TAGS="+strings.Join(t.Tags, ","),
+		"BAKE_TARGET_PLATFORMS="+strings.Join(t.Platforms, ","),
+	)
+	if t.Context != nil {
+		env = append(env, "BAKE_TARGET_CONTEXT="+*t.Context)
+	}
+	if t.Dockerfile != nil {
+		env = append(env, "BAKE_TARGET_DOCKERFILE="+*t.Dockerfile)
+	}
+	if metadata != nil {
+		dt, err := json.Marshal(metadata)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		env = append(env, "BAKE_METADATA="+string(dt))
+		if v, ok := metadata["containerimage.digest"].(string); ok {
+			env = append(env, "BAKE_IMAGE_DIGEST="+v)
+		}
+	}
+	return env, nil
+}
+
+type hookLogWriter struct {
+	l      progress.SubLogger
+	stream int
+}
+
+func (w *hookLogWriter) Write(dt []byte) (int, error) {
+	w.l.Log(w.stream, slices.Clone(dt))
+	return len(dt), nil
+}
@@ -0,0 +1,156 @@
+package bake
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/moby/buildkit/client"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+type hookWriter struct {
+	logs strings.Builder
+}
+
+func (w *hookWriter) Write(s *client.SolveStatus) {
+	for _, l := range s.Logs {
+		w.logs.Write(l.Data)
+	}
+}
+
+func (w *hookWriter) WriteBuildRef(string, string) {}
+
+func (w *hookWriter) ValidateLogSource(digest.Digest, interface{}) bool { return true }
+
+func (w *hookWriter) ClearLogSource(interface{}) {}
+
+func TestReadTargetsHooks(t *testing.T) {
+	fp := File{
+		Name: "docker-bake.hcl",
+		Data: []byte(`
+target "base" {
+  hooks = {
+    pre = ["make generate"]
+    post = ["make sign"]
+  }
+}
+
+target "app" {
+  inherits = ["base"]
+  hooks = {
+    post = ["make deploy"]
+  }
+}
+`),
+	}
+	ctx := context.TODO()
+
+	m, _, err := ReadTargets(ctx, []File{fp}, []string{"app"}, nil, nil, &EntitlementConf{})
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{
+		"pre":  {"make generate"},
+		"post": {"make deploy"},
+	}, m["app"].Hooks)
+
+	ent := EntitlementConf{}
+	m, _, err = ReadTargets(ctx, []File{fp}, []string{"app"}, []string{"app.hooks.pre=echo foo", "app.hooks.pre=echo bar"}, nil, &ent)
+	require.NoError(t, err)
+	require.Equal(t, []string{"echo foo", "echo bar"}, m["app"].Hooks["pre"])
+	require.False(t, ent.Hooks)
+	require.True(t, ent.isGranted(EntitlementKeyHooks, "pre=echo foo"))
+	require.False(t, ent.isGranted(EntitlementKeyHooks, "post=echo foo"))
+
+	_, _, err = ReadTargets(ctx, []File{fp}, []string{"app"}, []string{"app.hooks.build=echo foo"}, nil, &EntitlementConf{})
+	require.ErrorContains(t, err, `invalid hook "build"`)
+}
+
+func TestValidateHooks(t *testing.T) {
+	m := map[string]*Target{
+		"app": {Name: "app", Hooks: map[string][]string{"pre": {"make generate"}}},
+	}
+
+	var exp EntitlementConf
+	EntitlementConf{}.ValidateHooks(m, &exp)
+	require.True(t, exp.Hooks)
+
+	exp = EntitlementConf{}
+	EntitlementConf{Hooks: true}.ValidateHooks(m, &exp)
+	require.False(t, exp.Hooks)
+
+	exp = EntitlementConf{}
+	disabled := false
+	m["app"].Enabled = &disabled
+	EntitlementConf{}.ValidateHooks(m, &exp)
+	require.False(t, exp.Hooks)
+}
+
+func TestValidateHooksCommandLine(t *testing.T) {
+	// hooks of a remote definition
+	fp := File{
+		Name: "docker-bake.hcl",
+		Data: []byte(`
+target "app" {
+  hooks = {
+    pre = ["curl -fsSL https://example.com/install.sh | sh"]
+  }
+}
+
+target "docs" {
+  hooks = {
+    post = ["make publish"]
+  }
+}
+`),
+	}
+	ctx := context.TODO()
+
+	ent := EntitlementConf{}
+	m, _, err := ReadTargets(ctx, []File{fp}, []string{"app", "docs"}, []string{"*.hooks.post=echo done"}, nil, &ent)
+	require.NoError(t, err)
+	var exp EntitlementConf
+	ent.ValidateHooks(m, &exp)
+	require.True(t, exp.Hooks)
+
+	// the hooks of the file are still not allowed when a hook is appended
+	ent = EntitlementConf{}
+	m, _, err = ReadTargets(ctx, []File{fp}, []string{"docs"}, []string{"docs.hooks.post+=echo done"}, nil, &ent)
+	require.NoError(t, err)
+	require.Equal(t, []string{"make publish", "echo done"}, m["docs"].Hooks["post"])
+	exp = EntitlementConf{}
+	ent.ValidateHooks(m, &exp)
+	require.True(t, exp.Hooks)
+
+	ent = EntitlementConf{}
+	m, _, err = ReadTargets(ctx, []File{fp}, []string{"docs"}, []string{"docs.hooks.post=echo done"}, nil, &ent)
+	require.NoError(t, err)
+	require.Equal(t, []string{"echo done"}, m["docs"].Hooks["post"])
+	exp = EntitlementConf{}
+	ent.ValidateHooks(m, &exp)
+	require.False(t, exp.Hooks)
+}
+
+func TestRunHooks(t *testing.T) {
+	m := map[string]*Target{
+		"app": {
+			Name:  "app",
+			Tags:  []string{"app:latest"},
+			Hooks: map[string][]string{"pre": {`sh -c "echo pre $BAKE_TARGET $BAKE_TARGET_TAGS"`}, "post": {`sh -c "echo post $BAKE_IMAGE_DIGEST"`}},
+		},
+	}
+	ctx := context.TODO()
+
+	w := &hookWriter{}
+	require.NoError(t, RunPreHooks(ctx, m, w))
+	require.Equal(t, "pre app app:latest\n", w.logs.String())
+
+	w = &hookWriter{}
+	require.NoError(t, RunPostHooks(ctx, m, map[string]map[string]any{
+		"app": {"containerimage.digest": "sha256:abcd"},
+	}, w))
+	require.Equal(t, "post sha256:abcd\n", w.logs.String())
+
+	m["app"].Hooks["pre"] = []string{"sh -c 'exit 1'"}
+	require.ErrorContains(t, RunPreHooks(ctx, m, &hookWriter{}), "pre hook of target app failed")
+}
@@ -0,0 +1,263 @@
+package bake
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/docker/buildx/bake/hclparser"
+	"github.com/docker/buildx/builder"
+	"github.com/docker/buildx/util/progress"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/moby/buildkit/frontend/dockerui"
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Import loads the targets and groups of the Bake files of a source in the
+// namespace of the import name.
+type Import struct {
+	Name   string            `json:"-" hcl:"name,label" cty:"name"`
+	Source string            `json:"source" hcl:"source" cty:"source"`
+	Vars   map[string]string `json:"vars,omitempty" hcl:"vars,optional" cty:"vars"`
+}
+
+var importSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{{Type: "import", LabelNames: []string{"name"}}},
+}
+
+func isRemoteSource(s string) bool {
+	if _, ok := dockerui.DetectGitContext(s, false); ok {
+		return true
+	}
+	_, _, ok := dockerui.DetectHTTPContext(s)
+	return ok
+}
+
+// ReadRemoteImports reads the files of the Git and HTTP sources imported by
+// the files, and of the sources they import. The source of a remote import
+// must be a literal string. The nodes are only loaded if there are remote
+// imports.
+func ReadRemoteImports(ctx context.Context, files []File, nodes func() ([]builder.Node, error), pw progress.Writer) ([]File, error) {
+	var res []File
+	loaded := map[string]struct{}{}
+	for len(files) > 0 {
+		var next []File
+		for _, f := range files {
+			if f.vars {
+				continue
+			}
+			for _, source := range importSources(f) {
+				if _, ok := loaded[source]; ok {
+					continue
+				}
+				loaded[source] = struct{}{}
+				if !isRemoteSource(source) {
+					// local sources can import remote ones
+					if f.source != "" {
+						continue
+					}
+					if !filepath.IsAbs(source) {
+						source = filepath.Join(filepath.Dir(f.Name), source)
+					}
+					if lfiles, err := readImportFiles(source); err == nil {
+						next = append(next, lfiles...)
+					}
+					continue
+				}
+				n, err := nodes()
+				if err != nil {
+					return nil, err
+				}
+				rfiles, _, err := ReadRemoteFiles(ctx, n, source, nil, pw)
+				if err != nil {
+					return nil, errors.Wrapf(err, "failed to read import source %s", source)
+				}
+				for i := range rfiles {
+					rfiles[i].source = source
+				}
+				res = append(res, rfiles...)
+				next = append(next, rfiles...)
+			}
+		}
+		files = next
+	}
+	return res, nil
+}
+
+// importSources returns the literal sources of the import blocks of the
+// file, if it is an HCL file.
+func importSources(f File) []string {
+	hf, isHCL, err := ParseHCLFile(f.Data, f.Name)
+	if !isHCL || err != nil {
+		return nil
+	}
+	content, _, _ := hf.Body.PartialContent(importSchema)
+	if content == nil {
+		return nil
+	}
+	var sources []string
+	for _, b := range content.Blocks {
+		attrs, _ := b.Body.JustAttributes()
+		a, ok := attrs["source"]
+		if !ok {
+			continue
+		}
+		v, diags := a.Expr.Value(nil)
+		if diags.HasErrors() || v.Type() != cty.String || !v.IsKnown() || v.IsNull() {
+			continue
+		}
+		sources = append(sources, v.AsString())
+	}
+	return sources
+}
+
+// loadImports parses the sources of the imports and merges their targets
+// and groups in the config, with their names prefixed by the import name.
+// source is the remote source of the files defining the imports, and defs
+// the file defining each import. imported is the chain of sources being
+// imported, to detect cycles. loaded holds the imports already parsed for
+// their variables and functions.
+func (c *Config) loadImports(files []File, source string, defs map[string]string, defaults map[string]string, imported []string, loaded map[string]*Config) error {
+	for _, imp := range c.Imports {
+		ic, ok := loaded[imp.Name]
+		if !ok {
+			var err error
+			if ic, _, err = parseImport(imp, files, source, defs[imp.Name], defaults, imported); err != nil {
+				return err
+			}
+		}
+		c.Auths = append(c.Auths, ic.Auths...)
+		c.Tests = append(c.Tests, ic.Tests...)
+		*c = mergeConfig(*c, Config{Groups: ic.Groups, Targets: ic.Targets})
+	}
+	c.Imports = nil
+	return nil
+}
+
+// parseImport parses the source of the import imp defined in the file def,
+// and returns its config with the names of the targets and groups prefixed
+// by the import name.
+func parseImport(imp *Import, files []File, source string, def string, defaults map[string]string, imported []string) (*Config, *hclparser.ParseMeta, error) {
+	var remote, varFiles []File
+	for _, f := range files {
+		if f.source != "" {
+			remote = append(remote, f)
+		} else if f.vars {
+			varFiles = append(varFiles, f)
+		}
+	}
+
+	var ifiles []File
+	var id string
+	isource := ""
+	if isRemoteSource(imp.Source) {
+		id, isource = imp.Source, imp.Source
+		if !slices.ContainsFunc(remote, func(f File) bool { return f.source == imp.Source }) {
+			return nil, nil, errors.Errorf("import %s: failed to find Bake files in remote source %s, the source must be a literal string", imp.Name, imp.Source)
+		}
+	} else {
+		if source != "" {
+			return nil, nil, errors.Errorf("import %s: local source %s can't be imported from remote source %s", imp.Name, imp.Source, source)
+		}
+		p := imp.Source
+		if !filepath.IsAbs(p) && def != "" {
+			p = filepath.Join(filepath.Dir(def), p)
+		}
+		var err error
+		if ifiles, err = readImportFiles(p); err != nil {
+			return nil, nil, errors.Wrapf(err, "import %s", imp.Name)
+		}
+		id, _ = filepath.Abs(p)
+	}
+	if slices.Contains(imported, id) {
+		return nil, nil, errors.Errorf("import %s: import cycle through %s", imp.Name, strings.Join(append(imported, id), " -> "))
+	}
+
+	vars := make(map[string]string, len(defaults)+len(imp.Vars))
+	for k, v := range defaults {
+		vars[k] = v
+	}
+	for k, v := range imp.Vars {
+		vars[k] = v
+	}
+	ic, pm, err := parseFiles(slices.Concat(ifiles, remote, varFiles), vars, isource, append(slices.Clone(imported), id))
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "import %s", imp.Name)
+	}
+	ic.namespace(imp.Name)
+	return ic, pm, nil
+}
+
+// readImportFiles reads the Bake file p, or the Bake files with a default
+// name if p is a directory.
+func readImportFiles(p string) ([]File, error) {
+	st, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if !st.IsDir() {
+		dt, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		return []File{{Name: p, Data: dt}}, nil
+	}
+	var files []File
+	for _, name := range defaultFilenames() {
+		dt, err := os.ReadFile(filepath.Join(p, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		files = append(files, File{Name: filepath.Join(p, name), Data: dt})
+	}
+	if len(files) == 0 {
+		return nil, errors.Errorf("no Bake file found in %s", p)
+	}
+	return files, nil
+}
+
+// namespace prefixes the names of the targets and groups of the config, and
+// the references to them, with the name of an import.
+func (c *Config) namespace(ns string) {
+	names := map[string]struct{}{}
+	for _, t := range c.Targets {
+		names[t.Name] = struct{}{}
+	}
+	for _, g := range c.Groups {
+		names[g.Name] = struct{}{}
+	}
+	prefix := func(name string) string {
+		if _, ok := names[name]; ok {
+			return ns + "." + name
+		}
+		return name
+	}
+
+	for _, t := range c.Targets {
+		t.Name = prefix(t.Name)
+		for i, v := range t.Inherits {
+			t.Inherits[i] = prefix(v)
+		}
+		for k, v := range t.Contexts {
+			if target, ok := strings.CutPrefix(v, "target:"); ok {
+				t.Contexts[k] = "target:" + prefix(target)
+			}
+		}
+	}
+	for _, g := range c.Groups {
+		g.Name = prefix(g.Name)
+		for i, v := range g.Targets {
+			g.Targets[i] = prefix(v)
+		}
+	}
+	for _, t := range c.Tests {
+		t.Name = ns + "." + t.Name
+		t.Target = prefix(t.Target)
+	}
+}
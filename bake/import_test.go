@@ -0,0 +1,193 @@
+package bake
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadTargetsImport(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "common"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "common", "docker-bake.hcl"), []byte(`
+variable "GO_VERSION" {
+  default = "1.21"
+}
+
+group "default" {
+  targets = ["base"]
+}
+
+target "base" {
+  args = {
+    GO_VERSION = GO_VERSION
+  }
+  contexts = {
+    tools = "target:tools"
+  }
+}
+
+target "tools" {
+  dockerfile = "tools.Dockerfile"
+}
+`), 0644))
+
+	fp := File{
+		Name: filepath.Join(dir, "docker-bake.hcl"),
+		Data: []byte(`
+import "common" {
+  source = "./common"
+  vars = {
+    GO_VERSION = "1.22"
+  }
+}
+
+target "app" {
+  inherits = ["common.base"]
+  tags = ["app"]
+}
+`),
+	}
+
+	ctx := context.TODO()
+
+	t.Run("Inherits", func(t *testing.T) {
+		m, _, err := ReadTargets(ctx, []File{fp}, []string{"app"}, nil, nil, &EntitlementConf{})
+		require.NoError(t, err)
+		require.Len(t, m, 2)
+		require.Equal(t, []string{"app"}, m["app"].Tags)
+		require.Equal(t, map[string]*string{"GO_VERSION": ptrstr("1.22")}, m["app"].Args)
+		require.Equal(t, map[string]string{"tools": "target:common.tools"}, m["app"].Contexts)
+		require.Equal(t, "tools.Dockerfile", *m["common.tools"].Dockerfile)
+	})
+
+	t.Run("Group", func(t *testing.T) {
+		m, g, err := ReadTargets(ctx, []File{fp}, []string{"common.default"}, nil, nil, &EntitlementConf{})
+		require.NoError(t, err)
+		require.Contains(t, m, "common.base")
+		require.Equal(t, []string{"common.base"}, g["common.default"].Targets)
+	})
+
+	t.Run("Overrides", func(t *testing.T) {
+		m, _, err := ReadTargets(ctx, []File{fp}, []string{"common.tools"}, []string{"common.tools.dockerfile=Dockerfile"}, nil, &EntitlementConf{})
+		require.NoError(t, err)
+		require.Equal(t, "Dockerfile", *m["common.tools"].Dockerfile)
+	})
+}
+
+func TestReadTargetsImportNamespace(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "common.hcl"), []byte(`
+variable "REGISTRY" {
+  default = "docker.io/myorg"
+}
+
+variable "GO_VERSION" {
+  default = "1.21"
+}
+
+function "tag" {
+  params = [name, version]
+  result = "${REGISTRY}/${name}:${version}"
+}
+
+target "base" {}
+`), 0644))
+
+	fp := File{
+		Name: filepath.Join(dir, "docker-bake.hcl"),
+		Data: []byte(`
+variable "VERSION" {
+  default = "1.0"
+}
+
+import "common" {
+  source = "./common.hcl"
+  vars = {
+    REGISTRY = "ghcr.io/myorg"
+  }
+}
+
+variable "GO_VERSION" {
+  default = common.GO_VERSION
+}
+
+target "app" {
+  args = {
+    GO_VERSION = GO_VERSION
+  }
+  tags = [common::tag("app", VERSION)]
+}
+`),
+	}
+
+	m, _, err := ReadTargets(context.TODO(), []File{fp}, []string{"app"}, nil, nil, &EntitlementConf{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"ghcr.io/myorg/app:1.0"}, m["app"].Tags)
+	require.Equal(t, map[string]*string{"GO_VERSION": ptrstr("1.21")}, m["app"].Args)
+
+	t.Run("Undefined", func(t *testing.T) {
+		_, _, err := ReadTargets(context.TODO(), []File{{Name: fp.Name, Data: []byte(`
+import "common" {
+  source = "./common.hcl"
+}
+
+target "app" {
+  tags = [common::missing()]
+}
+`)}}, []string{"app"}, nil, nil, &EntitlementConf{})
+		require.ErrorContains(t, err, `There is no function named "missing" in namespace common::`)
+	})
+}
+
+func TestReadTargetsImportCycle(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.hcl"), []byte(`
+import "b" {
+  source = "./b.hcl"
+}
+target "a" {}
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.hcl"), []byte(`
+import "a" {
+  source = "./a.hcl"
+}
+target "b" {}
+`), 0644))
+
+	fp := File{
+		Name: filepath.Join(dir, "docker-bake.hcl"),
+		Data: []byte(`
+import "a" {
+  source = "./a.hcl"
+}
+`),
+	}
+	_, _, err := ReadTargets(context.TODO(), []File{fp}, []string{"a.a"}, nil, nil, &EntitlementConf{})
+	require.ErrorContains(t, err, "import cycle")
+}
+
+func TestReadTargetsImportRemote(t *testing.T) {
+	fp := File{
+		Name: "docker-bake.hcl",
+		Data: []byte(`
+import "common" {
+  source = "https://github.com/docker/buildx.git#master"
+}
+`),
+	}
+	_, _, err := ReadTargets(context.TODO(), []File{fp}, []string{"default"}, nil, nil, &EntitlementConf{})
+	require.ErrorContains(t, err, "failed to find Bake files in remote source")
+
+	fr := File{
+		Name:   "docker-bake.hcl",
+		Data:   []byte(`target "base" {}`),
+		source: "https://github.com/docker/buildx.git#master",
+	}
+	m, _, err := ReadTargets(context.TODO(), []File{fp, fr}, []string{"common.base"}, nil, nil, &EntitlementConf{})
+	require.NoError(t, err)
+	require.Contains(t, m, "common.base")
+}
@@ -53,7 +53,7 @@ func ReadRemoteFiles(ctx context.Context, nodes []builder.Node, url string, name
 			})
 		}
 		if len(gitAuthSecrets) > 0 {
-			if secrets, err := controllerapi.CreateSecrets(gitAuthSecrets); err == nil {
+			if secrets, err := controllerapi.CreateSecrets(gitAuthSecrets, nil); err == nil {
 				sessions = append(sessions, secrets)
 			}
 		}
@@ -113,6 +113,9 @@ func ReadRemoteFiles(ctx context.Context, nodes []builder.Node, url string, name
 		return nil, nil, err
 	}
 
+	for i := range files {
+		files[i].remote = url
+	}
 	return files, inp, nil
 }
 
@@ -0,0 +1,342 @@
+package bake
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/buildx/build"
+	controllerapi "github.com/docker/buildx/controller/pb"
+	"github.com/docker/buildx/util/progress"
+	gatewaypb "github.com/moby/buildkit/frontend/gateway/pb"
+	"github.com/pkg/errors"
+)
+
+// Test runs a command in a container started from the build result of a
+// target and checks its exit code and output.
+type Test struct {
+	Name           string            `json:"-" hcl:"name,label" cty:"name"`
+	Description    string            `json:"description,omitempty" hcl:"description,optional" cty:"description"`
+	Target         string            `json:"target" hcl:"target" cty:"target"`
+	Command        []string          `json:"command" hcl:"command" cty:"command"`
+	Env            map[string]string `json:"env,omitempty" hcl:"env,optional" cty:"env"`
+	ExitCode       *int              `json:"exit-code,omitempty" hcl:"exit-code,optional" cty:"exit-code"`
+	OutputContains []string          `json:"output-contains,omitempty" hcl:"output-contains,optional" cty:"output-contains"`
+	OutputMatches  []string          `json:"output-matches,omitempty" hcl:"output-matches,optional" cty:"output-matches"`
+}
+
+// readTests returns the tests of the enabled targets of m, sorted by name.
+func (c Config) readTests(m map[string]*Target) ([]*Test, error) {
+	var tests []*Test
+	for _, t := range c.Tests {
+		if err := t.validate(); err != nil {
+			return nil, err
+		}
+		if !slices.ContainsFunc(c.Targets, func(target *Target) bool { return target.Name == t.Target }) {
+			return nil, errors.Errorf("test %s references unknown target %s", t.Name, t.Target)
+		}
+		if tgt, ok := m[t.Target]; ok && tgt.IsEnabled() {
+			tests = append(tests, t)
+		}
+	}
+	slices.SortFunc(tests, func(a, b *Test) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return tests, nil
+}
+
+func (t *Test) validate() error {
+	if t.Target == "" {
+		return errors.Errorf("test %s has no target", t.Name)
+	}
+	if len(t.Command) == 0 {
+		return errors.Errorf("test %s has no command", t.Name)
+	}
+	for _, expr := range t.OutputMatches {
+		if _, err := regexp.Compile(expr); err != nil {
+			return errors.Wrapf(err, "invalid output-matches expression for test %s", t.Name)
+		}
+	}
+	return nil
+}
+
+// check returns an error if the exit code or the output of the command of
+// the test are not the expected ones.
+func (t *Test) check(exitCode int, output string) error {
+	expected := 0
+	if t.ExitCode != nil {
+		expected = *t.ExitCode
+	}
+	if exitCode != expected {
+		return errors.Errorf("expected exit code %d, got %d", expected, exitCode)
+	}
+	for _, s := range t.OutputContains {
+		if !strings.Contains(output, s) {
+			return errors.Errorf("expected output to contain %q", s)
+		}
+	}
+	for _, expr := range t.OutputMatches {
+		if !regexp.MustCompile(expr).MatchString(output) {
+			return errors.Errorf("expected output to match %q", expr)
+		}
+	}
+	return nil
+}
+
+// TestResult is the result of a test.
+type TestResult struct {
+	Test *Test
+	// Platform is the platform of the build result the test ran on, set if
+	// the target is built for multiple platforms.
+	Platform string
+	ExitCode int
+	Output   string
+	Duration time.Duration
+	// Failure is set if the exit code or the output of the command are not
+	// the expected ones.
+	Failure error
+	// Error is set if the command could not be run.
+	Error error
+}
+
+// Name returns the name of the test, with the platform it ran on if set.
+func (r *TestResult) Name() string {
+	if r.Platform != "" {
+		return r.Test.Name + " (" + r.Platform + ")"
+	}
+	return r.Test.Name
+}
+
+// Passed returns true if the command of the test ran with the expected exit
+// code and output.
+func (r *TestResult) Passed() bool {
+	return r.Failure == nil && r.Error == nil
+}
+
+// testRun is a build result of a platform of a target to run a test on.
+type testRun struct {
+	rh       *build.ResultHandle
+	platform string
+}
+
+// RunTests runs the tests in containers started from the build results of
+// their targets, that are not loaded to the image store. The results of a
+// target are the results of the nodes building it, and a test runs once for
+// each platform of the target. The results are returned in the order of the
+// tests and of the platforms.
+func RunTests(ctx context.Context, tests []*Test, results map[string][]*build.ResultHandle, pw progress.Writer) []*TestResult {
+	out := make([]*TestResult, 0, len(tests))
+	for _, t := range tests {
+		runs, err := testRuns(results[t.Target])
+		if err != nil {
+			out = append(out, &TestResult{Test: t, Error: err})
+			continue
+		}
+		if len(runs) == 0 {
+			out = append(out, &TestResult{Test: t, Error: errors.Errorf("no build result for target %s", t.Target)})
+			continue
+		}
+		for _, run := range runs {
+			res := &TestResult{Test: t}
+			if len(runs) > 1 {
+				res.Platform = run.platform
+			}
+			// the error is recorded in the result
+			_ = progress.Wrap(fmt.Sprintf("[test %s] %s", res.Name(), strings.Join(t.Command, " ")), pw.Write, func(l progress.SubLogger) error {
+				start := time.Now()
+				defer func() {
+					res.Duration = time.Since(start)
+				}()
+				res.ExitCode, res.Output, res.Error = runTest(ctx, t, run, l)
+				if res.Error != nil {
+					return res.Error
+				}
+				res.Failure = t.check(res.ExitCode, res.Output)
+				return res.Failure
+			})
+			out = append(out, res)
+		}
+	}
+	return out
+}
+
+// testRuns returns the platforms of the build results, sorted by platform.
+func testRuns(results []*build.ResultHandle) ([]testRun, error) {
+	var runs []testRun
+	for _, rh := range results {
+		ps, err := rh.Platforms()
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range ps {
+			runs = append(runs, testRun{rh: rh, platform: p})
+		}
+	}
+	slices.SortFunc(runs, func(a, b testRun) int {
+		return strings.Compare(a.platform, b.platform)
+	})
+	return runs, nil
+}
+
+func runTest(ctx context.Context, t *Test, run testRun, l progress.SubLogger) (int, string, error) {
+	env := make([]string, 0, len(t.Env))
+	for k, v := range t.Env {
+		env = append(env, k+"="+v)
+	}
+	slices.Sort(env)
+	cfg := &controllerapi.InvokeConfig{
+		Entrypoint: t.Command,
+		Env:        env,
+		NoUser:     true,
+		NoCwd:      true,
+	}
+
+	ctr, err := build.NewPlatformContainer(ctx, run.rh, cfg, run.platform)
+	if err != nil {
+		return 0, "", err
+	}
+	defer ctr.Cancel()
+
+	out := &testOutput{l: l}
+	err = ctr.Exec(ctx, cfg, nil, out.stream(1), out.stream(2))
+	var exitErr *gatewaypb.ExitError
+	if errors.As(err, &exitErr) {
+		return int(exitErr.ExitCode), out.String(), nil
+	} else if err != nil {
+		return 0, out.String(), err
+	}
+	return 0, out.String(), nil
+}
+
+// testOutput records the combined output of a test command and logs it to
+// the progress.
+type testOutput struct {
+	l   progress.SubLogger
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (o *testOutput) stream(stream int) io.WriteCloser {
+	return &testOutputStream{o: o, stream: stream}
+}
+
+func (o *testOutput) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.String()
+}
+
+type testOutputStream struct {
+	o      *testOutput
+	stream int
+}
+
+func (w *testOutputStream) Write(dt []byte) (int, error) {
+	w.o.mu.Lock()
+	w.o.buf.Write(dt)
+	w.o.mu.Unlock()
+	w.o.l.Log(w.stream, slices.Clone(dt))
+	return len(dt), nil
+}
+
+func (w *testOutputStream) Close() error {
+	return nil
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+// WriteJUnitReport writes the results of the tests in the JUnit XML format,
+// with a test suite for each target.
+func WriteJUnitReport(w io.Writer, results []*TestResult) error {
+	var report junitTestSuites
+	var total time.Duration
+	suites := map[string]*junitTestSuite{}
+	durations := map[string]time.Duration{}
+	var names []string
+	for _, r := range results {
+		target := r.Test.Target
+		suite, ok := suites[target]
+		if !ok {
+			suite = &junitTestSuite{Name: target}
+			suites[target] = suite
+			names = append(names, target)
+		}
+		tc := junitTestCase{
+			Name:      r.Name(),
+			Classname: target,
+			Time:      junitTime(r.Duration),
+			SystemOut: r.Output,
+		}
+		if r.Error != nil {
+			tc.Error = &junitMessage{Message: r.Error.Error()}
+			suite.Errors++
+			report.Errors++
+		} else if r.Failure != nil {
+			tc.Failure = &junitMessage{Message: r.Failure.Error()}
+			suite.Failures++
+			report.Failures++
+		}
+		suite.Tests++
+		suite.TestCases = append(suite.TestCases, tc)
+		durations[target] += r.Duration
+		report.Tests++
+		total += r.Duration
+	}
+	for _, name := range names {
+		suite := suites[name]
+		suite.Time = junitTime(durations[name])
+		report.Suites = append(report.Suites, *suite)
+	}
+	report.Time = junitTime(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return errors.WithStack(err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,185 @@
+package bake
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadTests(t *testing.T) {
+	fp := File{
+		Name: "docker-bake.hcl",
+		Data: []byte(`
+target "app" {
+  tags = ["app"]
+}
+
+target "tools" {
+}
+
+test "version" {
+  target = "app"
+  command = ["app", "--version"]
+  output-matches = ["^app v[0-9.]+"]
+}
+
+test "help" {
+  target = "app"
+  command = ["app", "--help"]
+  env = {
+    TERM = "dumb"
+  }
+  exit-code = 1
+  output-contains = ["Usage:"]
+}
+
+test "lint" {
+  target = "tools"
+  command = ["lint"]
+}
+`),
+	}
+	ctx := context.TODO()
+
+	def, err := ReadDefinition(ctx, []File{fp}, []string{"app"}, nil, nil, &EntitlementConf{})
+	require.NoError(t, err)
+
+	tests := def.Tests
+	require.Len(t, tests, 2)
+	require.Equal(t, "help", tests[0].Name)
+	require.Equal(t, "app", tests[0].Target)
+	require.Equal(t, []string{"app", "--help"}, tests[0].Command)
+	require.Equal(t, map[string]string{"TERM": "dumb"}, tests[0].Env)
+	require.Equal(t, 1, *tests[0].ExitCode)
+	require.Equal(t, []string{"Usage:"}, tests[0].OutputContains)
+	require.Equal(t, "version", tests[1].Name)
+	require.Nil(t, tests[1].ExitCode)
+
+	t.Run("UnknownTarget", func(t *testing.T) {
+		_, err := ReadDefinition(ctx, []File{{Name: "docker-bake.hcl", Data: []byte(`
+target "app" {}
+test "version" {
+  target = "ap"
+  command = ["app", "--version"]
+}
+`)}}, []string{"app"}, nil, nil, &EntitlementConf{})
+		require.ErrorContains(t, err, "test version references unknown target ap")
+	})
+
+	t.Run("NoCommand", func(t *testing.T) {
+		_, err := ReadDefinition(ctx, []File{{Name: "docker-bake.hcl", Data: []byte(`
+target "app" {}
+test "version" {
+  target = "app"
+  command = []
+}
+`)}}, []string{"app"}, nil, nil, &EntitlementConf{})
+		require.ErrorContains(t, err, "test version has no command")
+	})
+
+	t.Run("InvalidExpression", func(t *testing.T) {
+		_, err := ReadDefinition(ctx, []File{{Name: "docker-bake.hcl", Data: []byte(`
+target "app" {}
+test "version" {
+  target = "app"
+  command = ["app", "--version"]
+  output-matches = ["v[0-9"]
+}
+`)}}, []string{"app"}, nil, nil, &EntitlementConf{})
+		require.ErrorContains(t, err, "invalid output-matches expression for test version")
+	})
+}
+
+func TestReadTestsImport(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "common.hcl"), []byte(`
+target "base" {
+}
+
+test "smoke" {
+  target = "base"
+  command = ["true"]
+}
+`), 0644))
+
+	fp := File{
+		Name: filepath.Join(dir, "docker-bake.hcl"),
+		Data: []byte(`
+import "common" {
+  source = "./common.hcl"
+}
+`),
+	}
+
+	def, err := ReadDefinition(context.TODO(), []File{fp}, []string{"common.base"}, nil, nil, &EntitlementConf{})
+	require.NoError(t, err)
+	require.Len(t, def.Tests, 1)
+	require.Equal(t, "common.smoke", def.Tests[0].Name)
+	require.Equal(t, "common.base", def.Tests[0].Target)
+}
+
+func TestTestCheck(t *testing.T) {
+	exitCode := 2
+	tt := &Test{
+		Name:           "version",
+		Target:         "app",
+		Command:        []string{"app", "--version"},
+		ExitCode:       &exitCode,
+		OutputContains: []string{"app"},
+		OutputMatches:  []string{`v\d+\.\d+`},
+	}
+
+	require.NoError(t, tt.check(2, "app v1.2\n"))
+	require.EqualError(t, tt.check(0, "app v1.2\n"), "expected exit code 2, got 0")
+	require.EqualError(t, tt.check(2, "tool v1.2\n"), `expected output to contain "app"`)
+	require.EqualError(t, tt.check(2, "app\n"), `expected output to match "v\\d+\\.\\d+"`)
+}
+
+func TestWriteJUnitReport(t *testing.T) {
+	results := []*TestResult{
+		{
+			Test:     &Test{Name: "help", Target: "app"},
+			Output:   "Usage: app\n",
+			Duration: 1500 * time.Millisecond,
+		},
+		{
+			Test:     &Test{Name: "version", Target: "app"},
+			Platform: "linux/arm64",
+			ExitCode: 1,
+			Output:   "unknown flag\n",
+			Duration: 200 * time.Millisecond,
+			Failure:  errors.New("expected exit code 0, got 1"),
+		},
+		{
+			Test:  &Test{Name: "lint", Target: "tools"},
+			Error: errors.New("no build result for target tools"),
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteJUnitReport(&buf, results))
+	require.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="3" failures="1" errors="1" time="1.700">
+  <testsuite name="app" tests="2" failures="1" errors="0" time="1.700">
+    <testcase name="help" classname="app" time="1.500">
+      <system-out>Usage: app&#xA;</system-out>
+    </testcase>
+    <testcase name="version (linux/arm64)" classname="app" time="0.200">
+      <failure message="expected exit code 0, got 1"></failure>
+      <system-out>unknown flag&#xA;</system-out>
+    </testcase>
+  </testsuite>
+  <testsuite name="tools" tests="1" failures="0" errors="1" time="0.000">
+    <testcase name="lint" classname="tools" time="0.000">
+      <error message="no build result for target tools"></error>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, buf.String())
+}
@@ -0,0 +1,74 @@
+package bake
+
+import (
+	"os"
+	"strings"
+
+	"github.com/compose-spec/compose-go/v2/dotenv"
+	"github.com/docker/buildx/bake/hclparser"
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// defaultVarFile is the variable file read from the current working
+// directory if it exists.
+const defaultVarFile = "docker-bake.override.vars"
+
+// ReadVarFiles reads the variable files, after the default variable file of
+// the current working directory if it exists. The values of the variables
+// set in the files take precedence over the environment, and the ones of the
+// last files over the ones of the first files.
+func ReadVarFiles(names []string) ([]File, error) {
+	var files []File
+	if dt, err := os.ReadFile(defaultVarFile); err == nil {
+		files = append(files, File{Name: defaultVarFile, Data: dt, vars: true})
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, name := range names {
+		dt, err := os.ReadFile(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read variable file")
+		}
+		files = append(files, File{Name: name, Data: dt, vars: true})
+	}
+	return files, nil
+}
+
+// varValues returns the values of the variables set by the variable files.
+// HCL and JSON files hold typed values, other files are read as .env files
+// with string values.
+func varValues(files []File) (map[string]hclparser.VarValue, error) {
+	values := map[string]hclparser.VarValue{}
+	for _, f := range files {
+		if !f.vars {
+			continue
+		}
+		if !strings.HasSuffix(f.Name, ".hcl") && !strings.HasSuffix(f.Name, ".json") {
+			envs, err := dotenv.UnmarshalBytesWithLookup(f.Data, nil)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to parse variable file %s", f.Name)
+			}
+			for k, v := range envs {
+				values[k] = hclparser.VarValue{Value: cty.StringVal(v), File: f.Name}
+			}
+			continue
+		}
+		hf, _, err := ParseHCLFile(f.Data, f.Name)
+		if err != nil {
+			return nil, err
+		}
+		attrs, diags := hf.Body.JustAttributes()
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		for k, attr := range attrs {
+			v, diags := attr.Expr.Value(nil)
+			if diags.HasErrors() {
+				return nil, diags
+			}
+			values[k] = hclparser.VarValue{Value: v, File: f.Name}
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,128 @@
+package bake
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadTargetsVarFiles(t *testing.T) {
+	fp := File{
+		Name: "docker-bake.hcl",
+		Data: []byte(`
+variable "TAG" {
+  default = "latest"
+}
+variable "PUSH" {
+  default = false
+}
+variable "PLATFORMS" {
+  default = ["linux/amd64"]
+}
+variable "REGISTRY" {}
+
+target "app" {
+  tags = ["${REGISTRY}/app:${TAG}"]
+  platforms = PLATFORMS
+  args = {
+    PUSH = PUSH
+  }
+}
+`),
+	}
+	fh := File{
+		Name: "prod.hcl",
+		Data: []byte(`
+TAG = "v1"
+PUSH = true
+PLATFORMS = ["linux/amd64", "linux/arm64"]
+REGISTRY = "docker.io"
+`),
+		vars: true,
+	}
+	fj := File{
+		Name: "prod.json",
+		Data: []byte(`{"TAG": "v2"}`),
+		vars: true,
+	}
+	fe := File{
+		Name: ".env",
+		Data: []byte("TAG=v3\nPUSH=false\n"),
+		vars: true,
+	}
+	ctx := context.TODO()
+
+	t.Run("HCL", func(t *testing.T) {
+		m, _, err := ReadTargets(ctx, []File{fp, fh}, []string{"app"}, nil, nil, &EntitlementConf{})
+		require.NoError(t, err)
+		require.Equal(t, []string{"docker.io/app:v1"}, m["app"].Tags)
+		require.Equal(t, []string{"linux/amd64", "linux/arm64"}, m["app"].Platforms)
+		require.Equal(t, ptrstr("true"), m["app"].Args["PUSH"])
+	})
+
+	t.Run("Precedence", func(t *testing.T) {
+		t.Setenv("TAG", "env")
+		t.Setenv("REGISTRY", "ghcr.io")
+
+		m, _, err := ReadTargets(ctx, []File{fp, fe}, []string{"app"}, nil, nil, &EntitlementConf{})
+		require.NoError(t, err)
+		require.Equal(t, []string{"ghcr.io/app:v3"}, m["app"].Tags)
+		require.Equal(t, ptrstr("false"), m["app"].Args["PUSH"])
+
+		m, _, err = ReadTargets(ctx, []File{fp, fh, fj}, []string{"app"}, nil, nil, &EntitlementConf{})
+		require.NoError(t, err)
+		require.Equal(t, []string{"docker.io/app:v2"}, m["app"].Tags)
+	})
+
+	t.Run("Sources", func(t *testing.T) {
+		t.Setenv("REGISTRY", "ghcr.io")
+
+		_, pm, err := ParseFiles([]File{fp, fj}, nil)
+		require.NoError(t, err)
+		sources := map[string]string{}
+		for _, v := range pm.AllVariables {
+			sources[v.Name] = v.Source
+		}
+		require.Equal(t, map[string]string{
+			"TAG":       "prod.json",
+			"PUSH":      "",
+			"PLATFORMS": "",
+			"REGISTRY":  "env",
+		}, sources)
+	})
+
+	t.Run("InvalidType", func(t *testing.T) {
+		_, _, err := ReadTargets(ctx, []File{fp, {Name: ".env", Data: []byte("PUSH=maybe"), vars: true}}, []string{"app"}, nil, nil, &EntitlementConf{})
+		require.ErrorContains(t, err, "failed to parse PUSH as bool")
+	})
+}
+
+func TestReadVarFiles(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() {
+		require.NoError(t, os.Chdir(wd))
+	})
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "prod.env"), []byte("TAG=v1\n"), 0644))
+
+	files, err := ReadVarFiles([]string{"prod.env"})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, "prod.env", files[0].Name)
+	require.True(t, files[0].vars)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "docker-bake.override.vars"), []byte("TAG=latest\n"), 0644))
+	files, err = ReadVarFiles([]string{"prod.env"})
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	require.Equal(t, "docker-bake.override.vars", files[0].Name)
+
+	_, err = ReadVarFiles([]string{"missing.env"})
+	require.ErrorContains(t, err, "failed to read variable file")
+}
@@ -0,0 +1,476 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/containerd/platforms"
+	"github.com/distribution/reference"
+	"github.com/docker/buildx/util/imagetools"
+	"github.com/docker/buildx/util/osutil"
+	"github.com/docker/buildx/util/progress"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"github.com/moby/buildkit/frontend/dockerfile/shell"
+	spb "github.com/moby/buildkit/sourcepolicy/pb"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+// BaseImagePolicy is a policy the base images of a build must satisfy. In
+// patterns, "*" matches any sequence of characters. Empty fields are not
+// checked.
+type BaseImagePolicy struct {
+	// Repositories are the allowed repositories of the base images, like
+	// "docker.io/library/*".
+	Repositories []string
+	// BuilderIDs are the allowed builder IDs of the provenance attestations.
+	BuilderIDs []string
+	// Sources are the allowed source URIs of the provenance attestations,
+	// like "https://github.com/myorg/*".
+	Sources []string
+	// MaxAge is the maximum age of the base images.
+	MaxAge time.Duration
+	// Keys are the public keys the base images must be signed with. The
+	// provenance attestations are only authenticated by the signature of
+	// the image index that references them, so BuilderIDs and Sources are
+	// advisory if no key is set.
+	Keys []crypto.PublicKey
+}
+
+// ReadBaseImagePolicy reads a base image policy from a JSON file.
+func ReadBaseImagePolicy(p string) (*BaseImagePolicy, error) {
+	dt, err := os.ReadFile(p)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read base image policy")
+	}
+	pol, err := parseBaseImagePolicy(dt, filepath.Dir(p))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse base image policy %s", p)
+	}
+	return pol, nil
+}
+
+// parseBaseImagePolicy parses a base image policy. The paths of the keys are
+// relative to dir.
+func parseBaseImagePolicy(dt []byte, dir string) (*BaseImagePolicy, error) {
+	var in struct {
+		Repositories []string `json:"repositories"`
+		BuilderIDs   []string `json:"builderIds"`
+		Sources      []string `json:"sources"`
+		MaxAge       string   `json:"maxAge"`
+		Keys         []string `json:"keys"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(dt))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	pol := &BaseImagePolicy{
+		Repositories: in.Repositories,
+		BuilderIDs:   in.BuilderIDs,
+		Sources:      in.Sources,
+	}
+	if in.MaxAge != "" {
+		d, err := parseMaxAge(in.MaxAge)
+		if err != nil {
+			return nil, err
+		}
+		pol.MaxAge = d
+	}
+	for _, k := range in.Keys {
+		if !filepath.IsAbs(k) {
+			k = filepath.Join(dir, k)
+		}
+		dt, err := os.ReadFile(k)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read key")
+		}
+		key, err := imagetools.LoadPublicKey(dt)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load key %s", k)
+		}
+		pol.Keys = append(pol.Keys, key)
+	}
+	return pol, nil
+}
+
+// parseMaxAge parses a duration, also accepting a number of days like "30d".
+func parseMaxAge(v string) (time.Duration, error) {
+	var d time.Duration
+	if days, ok := strings.CutSuffix(v, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, errors.Errorf("invalid maxAge %q", v)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(v); err != nil {
+			return 0, errors.Errorf("invalid maxAge %q", v)
+		}
+	}
+	if d <= 0 {
+		return 0, errors.Errorf("invalid maxAge %q, must be positive", v)
+	}
+	return d, nil
+}
+
+// evaluate returns the violations of the policy by the image ref and the
+// origins of its platforms. signed tells if the image is signed with one of
+// the keys of the policy.
+func (p *BaseImagePolicy) evaluate(ref reference.Named, origins []imagetools.ImageOrigin, signed bool, now time.Time) []string {
+	var violations []string
+	if len(p.Repositories) > 0 && !matchAny(p.Repositories, ref.Name()) {
+		violations = append(violations, fmt.Sprintf("repository %s is not allowed", ref.Name()))
+	}
+	if len(p.Keys) > 0 && !signed {
+		violations = append(violations, "not signed with a key of the policy")
+	}
+
+	for _, o := range origins {
+		if !o.Provenance && (len(p.BuilderIDs) > 0 || len(p.Sources) > 0) {
+			violations = append(violations, fmt.Sprintf("%s: no provenance attestation", o.Platform))
+		} else {
+			if len(p.BuilderIDs) > 0 && !matchAny(p.BuilderIDs, o.BuilderID) {
+				violations = append(violations, fmt.Sprintf("%s: builder %q is not allowed", o.Platform, o.BuilderID))
+			}
+			if len(p.Sources) > 0 {
+				var found bool
+				for _, s := range o.Sources {
+					if matchAny(p.Sources, s) {
+						found = true
+						break
+					}
+				}
+				if !found {
+					violations = append(violations, fmt.Sprintf("%s: sources %s are not allowed", o.Platform, strings.Join(o.Sources, ", ")))
+				}
+			}
+		}
+		if p.MaxAge > 0 {
+			if o.Created == nil {
+				violations = append(violations, fmt.Sprintf("%s: unknown creation time", o.Platform))
+			} else if age := now.Sub(*o.Created); age > p.MaxAge {
+				violations = append(violations, fmt.Sprintf("%s: created %s ago, exceeding the maximum age of %s", o.Platform, age.Truncate(time.Hour), p.MaxAge))
+			}
+		}
+	}
+	return violations
+}
+
+// signedWithAny returns true if the manifest dgst of ref has a signature
+// made with one of keys.
+func signedWithAny(ctx context.Context, r *imagetools.Resolver, ref reference.Named, dgst digest.Digest, keys []crypto.PublicKey) (bool, error) {
+	for _, key := range keys {
+		sigs, err := r.Signatures(ctx, ref, dgst, key)
+		if err != nil {
+			return false, err
+		}
+		for _, sig := range sigs {
+			if sig.Status == imagetools.SignatureVerified {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func matchAny(patterns []string, s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, p := range patterns {
+		re := "^" + strings.ReplaceAll(regexp.QuoteMeta(p), `\*`, ".*") + "$"
+		if ok, _ := regexp.MatchString(re, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyBaseImages evaluates the base images of the build against the base
+// image policy, and returns the source policy of the build with the base
+// images pinned to the verified digests.
+func verifyBaseImages(ctx context.Context, opt Options, imageopt imagetools.Opt, pw progress.Writer) (*spb.Policy, error) {
+	names, err := baseImages(opt.Inputs, opt.BuildArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	var matchers []platforms.Matcher
+	for _, p := range opt.Platforms {
+		matchers = append(matchers, platforms.NewMatcher(p))
+	}
+
+	var rules []*spb.Rule
+	r := imagetools.New(imageopt)
+	err = progress.Wrap("verifying base images", pw.Write, func(l progress.SubLogger) error {
+		if len(opt.BaseImagePolicy.Keys) == 0 && (len(opt.BaseImagePolicy.BuilderIDs) > 0 || len(opt.BaseImagePolicy.Sources) > 0) {
+			l.Log(2, []byte("WARNING: the policy has no keys, the provenance attestations of the base images are not authenticated\n"))
+		}
+		var failed []string
+		for _, name := range names {
+			ref, err := reference.ParseNormalizedNamed(name)
+			if err != nil {
+				return errors.Wrapf(err, "invalid base image %q", name)
+			}
+			ref = reference.TagNameOnly(ref)
+
+			desc, origins, err := r.Origins(ctx, ref.String())
+			if err != nil {
+				return errors.Wrapf(err, "failed to verify base image %s", ref)
+			}
+			if len(matchers) > 0 {
+				var selected []imagetools.ImageOrigin
+				for _, o := range origins {
+					p, err := platforms.Parse(o.Platform)
+					if err != nil {
+						continue
+					}
+					for _, m := range matchers {
+						if m.Match(p) {
+							selected = append(selected, o)
+							break
+						}
+					}
+				}
+				origins = selected
+			}
+
+			var signed bool
+			if len(opt.BaseImagePolicy.Keys) > 0 {
+				if signed, err = signedWithAny(ctx, r, ref, desc.Digest, opt.BaseImagePolicy.Keys); err != nil {
+					return errors.Wrapf(err, "failed to verify the signatures of base image %s", ref)
+				}
+			}
+			if violations := opt.BaseImagePolicy.evaluate(ref, origins, signed, time.Now()); len(violations) > 0 {
+				failed = append(failed, fmt.Sprintf("%s: %s", ref, strings.Join(violations, "; ")))
+				l.Log(2, []byte(fmt.Sprintf("%s@%s: rejected\n", ref, desc.Digest)))
+				continue
+			}
+			l.Log(1, []byte(fmt.Sprintf("%s@%s: verified\n", ref, desc.Digest)))
+
+			if _, ok := ref.(reference.Canonical); ok {
+				continue
+			}
+			rule, err := PinRule(ref, desc.Digest)
+			if err != nil {
+				return err
+			}
+			rules = append(rules, rule)
+		}
+		if len(failed) > 0 {
+			return errors.Errorf("base images rejected by policy:\n%s", strings.Join(failed, "\n"))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rules) == 0 {
+		return opt.SourcePolicy, nil
+	}
+	pol := &spb.Policy{Version: 1}
+	if opt.SourcePolicy != nil {
+		pol = proto.Clone(opt.SourcePolicy).(*spb.Policy)
+	}
+	pol.Rules = append(pol.Rules, rules...)
+	return pol, nil
+}
+
+// BaseImage is the image of a FROM instruction of a Dockerfile.
+type BaseImage struct {
+	// Name is the image name with the build arguments expanded.
+	Name string
+	// Original is the image name as written in the Dockerfile.
+	Original string
+	// Line is the line of the FROM instruction, starting at 1.
+	Line int
+}
+
+// ParseBaseImages returns the images of the FROM instructions of the
+// Dockerfile dt, with the build arguments expanded. Stages and scratch are
+// skipped.
+func ParseBaseImages(dt []byte, buildArgs map[string]string) ([]BaseImage, error) {
+	res, err := parser.Parse(bytes.NewReader(dt))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse Dockerfile")
+	}
+
+	var out []BaseImage
+	lex := shell.NewLex(res.EscapeToken)
+	var metaArgs []string
+	stages := map[string]struct{}{}
+	var inStage bool
+	for _, n := range res.AST.Children {
+		switch strings.ToLower(n.Value) {
+		case "arg":
+			if inStage {
+				continue
+			}
+			for next := n.Next; next != nil; next = next.Next {
+				k, v, _ := strings.Cut(next.Value, "=")
+				if ba, ok := buildArgs[k]; ok {
+					v = ba
+				} else if v, _, err = lex.ProcessWord(v, shell.EnvsFromSlice(metaArgs)); err != nil {
+					return nil, errors.Wrapf(err, "failed to expand ARG %s", k)
+				}
+				metaArgs = append(metaArgs, k+"="+v)
+			}
+		case "from":
+			inStage = true
+			if n.Next == nil {
+				continue
+			}
+			name, _, err := lex.ProcessWord(n.Next.Value, shell.EnvsFromSlice(metaArgs))
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to expand base image %s", n.Next.Value)
+			}
+			if _, ok := stages[strings.ToLower(name)]; !ok && name != "scratch" {
+				if name == "" {
+					return nil, errors.Errorf("base image %q expands to an empty name", n.Next.Value)
+				}
+				out = append(out, BaseImage{
+					Name:     name,
+					Original: n.Next.Value,
+					Line:     n.StartLine,
+				})
+			}
+		}
+		if as := stageName(n); as != "" {
+			stages[as] = struct{}{}
+		}
+	}
+	return out, nil
+}
+
+// PinRule returns the source policy rule converting the image ref to the
+// image with the digest dgst.
+func PinRule(ref reference.Named, dgst digest.Digest) (*spb.Rule, error) {
+	ref = reference.TagNameOnly(ref)
+	pinned, err := reference.WithDigest(ref, dgst)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &spb.Rule{
+		Action: spb.PolicyAction_CONVERT,
+		Selector: &spb.Selector{
+			Identifier: "docker-image://" + ref.String(),
+		},
+		Updates: &spb.Update{
+			Identifier: "docker-image://" + pinned.String(),
+		},
+	}, nil
+}
+
+// baseImages returns the images of the FROM instructions of the Dockerfile,
+// with the named contexts replacing them, and the images of the named
+// contexts.
+func baseImages(inp Inputs, buildArgs map[string]string) ([]string, error) {
+	dt, err := readDockerfile(inp)
+	if err != nil {
+		return nil, err
+	}
+	froms, err := ParseBaseImages(dt, buildArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []string
+	seen := map[string]struct{}{}
+	add := func(name string) {
+		if _, ok := seen[name]; !ok {
+			seen[name] = struct{}{}
+			out = append(out, name)
+		}
+	}
+
+	for _, from := range froms {
+		if nc, ok := namedContext(inp.NamedContexts, from.Name); ok {
+			if img, ok := strings.CutPrefix(nc.Path, "docker-image://"); ok {
+				add(img)
+			}
+			continue
+		}
+		add(from.Name)
+	}
+
+	keys := make([]string, 0, len(inp.NamedContexts))
+	for k := range inp.NamedContexts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if img, ok := strings.CutPrefix(inp.NamedContexts[k].Path, "docker-image://"); ok {
+			add(img)
+		}
+	}
+	return out, nil
+}
+
+// stageName returns the lowercase name of the stage n starts, if any.
+func stageName(n *parser.Node) string {
+	if !strings.EqualFold(n.Value, "from") || n.Next == nil {
+		return ""
+	}
+	if as := n.Next.Next; as != nil && strings.EqualFold(as.Value, "as") && as.Next != nil {
+		return strings.ToLower(as.Next.Value)
+	}
+	return ""
+}
+
+// namedContext returns the named context replacing the image name, keyed
+// like the name in the Dockerfile or by its normalized reference.
+func namedContext(contexts map[string]NamedContext, name string) (NamedContext, bool) {
+	if nc, ok := contexts[name]; ok {
+		return nc, true
+	}
+	ref, err := reference.ParseNormalizedNamed(name)
+	if err != nil {
+		return NamedContext{}, false
+	}
+	for _, k := range []string{ref.String(), reference.TagNameOnly(ref).String(), reference.FamiliarString(ref)} {
+		if nc, ok := contexts[k]; ok {
+			return nc, true
+		}
+	}
+	return NamedContext{}, false
+}
+
+// readDockerfile reads the Dockerfile of local inputs.
+func readDockerfile(inp Inputs) ([]byte, error) {
+	if inp.DockerfileInline != "" {
+		return []byte(inp.DockerfileInline), nil
+	}
+	if inp.DockerfilePath == "-" || isHTTPURL(inp.DockerfilePath) {
+		return nil, errors.New("base image verification requires a local Dockerfile")
+	}
+
+	p := inp.DockerfilePath
+	switch {
+	case p == "" && osutil.IsLocalDir(inp.ContextPath):
+		p = filepath.Join(inp.ContextPath, handleLowercaseDockerfile(inp.ContextPath, "Dockerfile"))
+	case p == "" || (IsRemoteURL(inp.ContextPath) && !filepath.IsAbs(p)):
+		return nil, errors.New("base image verification requires a local Dockerfile")
+	default:
+		p = filepath.Join(filepath.Dir(p), handleLowercaseDockerfile(filepath.Dir(p), filepath.Base(p)))
+	}
+	dt, err := os.ReadFile(p)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read Dockerfile")
+	}
+	return dt, nil
+}
@@ -0,0 +1,139 @@
+package build
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/distribution/reference"
+	"github.com/docker/buildx/util/imagetools"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBaseImagePolicy(t *testing.T) {
+	pol, err := parseBaseImagePolicy([]byte(`{"repositories":["docker.io/library/*"],"builderIds":["https://github.com/*"],"maxAge":"30d"}`), "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"docker.io/library/*"}, pol.Repositories)
+	require.Equal(t, []string{"https://github.com/*"}, pol.BuilderIDs)
+	require.Equal(t, 30*24*time.Hour, pol.MaxAge)
+
+	pol, err = parseBaseImagePolicy([]byte(`{"maxAge":"12h"}`), "")
+	require.NoError(t, err)
+	require.Equal(t, 12*time.Hour, pol.MaxAge)
+
+	_, err = parseBaseImagePolicy([]byte(`{"maxAge":"-1d"}`), "")
+	require.ErrorContains(t, err, "must be positive")
+
+	_, err = parseBaseImagePolicy([]byte(`{"repository":["alpine"]}`), "")
+	require.ErrorContains(t, err, "unknown field")
+
+	// keys are relative to the directory of the policy
+	dir := t.TempDir()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cosign.pub"), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600))
+	pol, err = parseBaseImagePolicy([]byte(`{"keys":["cosign.pub"]}`), dir)
+	require.NoError(t, err)
+	require.Len(t, pol.Keys, 1)
+	require.True(t, key.PublicKey.Equal(pol.Keys[0]))
+
+	_, err = parseBaseImagePolicy([]byte(`{"keys":["missing.pub"]}`), dir)
+	require.ErrorContains(t, err, "failed to read key")
+}
+
+func TestBaseImagePolicyEvaluate(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	recent := now.Add(-24 * time.Hour)
+	old := now.Add(-90 * 24 * time.Hour)
+	pol := &BaseImagePolicy{
+		Repositories: []string{"docker.io/library/*", "ghcr.io/myorg/*"},
+		BuilderIDs:   []string{"https://github.com/myorg/*"},
+		Sources:      []string{"https://github.com/myorg/*"},
+		MaxAge:       30 * 24 * time.Hour,
+	}
+	good := imagetools.ImageOrigin{
+		Platform:   "linux/amd64",
+		Provenance: true,
+		BuilderID:  "https://github.com/myorg/runner",
+		Sources:    []string{"https://github.com/myorg/base.git"},
+		Created:    &recent,
+	}
+
+	ref, err := reference.ParseNormalizedNamed("ghcr.io/myorg/base:1.0")
+	require.NoError(t, err)
+	require.Empty(t, pol.evaluate(ref, []imagetools.ImageOrigin{good}, false, now))
+
+	ref, err = reference.ParseNormalizedNamed("ghcr.io/other/base:1.0")
+	require.NoError(t, err)
+	require.Equal(t, []string{"repository ghcr.io/other/base is not allowed"}, pol.evaluate(ref, []imagetools.ImageOrigin{good}, false, now))
+
+	ref, err = reference.ParseNormalizedNamed("alpine")
+	require.NoError(t, err)
+	bad := good
+	bad.BuilderID = "https://example.com/builder"
+	bad.Sources = []string{"https://github.com/other/base.git"}
+	bad.Created = &old
+	require.Equal(t, []string{
+		`linux/amd64: builder "https://example.com/builder" is not allowed`,
+		"linux/amd64: sources https://github.com/other/base.git are not allowed",
+		"linux/amd64: created 2160h0m0s ago, exceeding the maximum age of 720h0m0s",
+	}, pol.evaluate(ref, []imagetools.ImageOrigin{bad}, false, now))
+
+	require.Equal(t, []string{
+		"linux/arm64: no provenance attestation",
+		"linux/arm64: unknown creation time",
+	}, pol.evaluate(ref, []imagetools.ImageOrigin{{Platform: "linux/arm64"}}, false, now))
+
+	// images must be signed if the policy has keys
+	ref, err = reference.ParseNormalizedNamed("ghcr.io/myorg/base:1.0")
+	require.NoError(t, err)
+	pol.Keys = []crypto.PublicKey{&ecdsa.PublicKey{}}
+	require.Equal(t, []string{"not signed with a key of the policy"}, pol.evaluate(ref, []imagetools.ImageOrigin{good}, false, now))
+	require.Empty(t, pol.evaluate(ref, []imagetools.ImageOrigin{good}, true, now))
+}
+
+func TestBaseImages(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte(`
+ARG GO_VERSION=1.22
+ARG ALPINE_VERSION
+FROM golang:${GO_VERSION}-alpine${ALPINE_VERSION} AS build
+ARG IGNORED=foo
+FROM build AS test
+FROM scratch AS empty
+FROM alpine
+FROM tools
+COPY --from=build /out /
+`), 0600))
+
+	names, err := baseImages(Inputs{ContextPath: dir}, map[string]string{"ALPINE_VERSION": "3.20"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"golang:1.22-alpine3.20", "alpine", "tools"}, names)
+
+	names, err = baseImages(Inputs{
+		ContextPath: dir,
+		NamedContexts: map[string]NamedContext{
+			"docker.io/library/alpine:latest": {Path: "docker-image://alpine:3.20"},
+			"tools":                           {Path: "target:tools"},
+			"extra":                           {Path: "docker-image://busybox"},
+		},
+	}, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"golang:1.22-alpine", "alpine:3.20", "busybox"}, names)
+
+	names, err = baseImages(Inputs{ContextPath: dir, DockerfileInline: "FROM busybox AS base\nFROM base\n"}, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"busybox"}, names)
+
+	_, err = baseImages(Inputs{ContextPath: "https://github.com/docker/buildx.git"}, nil)
+	require.ErrorContains(t, err, "requires a local Dockerfile")
+}
@@ -25,7 +25,6 @@ import (
 	"github.com/docker/buildx/util/dockerutil"
 	"github.com/docker/buildx/util/imagetools"
 	"github.com/docker/buildx/util/progress"
-	"github.com/docker/buildx/util/resolver"
 	"github.com/docker/buildx/util/waitmap"
 	"github.com/docker/cli/opts"
 	"github.com/docker/docker/api/types/image"
@@ -51,6 +50,7 @@ import (
 	fstypes "github.com/tonistiigi/fsutil/types"
 	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -79,6 +79,7 @@ type Options struct {
 	Platforms                  []specs.Platform
 	Pull                       bool
 	SecretSpecs                []*controllerapi.Secret
+	RegistryAuthSpecs          []*controllerapi.RegistryAuth
 	SSHSpecs                   []*controllerapi.SSH
 	ShmSize                    opts.MemBytes
 	Tags                       []string
@@ -91,6 +92,9 @@ type Options struct {
 	ProvenanceResponseMode confutil.MetadataProvenanceMode
 	SourcePolicy           *spb.Policy
 	GroupRef               string
+	RegistryAuth           imagetools.Auth   // overrides the registry credentials of the nodes
+	Signer                 imagetools.Signer // signs the pushed images
+	BaseImagePolicy        *BaseImagePolicy  // verifies the base images before the build
 }
 
 type CallFunc struct {
@@ -155,11 +159,81 @@ func toRepoOnly(in string) (string, error) {
 	return strings.Join(out, ","), nil
 }
 
+// RunOptions controls how the targets of a build are scheduled.
+type RunOptions struct {
+	// MaxParallelism is the maximum number of targets solved concurrently
+	// on a node. Zero means no limit.
+	MaxParallelism int
+	// ContinueOnError keeps building the targets that don't depend on a
+	// failed target instead of canceling the build on the first error. The
+	// errors of the failed targets are returned as TargetErrors.
+	ContinueOnError bool
+	// ResultHandleFunc is called with the result of each target, that is
+	// kept open to run containers from until ResultHandle.Done is called.
+	ResultHandleFunc func(target string, driverIndex int, res *ResultHandle)
+	// ResultHandleTargets restricts ResultHandleFunc to the results of
+	// these targets. The other targets are built without keeping their
+	// result. All the targets are kept if unset.
+	ResultHandleTargets []string
+}
+
+func (o RunOptions) keepResult(target string) bool {
+	if o.ResultHandleFunc == nil {
+		return false
+	}
+	return o.ResultHandleTargets == nil || slices.Contains(o.ResultHandleTargets, target)
+}
+
+// TargetErrors is the error returned by BuildWithRunOptions for the targets
+// that failed when RunOptions.ContinueOnError is set, by target name.
+type TargetErrors map[string]error
+
+func (e TargetErrors) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	msgs := make([]string, len(names))
+	for i, name := range names {
+		msgs[i] = e[name].Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e TargetErrors) Unwrap() []error {
+	errs := make([]error, 0, len(e))
+	for _, err := range e {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// DependencyError is the error of a target that was not built because a
+// target it depends on failed.
+type DependencyError struct {
+	Target string
+}
+
+func (e *DependencyError) Error() string {
+	return fmt.Sprintf("dependency %s failed", e.Target)
+}
+
 func Build(ctx context.Context, nodes []builder.Node, opts map[string]Options, docker *dockerutil.Client, cfg *confutil.Config, w progress.Writer) (resp map[string]*client.SolveResponse, err error) {
-	return BuildWithResultHandler(ctx, nodes, opts, docker, cfg, w, nil)
+	return BuildWithRunOptions(ctx, nodes, opts, RunOptions{}, docker, cfg, w)
 }
 
 func BuildWithResultHandler(ctx context.Context, nodes []builder.Node, opts map[string]Options, docker *dockerutil.Client, cfg *confutil.Config, w progress.Writer, resultHandleFunc func(driverIndex int, rCtx *ResultHandle)) (resp map[string]*client.SolveResponse, err error) {
+	var runOpt RunOptions
+	if resultHandleFunc != nil {
+		runOpt.ResultHandleFunc = func(_ string, driverIndex int, res *ResultHandle) {
+			resultHandleFunc(driverIndex, res)
+		}
+	}
+	return BuildWithRunOptions(ctx, nodes, opts, runOpt, docker, cfg, w)
+}
+
+func BuildWithRunOptions(ctx context.Context, nodes []builder.Node, opts map[string]Options, runOpt RunOptions, docker *dockerutil.Client, cfg *confutil.Config, w progress.Writer) (resp map[string]*client.SolveResponse, err error) {
 	if len(nodes) == 0 {
 		return nil, errors.Errorf("driver required for build")
 	}
@@ -227,6 +301,16 @@ func BuildWithResultHandler(ctx context.Context, nodes []builder.Node, opts map[
 		if opt.Ref == "" {
 			opt.Ref = identity.NewID()
 		}
+		if opt.BaseImagePolicy != nil {
+			imageopt, err := registryImageOpt(drivers[k], opt, "", false)
+			if err != nil {
+				return nil, err
+			}
+			opt.SourcePolicy, err = verifyBaseImages(ctx, opt, imageopt, w)
+			if err != nil {
+				return nil, err
+			}
+		}
 		var reqn []*reqForNode
 		for _, np := range drivers[k] {
 			if np.Node().Driver.IsMobyDriver() {
@@ -318,6 +402,19 @@ func BuildWithResultHandler(ctx context.Context, nodes []builder.Node, opts map[
 	multiTarget := len(opts) > 1
 	childTargets := calculateChildTargets(reqForNodes, opts)
 
+	// solves are limited per node and a slot is only taken once the
+	// dependencies of a target are solved so the targets waiting for their
+	// dependencies don't hold slots
+	sems := map[string]*semaphore.Weighted{}
+	if runOpt.MaxParallelism > 0 {
+		for _, n := range nodes {
+			sems[n.Name] = semaphore.NewWeighted(int64(runOpt.MaxParallelism))
+		}
+	}
+
+	failed := TargetErrors{}
+	var failedMu sync.Mutex
+
 	for k, opt := range opts {
 		err := func(k string) (err error) {
 			opt := opt
@@ -343,6 +440,13 @@ func BuildWithResultHandler(ctx context.Context, nodes []builder.Node, opts map[
 			var pushNames string
 			var insecurePush bool
 
+			var signNames []string
+			var signInsecure bool
+			if opt.Signer != nil && len(reqForNodes[k]) > 0 {
+				// names are collected before they are rewritten for multi-node pushes
+				signNames, signInsecure = pushedNames(reqForNodes[k][0].so.Exports)
+			}
+
 			for i, dp := range dps {
 				i, dp := i, dp
 				node := dp.Node()
@@ -375,7 +479,7 @@ func BuildWithResultHandler(ctx context.Context, nodes []builder.Node, opts map[
 					}
 				}
 
-				pw := progress.WithPrefix(w, k, multiTarget)
+				pw := progress.WithPrefix(progress.WithTarget(w, k, node.Name), k, multiTarget)
 
 				c, err := dp.Client(ctx)
 				if err != nil {
@@ -407,10 +511,18 @@ func BuildWithResultHandler(ctx context.Context, nodes []builder.Node, opts map[
 					done = wg.Done
 				}
 
-				eg2.Go(func() error {
+				eg2.Go(func() (err error) {
 					if done != nil {
 						defer done()
 					}
+					if runOpt.ContinueOnError {
+						// unblock the targets depending on this one
+						defer func() {
+							if err != nil {
+								results.Set(resultKey(dp.driverIndex, k), &DependencyError{Target: k})
+							}
+						}()
+					}
 
 					pw = progress.ResetTime(pw)
 
@@ -418,6 +530,17 @@ func BuildWithResultHandler(ctx context.Context, nodes []builder.Node, opts map[
 						return err
 					}
 
+					release := func() {}
+					if sem, ok := sems[node.Name]; ok {
+						if err := sem.Acquire(ctx, 1); err != nil {
+							return err
+						}
+						release = sync.OnceFunc(func() {
+							sem.Release(1)
+						})
+						defer release()
+					}
+
 					frontendInputs := make(map[string]*pb.Definition)
 					for key, st := range so.FrontendInputs {
 						def, err := st.Marshal(ctx)
@@ -474,6 +597,10 @@ func BuildWithResultHandler(ctx context.Context, nodes []builder.Node, opts map[
 						results.Set(rKey, res)
 
 						if children, ok := childTargets[rKey]; ok && len(children) > 0 {
+							// the result is evaluated as part of the child targets
+							// solves so the slot is released for them
+							release()
+
 							// wait for the child targets to register their LLB before evaluating
 							_, err := results.Get(ctx, children...)
 							if err != nil {
@@ -499,10 +626,10 @@ func BuildWithResultHandler(ctx context.Context, nodes []builder.Node, opts map[
 					}
 					buildRef := fmt.Sprintf("%s/%s/%s", node.Builder, node.Name, so.Ref)
 					var rr *client.SolveResponse
-					if resultHandleFunc != nil {
+					if runOpt.keepResult(k) {
 						var resultHandle *ResultHandle
 						resultHandle, rr, err = NewResultHandle(ctx, cc, *so, "buildx", buildFunc, ch)
-						resultHandleFunc(dp.driverIndex, resultHandle)
+						runOpt.ResultHandleFunc(k, dp.driverIndex, resultHandle)
 					} else {
 						span, ctx := tracing.StartSpan(ctx, "build")
 						rr, err = c.Build(ctx, *so, "buildx", buildFunc, ch)
@@ -578,6 +705,19 @@ func BuildWithResultHandler(ctx context.Context, nodes []builder.Node, opts map[
 
 			eg.Go(func() (err error) {
 				ctx := baseCtx
+				if runOpt.ContinueOnError {
+					defer func() {
+						if err != nil {
+							failedMu.Lock()
+							failed[k] = err
+							failedMu.Unlock()
+							respMu.Lock()
+							delete(resp, k)
+							respMu.Unlock()
+							err = nil
+						}
+					}()
+				}
 				defer func() {
 					if span != nil {
 						tracing.FinishWithError(span, err)
@@ -595,11 +735,28 @@ func BuildWithResultHandler(ctx context.Context, nodes []builder.Node, opts map[
 					return err
 				}
 
+				sign := func() error {
+					if len(signNames) == 0 {
+						return nil
+					}
+					respMu.Lock()
+					dgst := resp[k].ExporterResponse[exptypes.ExporterImageDigestKey]
+					respMu.Unlock()
+					if dgst == "" {
+						return errors.Errorf("failed to sign %s: missing digest of the pushed image", signNames[0])
+					}
+					imageopt, err := registryImageOpt(dps, opt, signNames[0], signInsecure)
+					if err != nil {
+						return err
+					}
+					return signImage(ctx, opt.Signer, imageopt, signNames, digest.Digest(dgst), pw)
+				}
+
 				respMu.Lock()
 				resp[k] = res[0]
 				respMu.Unlock()
 				if len(res) == 1 {
-					return nil
+					return sign()
 				}
 
 				if pushNames != "" {
@@ -634,26 +791,10 @@ func BuildWithResultHandler(ctx context.Context, nodes []builder.Node, opts map[
 							}
 						}
 						if len(descs) > 0 {
-							var imageopt imagetools.Opt
-							for _, dp := range dps {
-								imageopt = dp.Node().ImageOpt
-								break
-							}
 							names := strings.Split(pushNames, ",")
-
-							if insecurePush {
-								insecureTrue := true
-								httpTrue := true
-								nn, err := reference.ParseNormalizedNamed(names[0])
-								if err != nil {
-									return err
-								}
-								imageopt.RegistryConfig = map[string]resolver.RegistryConfig{
-									reference.Domain(nn): {
-										Insecure:  &insecureTrue,
-										PlainHTTP: &httpTrue,
-									},
-								}
+							imageopt, err := registryImageOpt(dps, opt, names[0], insecurePush)
+							if err != nil {
+								return err
 							}
 
 							itpull := imagetools.New(imageopt)
@@ -708,7 +849,7 @@ func BuildWithResultHandler(ctx context.Context, nodes []builder.Node, opts map[
 						return err
 					}
 				}
-				return nil
+				return sign()
 			})
 
 			return nil
@@ -721,6 +862,9 @@ func BuildWithResultHandler(ctx context.Context, nodes []builder.Node, opts map[
 	if err := eg.Wait(); err != nil {
 		return nil, err
 	}
+	if len(failed) > 0 {
+		return resp, failed
+	}
 
 	return resp, nil
 }
@@ -1014,6 +1158,9 @@ func waitContextDeps(ctx context.Context, index int, results *waitmap.Map, so *c
 		if !ok {
 			continue
 		}
+		if derr, ok := r.(*DependencyError); ok {
+			return derr
+		}
 		rr, ok := r.(*gateway.Result)
 		if !ok {
 			return errors.Errorf("invalid result type %T", rr)
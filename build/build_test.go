@@ -0,0 +1,49 @@
+package build
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/buildx/util/waitmap"
+	"github.com/moby/buildkit/client"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTargetErrors(t *testing.T) {
+	err := error(TargetErrors{
+		"foo": errors.Wrap(&DependencyError{Target: "bar"}, "target foo"),
+		"bar": errors.New("target bar: failed to solve"),
+	})
+	require.Equal(t, "target bar: failed to solve\ntarget foo: dependency bar failed", err.Error())
+
+	var derr *DependencyError
+	require.ErrorAs(t, err, &derr)
+	require.Equal(t, "bar", derr.Target)
+}
+
+func TestRunOptionsKeepResult(t *testing.T) {
+	require.False(t, RunOptions{}.keepResult("app"))
+
+	fn := func(string, int, *ResultHandle) {}
+	require.True(t, RunOptions{ResultHandleFunc: fn}.keepResult("app"))
+
+	runOpt := RunOptions{ResultHandleFunc: fn, ResultHandleTargets: []string{"app"}}
+	require.True(t, runOpt.keepResult("app"))
+	require.False(t, runOpt.keepResult("docs"))
+}
+
+func TestWaitContextDepsFailed(t *testing.T) {
+	results := waitmap.New()
+	results.Set(resultKey(0, "base"), &DependencyError{Target: "base"})
+
+	so := &client.SolveOpt{
+		FrontendAttrs: map[string]string{
+			"context:base": "target:base",
+		},
+	}
+	err := waitContextDeps(context.TODO(), 0, results, so)
+	var derr *DependencyError
+	require.ErrorAs(t, err, &derr)
+	require.Equal(t, "base", derr.Target)
+}
@@ -22,9 +22,17 @@ type Container struct {
 	container       gateway.Container
 	releaseCh       chan struct{}
 	resultCtx       *ResultHandle
+	platform        string
 }
 
 func NewContainer(ctx context.Context, resultCtx *ResultHandle, cfg *controllerapi.InvokeConfig) (*Container, error) {
+	return NewPlatformContainer(ctx, resultCtx, cfg, "")
+}
+
+// NewPlatformContainer is like NewContainer, for the result of a platform of
+// a multi-platform build, as returned by ResultHandle.Platforms. The first
+// platform is used if platform is empty.
+func NewPlatformContainer(ctx context.Context, resultCtx *ResultHandle, cfg *controllerapi.InvokeConfig, platform string) (*Container, error) {
 	mainCtx := ctx
 
 	ctrCh := make(chan *Container)
@@ -37,7 +45,7 @@ func NewContainer(ctx context.Context, resultCtx *ResultHandle, cfg *controllera
 				cancel(errors.WithStack(context.Canceled))
 			}()
 
-			containerCfg, err := resultCtx.getContainerConfig(cfg)
+			containerCfg, err := resultCtx.getContainerConfig(cfg, platform)
 			if err != nil {
 				return nil, err
 			}
@@ -53,6 +61,7 @@ func NewContainer(ctx context.Context, resultCtx *ResultHandle, cfg *controllera
 				container:       bkContainer,
 				releaseCh:       releaseCh,
 				resultCtx:       resultCtx,
+				platform:        platform,
 			}
 			doneCh := make(chan struct{})
 			defer close(doneCh)
@@ -104,7 +113,7 @@ func (c *Container) Exec(ctx context.Context, cfg *controllerapi.InvokeConfig, s
 			c.markUnavailable()
 		}()
 	}
-	err := exec(ctx, c.resultCtx, cfg, c.container, stdin, stdout, stderr)
+	err := exec(ctx, c.resultCtx, cfg, c.platform, c.container, stdin, stdout, stderr)
 	if err != nil {
 		// Container becomes unavailable if one of the processes fails in it.
 		c.markUnavailable()
@@ -112,8 +121,8 @@ func (c *Container) Exec(ctx context.Context, cfg *controllerapi.InvokeConfig, s
 	return err
 }
 
-func exec(ctx context.Context, resultCtx *ResultHandle, cfg *controllerapi.InvokeConfig, ctr gateway.Container, stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser) error {
-	processCfg, err := resultCtx.getProcessConfig(cfg, stdin, stdout, stderr)
+func exec(ctx context.Context, resultCtx *ResultHandle, cfg *controllerapi.InvokeConfig, platform string, ctr gateway.Container, stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser) error {
+	processCfg, err := resultCtx.getProcessConfig(cfg, platform, stdin, stdout, stderr)
 	if err != nil {
 		return err
 	}
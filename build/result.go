@@ -292,10 +292,27 @@ func (r *ResultHandle) build(buildFunc gateway.BuildFunc) (err error) {
 	return err
 }
 
-func (r *ResultHandle) getContainerConfig(cfg *controllerapi.InvokeConfig) (containerCfg gateway.NewContainerRequest, _ error) {
+// Platforms returns the IDs of the platforms of the successful build result,
+// in the format of platforms.Format.
+func (r *ResultHandle) Platforms() ([]string, error) {
+	if r.res == nil || r.solveErr != nil {
+		return nil, errors.Errorf("no successful build result")
+	}
+	ps, err := exptypes.ParsePlatforms(r.res.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(ps.Platforms))
+	for i, p := range ps.Platforms {
+		ids[i] = p.ID
+	}
+	return ids, nil
+}
+
+func (r *ResultHandle) getContainerConfig(cfg *controllerapi.InvokeConfig, platform string) (containerCfg gateway.NewContainerRequest, _ error) {
 	if r.res != nil && r.solveErr == nil {
 		logrus.Debugf("creating container from successful build")
-		ccfg, err := containerConfigFromResult(r.res, cfg)
+		ccfg, err := containerConfigFromResult(r.res, cfg, platform)
 		if err != nil {
 			return containerCfg, err
 		}
@@ -311,11 +328,11 @@ func (r *ResultHandle) getContainerConfig(cfg *controllerapi.InvokeConfig) (cont
 	return containerCfg, nil
 }
 
-func (r *ResultHandle) getProcessConfig(cfg *controllerapi.InvokeConfig, stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser) (_ gateway.StartRequest, err error) {
+func (r *ResultHandle) getProcessConfig(cfg *controllerapi.InvokeConfig, platform string, stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser) (_ gateway.StartRequest, err error) {
 	processCfg := newStartRequest(stdin, stdout, stderr)
 	if r.res != nil && r.solveErr == nil {
 		logrus.Debugf("creating container from successful build")
-		if err := populateProcessConfigFromResult(&processCfg, r.res, cfg); err != nil {
+		if err := populateProcessConfigFromResult(&processCfg, r.res, cfg, platform); err != nil {
 			return processCfg, err
 		}
 	} else {
@@ -327,21 +344,23 @@ func (r *ResultHandle) getProcessConfig(cfg *controllerapi.InvokeConfig, stdin i
 	return processCfg, nil
 }
 
-func containerConfigFromResult(res *gateway.Result, cfg *controllerapi.InvokeConfig) (*gateway.NewContainerRequest, error) {
+// containerConfigFromResult returns the config of a container started from
+// the result of the platform, or of the first platform if empty.
+func containerConfigFromResult(res *gateway.Result, cfg *controllerapi.InvokeConfig, platform string) (*gateway.NewContainerRequest, error) {
 	if cfg.Initial {
 		return nil, errors.Errorf("starting from the container from the initial state of the step is supported only on the failed steps")
 	}
 
-	ps, err := exptypes.ParsePlatforms(res.Metadata)
+	p, err := resultPlatform(res, platform)
 	if err != nil {
 		return nil, err
 	}
-	ref, ok := res.FindRef(ps.Platforms[0].ID)
+	ref, ok := res.FindRef(p.ID)
 	if !ok {
 		return nil, errors.Errorf("no reference found")
 	}
 
-	return &gateway.NewContainerRequest{
+	req := &gateway.NewContainerRequest{
 		Mounts: []gateway.Mount{
 			{
 				Dest:      "/",
@@ -349,11 +368,46 @@ func containerConfigFromResult(res *gateway.Result, cfg *controllerapi.InvokeCon
 				Ref:       ref,
 			},
 		},
-	}, nil
+	}
+	if platform != "" {
+		// run the processes of other platforms with emulation
+		req.Platform = &pb.Platform{
+			OS:           p.Platform.OS,
+			Architecture: p.Platform.Architecture,
+			Variant:      p.Platform.Variant,
+			OSVersion:    p.Platform.OSVersion,
+			OSFeatures:   p.Platform.OSFeatures,
+		}
+	}
+	return req, nil
 }
 
-func populateProcessConfigFromResult(req *gateway.StartRequest, res *gateway.Result, cfg *controllerapi.InvokeConfig) error {
+// resultPlatform returns the platform of the result with the ID platform, or
+// the first platform if empty.
+func resultPlatform(res *gateway.Result, platform string) (exptypes.Platform, error) {
+	ps, err := exptypes.ParsePlatforms(res.Metadata)
+	if err != nil {
+		return exptypes.Platform{}, err
+	}
+	if platform == "" {
+		return ps.Platforms[0], nil
+	}
+	for _, p := range ps.Platforms {
+		if p.ID == platform {
+			return p, nil
+		}
+	}
+	return exptypes.Platform{}, errors.Errorf("no result for platform %s", platform)
+}
+
+func populateProcessConfigFromResult(req *gateway.StartRequest, res *gateway.Result, cfg *controllerapi.InvokeConfig, platform string) error {
 	imgData := res.Metadata[exptypes.ExporterImageConfigKey]
+	if p, err := resultPlatform(res, platform); err == nil {
+		// the configs of multi-platform results are keyed by platform
+		if dt, ok := res.Metadata[exptypes.ExporterImageConfigKey+"/"+p.ID]; ok {
+			imgData = dt
+		}
+	}
 	var img *specs.Image
 	if len(imgData) > 0 {
 		img = &specs.Image{}
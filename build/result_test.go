@@ -0,0 +1,46 @@
+package build
+
+import (
+	"encoding/json"
+	"testing"
+
+	controllerapi "github.com/docker/buildx/controller/pb"
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	gateway "github.com/moby/buildkit/frontend/gateway/client"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultPlatform(t *testing.T) {
+	res := &gateway.Result{}
+	ps := exptypes.Platforms{
+		Platforms: []exptypes.Platform{
+			{ID: "linux/amd64", Platform: specs.Platform{OS: "linux", Architecture: "amd64"}},
+			{ID: "linux/arm64", Platform: specs.Platform{OS: "linux", Architecture: "arm64"}},
+		},
+	}
+	dt, err := json.Marshal(ps)
+	require.NoError(t, err)
+	res.AddMeta(exptypes.ExporterPlatformsKey, dt)
+	for _, p := range ps.Platforms {
+		dt, err := json.Marshal(specs.Image{
+			Platform: p.Platform,
+			Config:   specs.ImageConfig{Env: []string{"ARCH=" + p.Platform.Architecture}},
+		})
+		require.NoError(t, err)
+		res.AddMeta(exptypes.ExporterImageConfigKey+"/"+p.ID, dt)
+	}
+
+	p, err := resultPlatform(res, "")
+	require.NoError(t, err)
+	require.Equal(t, "linux/amd64", p.ID)
+	p, err = resultPlatform(res, "linux/arm64")
+	require.NoError(t, err)
+	require.Equal(t, "arm64", p.Platform.Architecture)
+	_, err = resultPlatform(res, "linux/s390x")
+	require.EqualError(t, err, "no result for platform linux/s390x")
+
+	req := newStartRequest(nil, nil, nil)
+	require.NoError(t, populateProcessConfigFromResult(&req, res, &controllerapi.InvokeConfig{NoUser: true, NoCwd: true}, "linux/arm64"))
+	require.Equal(t, []string{"ARCH=arm64"}, req.Env)
+}
@@ -0,0 +1,113 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/distribution/reference"
+	controllerapi "github.com/docker/buildx/controller/pb"
+	"github.com/docker/buildx/util/imagetools"
+	"github.com/docker/buildx/util/progress"
+	"github.com/docker/buildx/util/resolver"
+	"github.com/moby/buildkit/client"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+// registryImageOpt returns the options to access the registries of the
+// build, with the registry of name configured as insecure if set.
+func registryImageOpt(dps []*resolvedNode, opt Options, name string, insecure bool) (imagetools.Opt, error) {
+	var imageopt imagetools.Opt
+	for _, dp := range dps {
+		imageopt = dp.Node().ImageOpt
+		break
+	}
+	if opt.RegistryAuth != nil {
+		imageopt.Auth = opt.RegistryAuth
+	}
+	if insecure {
+		insecureTrue := true
+		httpTrue := true
+		nn, err := reference.ParseNormalizedNamed(name)
+		if err != nil {
+			return imagetools.Opt{}, err
+		}
+		imageopt.RegistryConfig = map[string]resolver.RegistryConfig{
+			reference.Domain(nn): {
+				Insecure:  &insecureTrue,
+				PlainHTTP: &httpTrue,
+			},
+		}
+	}
+	return imageopt, nil
+}
+
+// pushedNames returns the names the image exports push to, and whether the
+// registry is insecure.
+func pushedNames(exports []client.ExportEntry) ([]string, bool) {
+	var names []string
+	var insecure bool
+	for _, e := range exports {
+		if e.Type != client.ExporterImage && e.Type != "moby" {
+			continue
+		}
+		if ok, _ := strconv.ParseBool(e.Attrs["push"]); !ok {
+			continue
+		}
+		for _, n := range strings.Split(e.Attrs["name"], ",") {
+			if n = strings.TrimSpace(n); n != "" {
+				names = append(names, n)
+			}
+		}
+		if ok, _ := strconv.ParseBool(e.Attrs["registry.insecure"]); ok {
+			insecure = true
+		}
+	}
+	return names, insecure
+}
+
+// NewSigner returns the signer of the sign options of a build.
+func NewSigner(opt *controllerapi.SignOptions) (imagetools.Signer, error) {
+	if opt.Keyless {
+		return imagetools.NewKeylessSigner(opt.FulcioURL, opt.RekorURL), nil
+	}
+	dt, err := os.ReadFile(opt.Key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read signing key")
+	}
+	return imagetools.NewKeySigner(dt, opt.RekorURL)
+}
+
+// signImage signs the pushed manifest dgst in the repositories of names. It
+// runs right after the push, so the image is only public without its
+// signature until the signature is pushed.
+func signImage(ctx context.Context, signer imagetools.Signer, imageopt imagetools.Opt, names []string, dgst digest.Digest, pw progress.Writer) error {
+	r := imagetools.New(imageopt)
+	seen := map[string]struct{}{}
+	for _, n := range names {
+		ref, err := reference.ParseNormalizedNamed(n)
+		if err != nil {
+			return err
+		}
+		if _, ok := seen[ref.Name()]; ok {
+			continue
+		}
+		seen[ref.Name()] = struct{}{}
+
+		err = progress.Wrap(fmt.Sprintf("signing %s@%s", ref.Name(), dgst), pw.Write, func(l progress.SubLogger) error {
+			desc, err := r.Sign(ctx, ref, dgst, signer)
+			if err != nil {
+				return err
+			}
+			l.Log(1, []byte(fmt.Sprintf("pushed signature %s\n", desc.Digest)))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
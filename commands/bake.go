@@ -15,6 +15,7 @@ import (
 	"strings"
 	"sync"
 	"text/tabwriter"
+	"time"
 
 	"github.com/containerd/console"
 	"github.com/containerd/platforms"
@@ -34,6 +35,7 @@ import (
 	"github.com/docker/buildx/util/progress"
 	"github.com/docker/buildx/util/tracing"
 	"github.com/docker/cli/cli/command"
+	"github.com/docker/docker/pkg/ioutils"
 	"github.com/moby/buildkit/identity"
 	"github.com/moby/buildkit/util/progress/progressui"
 	"github.com/pkg/errors"
@@ -42,20 +44,31 @@ import (
 )
 
 type bakeOptions struct {
-	files       []string
-	overrides   []string
-	printOnly   bool
-	listTargets bool
-	listVars    bool
-	sbom        string
-	provenance  string
-	allow       []string
+	files        []string
+	varFiles     []string
+	overrides    []string
+	printOnly    bool
+	explain      bool
+	listTargets  bool
+	listVars     bool
+	sbom         string
+	provenance   string
+	allow        []string
+	registryAuth []string
+	isolatedAuth bool
+	sign         string
 
 	builder      string
 	metadataFile string
+	summaryFile  string
 	exportPush   bool
 	exportLoad   bool
 	callFunc     string
+
+	maxParallelism int
+	failFast       bool
+	test           bool
+	junitFile      string
 }
 
 func runBake(ctx context.Context, dockerCli command.Cli, targets []string, in bakeOptions, cFlags commonFlags) (err error) {
@@ -69,16 +82,32 @@ func runBake(ctx context.Context, dockerCli command.Cli, targets []string, in ba
 		end(err)
 	}()
 
+	if in.maxParallelism < 0 {
+		return errors.Errorf("invalid max parallelism %d", in.maxParallelism)
+	}
+	if in.junitFile != "" && !in.test {
+		return errors.New("--junit-file requires --test")
+	}
+
 	url, cmdContext, targets := bakeArgs(targets)
 	if len(targets) == 0 {
 		targets = []string{"default"}
 	}
 
+	if in.explain && !in.printOnly {
+		return errors.New("--explain requires --print")
+	}
+
 	callFunc, err := buildflags.ParseCallFunc(in.callFunc)
 	if err != nil {
 		return err
 	}
 
+	sign, err := buildflags.ParseSign(in.sign)
+	if err != nil {
+		return err
+	}
+
 	overrides := in.overrides
 	if in.exportPush {
 		overrides = append(overrides, "*.push=true")
@@ -101,6 +130,9 @@ func runBake(ctx context.Context, dockerCli command.Cli, targets []string, in ba
 	if in.provenance != "" {
 		overrides = append(overrides, fmt.Sprintf("*.attest=%s", buildflags.CanonicalizeAttest("provenance", in.provenance)))
 	}
+	for _, v := range in.registryAuth {
+		overrides = append(overrides, "*.registry-auth="+v)
+	}
 	contextPathHash, _ := os.Getwd()
 
 	ent, err := bake.ParseEntitlements(in.allow)
@@ -154,13 +186,17 @@ func runBake(ctx context.Context, dockerCli command.Cli, targets []string, in ba
 
 	makePrinter := func() error {
 		var err error
-		printer, err = progress.NewPrinter(ctx2, os.Stderr, progressMode,
+		printerOpts := []progress.PrinterOpt{
 			progress.WithDesc(progressTextDesc, progressConsoleDesc),
 			progress.WithMetrics(mp, attributes),
 			progress.WithOnClose(func() {
-				printWarnings(os.Stderr, printer.Warnings(), progressMode)
+				printWarnings(os.Stderr, printer.Warnings(), printer.DisplayMode())
 			}),
-		)
+		}
+		if in.summaryFile != "" {
+			printerOpts = append(printerOpts, progress.WithSummary())
+		}
+		printer, err = progress.NewPrinter(ctx2, os.Stderr, progressMode, printerOpts...)
 		return err
 	}
 
@@ -173,10 +209,37 @@ func runBake(ctx context.Context, dockerCli command.Cli, targets []string, in ba
 		return err
 	}
 
+	// remote imports are read with the builder, that is otherwise not
+	// loaded to print the definition
+	ifiles, err := bake.ReadRemoteImports(ctx, files, func() ([]builder.Node, error) {
+		if nodes != nil {
+			return nodes, nil
+		}
+		b, err := builder.New(dockerCli,
+			builder.WithName(in.builder),
+			builder.WithContextPathHash(contextPathHash),
+		)
+		if err != nil {
+			return nil, err
+		}
+		nodes, err = b.LoadNodes(ctx)
+		return nodes, err
+	}, printer)
+	if err != nil {
+		return err
+	}
+	files = append(files, ifiles...)
+
 	if len(files) == 0 {
 		return errors.New("couldn't find a bake definition")
 	}
 
+	varFiles, err := bake.ReadVarFiles(in.varFiles)
+	if err != nil {
+		return err
+	}
+	files = append(files, varFiles...)
+
 	defaults := map[string]string{
 		// don't forget to update documentation if you add a new
 		// built-in variable: docs/bake-reference.md#built-in-variables
@@ -199,14 +262,25 @@ func runBake(ctx context.Context, dockerCli command.Cli, targets []string, in ba
 		}
 	}
 
-	tgts, grps, err := bake.ReadTargets(ctx, files, targets, overrides, defaults, &ent)
+	definition, err := bake.ReadDefinition(ctx, files, targets, overrides, defaults, &ent)
 	if err != nil {
 		return err
 	}
+	tgts, grps := definition.Targets, definition.Groups
+
+	var tests []*bake.Test
+	if in.test {
+		tests = definition.Tests
+	}
+
+	var explain map[string]map[string][]bake.Origin
+	if in.explain {
+		explain = definition.Origins
+	}
 
 	if v := os.Getenv("SOURCE_DATE_EPOCH"); v != "" {
 		// TODO: extract env var parsing to a method easily usable by library consumers
-		for _, t := range tgts {
+		for name, t := range tgts {
 			if _, ok := t.Args["SOURCE_DATE_EPOCH"]; ok {
 				continue
 			}
@@ -214,21 +288,26 @@ func runBake(ctx context.Context, dockerCli command.Cli, targets []string, in ba
 				t.Args = map[string]*string{}
 			}
 			t.Args["SOURCE_DATE_EPOCH"] = &v
+			if explain != nil {
+				explain[name]["args"] = append(explain[name]["args"], bake.Origin{Env: "SOURCE_DATE_EPOCH"})
+			}
 		}
 	}
 
 	// this function can update target context string from the input so call before printOnly check
-	bo, err := bake.TargetsToBuildOpt(tgts, inp)
+	bo, err := bake.TargetsToBuildOpt(ctx, tgts, inp, in.isolatedAuth)
 	if err != nil {
 		return err
 	}
 
 	def := struct {
-		Group  map[string]*bake.Group  `json:"group,omitempty"`
-		Target map[string]*bake.Target `json:"target"`
+		Group   map[string]*bake.Group              `json:"group,omitempty"`
+		Target  map[string]*bake.Target             `json:"target"`
+		Explain map[string]map[string][]bake.Origin `json:"explain,omitempty"`
 	}{
-		Group:  grps,
-		Target: tgts,
+		Group:   grps,
+		Target:  tgts,
+		Explain: explain,
 	}
 
 	if in.printOnly {
@@ -243,6 +322,10 @@ func runBake(ctx context.Context, dockerCli command.Cli, targets []string, in ba
 		return err
 	}
 
+	if len(bo) == 0 {
+		return errors.New("all the targets to build are disabled")
+	}
+
 	for _, opt := range bo {
 		if opt.CallFunc != nil {
 			cf, err := buildflags.ParseCallFunc(opt.CallFunc.Name)
@@ -253,10 +336,22 @@ func runBake(ctx context.Context, dockerCli command.Cli, targets []string, in ba
 		}
 	}
 
+	if sign != nil {
+		signer, err := build.NewSigner(sign)
+		if err != nil {
+			return err
+		}
+		for k, opt := range bo {
+			opt.Signer = signer
+			bo[k] = opt
+		}
+	}
+
 	exp, err := ent.Validate(bo)
 	if err != nil {
 		return err
 	}
+	ent.ValidateHooks(tgts, &exp)
 	if err := exp.Prompt(ctx, url != "", &syncWriter{w: dockerCli.Err(), wait: printer.Wait}); err != nil {
 		return err
 	}
@@ -271,21 +366,85 @@ func runBake(ctx context.Context, dockerCli command.Cli, targets []string, in ba
 		return err
 	}
 
+	if err := bake.RunPreHooks(ctx, tgts, printer); err != nil {
+		return err
+	}
+
 	done := timeBuildCommand(mp, attributes)
-	resp, retErr := build.Build(ctx, nodes, bo, dockerutil.NewClient(dockerCli), confutil.NewConfig(dockerCli), printer)
+	runOpt := build.RunOptions{
+		MaxParallelism:  in.maxParallelism,
+		ContinueOnError: !in.failFast,
+	}
+
+	// the results of the targets with tests are kept to start the test
+	// containers from
+	tests = slices.DeleteFunc(tests, func(t *bake.Test) bool {
+		opt, ok := bo[t.Target]
+		return !ok || opt.CallFunc != nil
+	})
+	testResults := map[string][]*build.ResultHandle{}
+	if len(tests) > 0 {
+		var testResultsMu sync.Mutex
+		for _, t := range tests {
+			if !slices.Contains(runOpt.ResultHandleTargets, t.Target) {
+				runOpt.ResultHandleTargets = append(runOpt.ResultHandleTargets, t.Target)
+			}
+		}
+		// a target built on multiple nodes has a result for each node,
+		// with the platforms built by the node
+		runOpt.ResultHandleFunc = func(target string, _ int, res *build.ResultHandle) {
+			if res == nil {
+				return
+			}
+			testResultsMu.Lock()
+			testResults[target] = append(testResults[target], res)
+			testResultsMu.Unlock()
+		}
+		defer func() {
+			for _, results := range testResults {
+				for _, res := range results {
+					res.Done()
+				}
+			}
+		}()
+	}
+
+	resp, retErr := build.BuildWithRunOptions(ctx, nodes, bo, runOpt, dockerutil.NewClient(dockerCli), confutil.NewConfig(dockerCli), printer)
+	resultNames := make([]string, 0, len(resp))
+	for name := range resp {
+		resultNames = append(resultNames, name)
+	}
+	slices.Sort(resultNames)
+	for _, name := range resultNames {
+		printer.WriteResult(name, resp[name].ExporterResponse)
+	}
 	if err := printer.Wait(); retErr == nil {
 		retErr = err
 	}
+	printer.WriteError(retErr)
+	if in.summaryFile != "" {
+		if err := writeSummaryFile(in.summaryFile, printer.Summary()); err != nil && retErr == nil {
+			retErr = err
+		}
+	}
+	// with --fail-fast=false, the results of the targets that succeeded are
+	// still written before returning the errors of the failed ones
+	var targetErrs build.TargetErrors
+	partial := errors.As(retErr, &targetErrs)
+	if !in.failFast && len(bo) > 1 && (retErr == nil || partial) && progressMode != progressui.QuietMode && progressMode != progressui.RawJSONMode && progressMode != progress.JSONMode {
+		printTargetResults(dockerCli.Err(), bo, targetErrs)
+	}
 	if retErr != nil {
 		err = wrapBuildError(retErr, true)
 	}
 	done(err)
 
-	if err != nil {
+	if err != nil && !partial {
 		return err
 	}
+	buildErr := err
 
-	if progressMode != progressui.QuietMode && progressMode != progressui.RawJSONMode {
+	if progressMode != progressui.QuietMode && progressMode != progressui.RawJSONMode && progressMode != progress.JSONMode {
 		desktop.PrintBuildDetails(os.Stderr, printer.BuildRefs(), term)
 	}
 	if len(in.metadataFile) > 0 {
@@ -303,6 +462,50 @@ func runBake(ctx context.Context, dockerCli command.Cli, targets []string, in ba
 		}
 	}
 
+	if in.test && buildErr == nil {
+		if err := makePrinter(); err != nil {
+			return err
+		}
+		results := bake.RunTests(ctx, tests, testResults, printer)
+		if err := printer.Wait(); err != nil {
+			return err
+		}
+		if in.junitFile != "" {
+			var buf bytes.Buffer
+			if err := bake.WriteJUnitReport(&buf, results); err != nil {
+				return err
+			}
+			if err := ioutils.AtomicWriteFile(in.junitFile, buf.Bytes(), 0644); err != nil {
+				return err
+			}
+		}
+		if err := printTestResults(dockerCli.Err(), results); err != nil {
+			return err
+		}
+	}
+
+	postHooks := make(map[string]map[string]any)
+	for name, r := range resp {
+		if t, ok := tgts[name]; ok && len(t.Hooks["post"]) > 0 && bo[name].CallFunc == nil {
+			postHooks[name] = decodeExporterResponse(r.ExporterResponse)
+		}
+	}
+	if len(postHooks) > 0 {
+		if err := makePrinter(); err != nil {
+			return err
+		}
+		err := bake.RunPostHooks(ctx, tgts, postHooks, printer)
+		if err2 := printer.Wait(); err == nil {
+			err = err2
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if buildErr != nil {
+		return buildErr
+	}
+
 	var callFormatJSON bool
 	jsonResults := map[string]map[string]any{}
 	if callFunc != nil {
@@ -429,6 +632,7 @@ func bakeCmd(dockerCli command.Cli, rootOpts *rootOptions) *cobra.Command {
 			}
 			options.builder = rootOpts.builder
 			options.metadataFile = cFlags.metadataFile
+			options.summaryFile = cFlags.summaryFile
 			// Other common flags (noCache, pull and progress) are processed in runBake function.
 			return runBake(cmd.Context(), dockerCli, args, options, cFlags)
 		},
@@ -438,14 +642,23 @@ func bakeCmd(dockerCli command.Cli, rootOpts *rootOptions) *cobra.Command {
 	flags := cmd.Flags()
 
 	flags.StringArrayVarP(&options.files, "file", "f", []string{}, "Build definition file")
+	flags.StringArrayVar(&options.varFiles, "var-file", nil, "Read the values of variables from a file")
 	flags.BoolVar(&options.exportLoad, "load", false, `Shorthand for "--set=*.output=type=docker"`)
 	flags.BoolVar(&options.printOnly, "print", false, "Print the options without building")
+	flags.BoolVar(&options.explain, "explain", false, "Print the origin of the values of the targets with --print")
 	flags.BoolVar(&options.exportPush, "push", false, `Shorthand for "--set=*.output=type=registry"`)
 	flags.StringVar(&options.sbom, "sbom", "", `Shorthand for "--set=*.attest=type=sbom"`)
 	flags.StringVar(&options.provenance, "provenance", "", `Shorthand for "--set=*.attest=type=provenance"`)
 	flags.StringArrayVar(&options.overrides, "set", nil, `Override target value (e.g., "targetpattern.key=value")`)
 	flags.StringVar(&options.callFunc, "call", "build", `Set method for evaluating build ("check", "outline", "targets")`)
 	flags.StringArrayVar(&options.allow, "allow", nil, "Allow build to access specified resources")
+	flags.StringArrayVar(&options.registryAuth, "registry-auth", nil, `Shorthand for "--set=*.registry-auth=..."`)
+	flags.BoolVar(&options.isolatedAuth, "registry-auth-isolated", false, "Only use the credentials of the registry-auth attribute of the targets, not the ones of the Docker config")
+	flags.StringVar(&options.sign, "sign", "", `Sign the pushed images with a cosign-compatible signature (format: "key=<path>", "keyless")`)
+	flags.IntVar(&options.maxParallelism, "max-parallelism", 0, "Maximum number of targets built concurrently on a node (0 for no limit)")
+	flags.BoolVar(&options.failFast, "fail-fast", true, "Cancel the build of all the targets when a target fails")
+	flags.BoolVar(&options.test, "test", false, "Run the tests of the targets after building them")
+	flags.StringVar(&options.junitFile, "junit-file", "", "Write the results of the tests to a file in the JUnit XML format")
 
 	flags.VarPF(callAlias(&options.callFunc, "check"), "check", "", `Shorthand for "--call=check"`)
 	flags.Lookup("check").NoOptDefVal = "true"
@@ -557,6 +770,64 @@ func readBakeFiles(ctx context.Context, nodes []builder.Node, url string, names
 	return
 }
 
+func printTargetResults(w io.Writer, bo map[string]build.Options, targetErrs build.TargetErrors) {
+	names := make([]string, 0, len(bo))
+	for name := range bo {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+	defer tw.Flush()
+
+	tw.Write([]byte("TARGET\tSTATUS\n"))
+	for _, name := range names {
+		status := "done"
+		if err, ok := targetErrs[name]; ok {
+			var derr *build.DependencyError
+			if errors.As(err, &derr) {
+				status = "skipped (" + derr.Error() + ")"
+			} else {
+				status = "failed"
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", name, status)
+	}
+}
+
+// printTestResults prints the result of each test and returns an error if a
+// test did not pass.
+func printTestResults(w io.Writer, results []*bake.TestResult) error {
+	if len(results) == 0 {
+		fmt.Fprintln(w, "No tests to run")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+	tw.Write([]byte("TEST\tTARGET\tRESULT\tDURATION\n"))
+
+	var failed int
+	for _, r := range results {
+		result := "pass"
+		if r.Error != nil {
+			result = "error: " + r.Error.Error()
+		} else if r.Failure != nil {
+			result = "fail: " + r.Failure.Error()
+		}
+		if !r.Passed() {
+			failed++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Name(), r.Test.Target, result, r.Duration.Round(time.Millisecond))
+	}
+	tw.Flush()
+
+	if failed > 0 {
+		return errors.Errorf("%d of %d tests failed", failed, len(results))
+	}
+	return nil
+}
+
 func printVars(w io.Writer, vars []*hclparser.Variable) error {
 	slices.SortFunc(vars, func(a, b *hclparser.Variable) int {
 		return cmp.Compare(a.Name, b.Name)
@@ -564,7 +835,7 @@ func printVars(w io.Writer, vars []*hclparser.Variable) error {
 	tw := tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
 	defer tw.Flush()
 
-	tw.Write([]byte("VARIABLE\tVALUE\tDESCRIPTION\n"))
+	tw.Write([]byte("VARIABLE\tVALUE\tSOURCE\tDESCRIPTION\n"))
 
 	for _, v := range vars {
 		var value string
@@ -573,7 +844,11 @@ func printVars(w io.Writer, vars []*hclparser.Variable) error {
 		} else {
 			value = "<null>"
 		}
-		fmt.Fprintf(tw, "%s\t%s\t%s\n", v.Name, value, v.Description)
+		source := v.Source
+		if source == "" {
+			source = "default"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", v.Name, value, source, v.Description)
 	}
 	return nil
 }
@@ -608,10 +883,13 @@ func printTargetList(w io.Writer, cfg *bake.Config) error {
 			continue
 		}
 		var descr string
+		var enabled *bool
 		if tgt.target != nil {
 			descr = tgt.target.Description
+			enabled = tgt.target.Enabled
 		} else if tgt.group != nil {
 			descr = tgt.group.Description
+			enabled = tgt.group.Enabled
 
 			if len(tgt.group.Targets) > 0 {
 				slices.Sort(tgt.group.Targets)
@@ -623,6 +901,13 @@ func printTargetList(w io.Writer, cfg *bake.Config) error {
 				}
 			}
 		}
+		if enabled != nil && !*enabled {
+			if descr != "" {
+				descr = "[disabled] " + descr
+			} else {
+				descr = "[disabled]"
+			}
+		}
 		fmt.Fprintf(tw, "%s\t%s\n", tgt.name, descr)
 	}
 
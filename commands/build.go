@@ -65,29 +65,33 @@ import (
 )
 
 type buildOptions struct {
-	allow          []string
-	annotations    []string
-	buildArgs      []string
-	cacheFrom      []string
-	cacheTo        []string
-	cgroupParent   string
-	contextPath    string
-	contexts       []string
-	dockerfileName string
-	extraHosts     []string
-	imageIDFile    string
-	labels         []string
-	networkMode    string
-	noCacheFilter  []string
-	outputs        []string
-	platforms      []string
-	callFunc       string
-	secrets        []string
-	shmSize        dockeropts.MemBytes
-	ssh            []string
-	tags           []string
-	target         string
-	ulimits        *dockeropts.UlimitOpt
+	allow                []string
+	annotations          []string
+	buildArgs            []string
+	cacheFrom            []string
+	cacheTo              []string
+	cgroupParent         string
+	contextPath          string
+	contexts             []string
+	dockerfileName       string
+	extraHosts           []string
+	imageIDFile          string
+	labels               []string
+	networkMode          string
+	noCacheFilter        []string
+	outputs              []string
+	platforms            []string
+	callFunc             string
+	registryAuth         []string
+	registryAuthIsolated bool
+	sign                 string
+	verifyBaseImage      string
+	secrets              []string
+	shmSize              dockeropts.MemBytes
+	ssh                  []string
+	tags                 []string
+	target               string
+	ulimits              *dockeropts.UlimitOpt
 
 	attests    []string
 	sbom       string
@@ -98,6 +102,7 @@ type buildOptions struct {
 
 	builder      string
 	metadataFile string
+	summaryFile  string
 	noCache      bool
 	pull         bool
 	exportPush   bool
@@ -122,26 +127,27 @@ func (o *buildOptions) toControllerOptions() (*controllerapi.BuildOptions, error
 	}
 
 	opts := controllerapi.BuildOptions{
-		Allow:          o.allow,
-		Annotations:    o.annotations,
-		BuildArgs:      buildArgs,
-		CgroupParent:   o.cgroupParent,
-		ContextPath:    o.contextPath,
-		DockerfileName: o.dockerfileName,
-		ExtraHosts:     o.extraHosts,
-		Labels:         labels,
-		NetworkMode:    o.networkMode,
-		NoCacheFilter:  o.noCacheFilter,
-		Platforms:      o.platforms,
-		ShmSize:        int64(o.shmSize),
-		Tags:           o.tags,
-		Target:         o.target,
-		Ulimits:        dockerUlimitToControllerUlimit(o.ulimits),
-		Builder:        o.builder,
-		NoCache:        o.noCache,
-		Pull:           o.pull,
-		ExportPush:     o.exportPush,
-		ExportLoad:     o.exportLoad,
+		Allow:           o.allow,
+		Annotations:     o.annotations,
+		BuildArgs:       buildArgs,
+		CgroupParent:    o.cgroupParent,
+		ContextPath:     o.contextPath,
+		DockerfileName:  o.dockerfileName,
+		ExtraHosts:      o.extraHosts,
+		Labels:          labels,
+		NetworkMode:     o.networkMode,
+		NoCacheFilter:   o.noCacheFilter,
+		Platforms:       o.platforms,
+		ShmSize:         int64(o.shmSize),
+		Tags:            o.tags,
+		Target:          o.target,
+		Ulimits:         dockerUlimitToControllerUlimit(o.ulimits),
+		Builder:         o.builder,
+		NoCache:         o.noCache,
+		Pull:            o.pull,
+		ExportPush:      o.exportPush,
+		ExportLoad:      o.exportLoad,
+		VerifyBaseImage: o.verifyBaseImage,
 	}
 
 	// TODO: extract env var parsing to a method easily usable by library consumers
@@ -192,6 +198,15 @@ func (o *buildOptions) toControllerOptions() (*controllerapi.BuildOptions, error
 		return nil, err
 	}
 
+	opts.RegistryAuth, err = buildflags.ParseRegistryAuthSpecs(o.registryAuth)
+	if err != nil {
+		return nil, err
+	}
+	opts.RegistryAuthIsolated = o.registryAuthIsolated
+	opts.Sign, err = buildflags.ParseSign(o.sign)
+	if err != nil {
+		return nil, err
+	}
 	opts.Secrets, err = buildflags.ParseSecretSpecs(o.secrets)
 	if err != nil {
 		return nil, err
@@ -332,16 +347,20 @@ func runBuild(ctx context.Context, dockerCli command.Cli, options buildOptions)
 		return err
 	}
 	var printer *progress.Printer
-	printer, err = progress.NewPrinter(ctx2, os.Stderr, progressMode,
+	printerOpts := []progress.PrinterOpt{
 		progress.WithDesc(
 			fmt.Sprintf("building with %q instance using %s driver", b.Name, b.Driver),
 			fmt.Sprintf("%s:%s", b.Driver, b.Name),
 		),
 		progress.WithMetrics(mp, attributes),
 		progress.WithOnClose(func() {
-			printWarnings(os.Stderr, printer.Warnings(), progressMode)
+			printWarnings(os.Stderr, printer.Warnings(), printer.DisplayMode())
 		}),
-	)
+	}
+	if options.summaryFile != "" {
+		printerOpts = append(printerOpts, progress.WithSummary())
+	}
+	printer, err = progress.NewPrinter(ctx2, os.Stderr, progressMode, printerOpts...)
 	if err != nil {
 		return err
 	}
@@ -356,9 +375,19 @@ func runBuild(ctx context.Context, dockerCli command.Cli, options buildOptions)
 		resp, inputs, retErr = runBasicBuild(ctx, dockerCli, opts, printer)
 	}
 
+	if resp != nil {
+		printer.WriteResult("default", resp.ExporterResponse)
+	}
 	if err := printer.Wait(); retErr == nil {
 		retErr = err
 	}
+	printer.WriteError(retErr)
+	if options.summaryFile != "" {
+		// written for failed builds too, to show the steps that ran
+		if err := writeSummaryFile(options.summaryFile, printer.Summary()); err != nil && retErr == nil {
+			retErr = err
+		}
+	}
 
 	done(retErr)
 	if retErr != nil {
@@ -366,7 +395,7 @@ func runBuild(ctx context.Context, dockerCli command.Cli, options buildOptions)
 	}
 
 	switch progressMode {
-	case progressui.RawJSONMode:
+	case progressui.RawJSONMode, progress.JSONMode:
 		// no additional display
 	case progressui.QuietMode:
 		fmt.Println(getImageID(resp.ExporterResponse))
@@ -555,6 +584,7 @@ func buildCmd(dockerCli command.Cli, rootOpts *rootOptions, debugConfig *debug.D
 			options.contextPath = args[0]
 			options.builder = rootOpts.builder
 			options.metadataFile = cFlags.metadataFile
+			options.summaryFile = cFlags.summaryFile
 			options.noCache = false
 			if cFlags.noCache != nil {
 				options.noCache = *cFlags.noCache
@@ -624,8 +654,13 @@ func buildCmd(dockerCli command.Cli, rootOpts *rootOptions, debugConfig *debug.D
 
 	flags.BoolVarP(&options.quiet, "quiet", "q", false, "Suppress the build output and print image ID on success")
 
+	flags.StringArrayVar(&options.registryAuth, "registry-auth", []string{}, `Credentials of a registry for this build (format: "host=ghcr.io,username=user,env=TOKEN")`)
+	flags.BoolVar(&options.registryAuthIsolated, "registry-auth-isolated", false, "Only use the credentials of --registry-auth, not the ones of the Docker config")
+
 	flags.StringArrayVar(&options.secrets, "secret", []string{}, `Secret to expose to the build (format: "id=mysecret[,src=/local/secret]")`)
 
+	flags.StringVar(&options.sign, "sign", "", `Sign the pushed image with a cosign-compatible signature (format: "key=<path>", "keyless")`)
+
 	flags.Var(&options.shmSize, "shm-size", `Shared memory size for build containers`)
 
 	flags.StringArrayVar(&options.ssh, "ssh", []string{}, `SSH agent socket or keys to expose to the build (format: "default|<id>[=<socket>|<key>[,<key>]]")`)
@@ -637,6 +672,8 @@ func buildCmd(dockerCli command.Cli, rootOpts *rootOptions, debugConfig *debug.D
 	options.ulimits = dockeropts.NewUlimitOpt(nil)
 	flags.Var(options.ulimits, "ulimit", "Ulimit options")
 
+	flags.StringVar(&options.verifyBaseImage, "verify-base-image", "", "Verify the base images against a policy file and pin them to their digests")
+
 	flags.StringArrayVar(&options.attests, "attest", []string{}, `Attestation parameters (format: "type=sbom,generator=image")`)
 	flags.StringVar(&options.sbom, "sbom", "", `Shorthand for "--attest=type=sbom"`)
 	flags.StringVar(&options.provenance, "provenance", "", `Shorthand for "--attest=type=provenance"`)
@@ -713,6 +750,7 @@ func buildCmd(dockerCli command.Cli, rootOpts *rootOptions, debugConfig *debug.D
 // comomnFlags is a set of flags commonly shared among subcommands.
 type commonFlags struct {
 	metadataFile string
+	summaryFile  string
 	progress     string
 	noCache      *bool
 	pull         *bool
@@ -720,9 +758,10 @@ type commonFlags struct {
 
 func commonBuildFlags(options *commonFlags, flags *pflag.FlagSet) {
 	options.noCache = flags.Bool("no-cache", false, "Do not use cache when building the image")
-	flags.StringVar(&options.progress, "progress", "auto", `Set type of progress output ("auto", "plain", "tty", "rawjson"). Use plain to show container output`)
+	flags.StringVar(&options.progress, "progress", "auto", `Set type of progress output ("auto", "plain", "tty", "rawjson", "json", "ci"). Use plain to show container output`)
 	options.pull = flags.Bool("pull", false, "Always attempt to pull all referenced images")
 	flags.StringVar(&options.metadataFile, "metadata-file", "", "Write build result metadata to a file")
+	flags.StringVar(&options.summaryFile, "summary-file", "", `Write a summary of step timings and cache usage to a file (JSON if the file name ends with ".json", Markdown otherwise)`)
 }
 
 func checkWarnedFlags(f *pflag.Flag) {
@@ -745,6 +784,21 @@ func writeMetadataFile(filename string, dt interface{}) error {
 	return ioutils.AtomicWriteFile(filename, b, 0644)
 }
 
+func writeSummaryFile(filename string, s *progress.Summary) error {
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		b, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return err
+		}
+		return ioutils.AtomicWriteFile(filename, b, 0644)
+	}
+	var buf bytes.Buffer
+	if err := s.WriteMarkdown(&buf); err != nil {
+		return err
+	}
+	return ioutils.AtomicWriteFile(filename, buf.Bytes(), 0644)
+}
+
 func decodeExporterResponse(exporterResponse map[string]string) map[string]interface{} {
 	decFunc := func(k, v string) ([]byte, error) {
 		if k == "result.json" {
@@ -850,7 +904,8 @@ func dockerUlimitToControllerUlimit(u *dockeropts.UlimitOpt) *controllerapi.Ulim
 }
 
 func printWarnings(w io.Writer, warnings []client.VertexWarning, mode progressui.DisplayMode) {
-	if len(warnings) == 0 || mode == progressui.QuietMode || mode == progressui.RawJSONMode {
+	// the CI display already annotates the warnings
+	if len(warnings) == 0 || mode == progressui.QuietMode || mode == progressui.RawJSONMode || mode == progress.JSONMode || mode == progress.CIMode {
 		return
 	}
 	fmt.Fprintf(w, "\n ")
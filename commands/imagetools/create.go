@@ -31,8 +31,17 @@ type createOptions struct {
 	actionAppend bool
 	progress     string
 	preferIndex  bool
+	attestations string
+	referrers    bool
+	platforms    []string
 }
 
+const (
+	attestationsInclude   = "include"
+	attestationsExclude   = "exclude"
+	attestationsReferrers = "referrers"
+)
+
 func runCreate(ctx context.Context, dockerCli command.Cli, in createOptions, args []string) error {
 	if len(args) == 0 && len(in.files) == 0 {
 		return errors.Errorf("no sources specified")
@@ -42,6 +51,12 @@ func runCreate(ctx context.Context, dockerCli command.Cli, in createOptions, arg
 		return errors.Errorf("can't push with no tags specified, please set --tag or --dry-run")
 	}
 
+	switch in.attestations {
+	case attestationsInclude, attestationsExclude, attestationsReferrers:
+	default:
+		return errors.Errorf("invalid attestations mode %q, valid modes are %s, %s and %s", in.attestations, attestationsInclude, attestationsExclude, attestationsReferrers)
+	}
+
 	fileArgs := make([]string, len(in.files), len(in.files)+len(args))
 	for i, f := range in.files {
 		dt, err := os.ReadFile(f)
@@ -67,6 +82,18 @@ func runCreate(ctx context.Context, dockerCli command.Cli, in createOptions, arg
 		return err
 	}
 
+	if len(in.platforms) > 0 {
+		sels, err := parsePlatformSelectors(in.platforms)
+		if err != nil {
+			return err
+		}
+		for _, s := range srcs {
+			if len(s.Platforms) == 0 {
+				s.Platforms = sels
+			}
+		}
+	}
+
 	repos := map[string]struct{}{}
 
 	for _, t := range tags {
@@ -160,6 +187,21 @@ func runCreate(ctx context.Context, dockerCli command.Cli, in createOptions, arg
 		return errors.Wrapf(err, "failed to parse annotations")
 	}
 
+	// referrers are looked up on the sources before their attestations are
+	// split, so the referrers of the source indexes are copied as well
+	referrerSrcs := srcs
+
+	var atts []*imagetools.Attestation
+	if in.attestations != attestationsInclude {
+		srcs, atts, err = r.SplitAttestations(ctx, srcs)
+		if err != nil {
+			return err
+		}
+		if in.attestations == attestationsExclude {
+			atts = nil
+		}
+	}
+
 	dt, desc, err := r.Combine(ctx, srcs, annotations, in.preferIndex)
 	if err != nil {
 		return err
@@ -167,6 +209,20 @@ func runCreate(ctx context.Context, dockerCli command.Cli, in createOptions, arg
 
 	if in.dryrun {
 		fmt.Printf("%s\n", dt)
+		for _, a := range atts {
+			fmt.Fprintf(dockerCli.Err(), "attestation %s would be pushed as referrer of %s\n", a.Source.Desc.Digest, a.Subject.Digest)
+		}
+		if in.referrers {
+			for _, s := range referrerSrcs {
+				descs, err := r.ListReferrers(ctx, s)
+				if err != nil {
+					return err
+				}
+				for _, d := range descs {
+					fmt.Fprintf(dockerCli.Err(), "referrer %s would be copied from %s\n", d.Digest, s.Ref)
+				}
+			}
+		}
 		return nil
 	}
 
@@ -203,7 +259,33 @@ func runCreate(ctx context.Context, dockerCli command.Cli, in createOptions, arg
 					return err
 				}
 				sub.Log(1, []byte(fmt.Sprintf("pushing %s to %s\n", desc.Digest.String(), t.String())))
-				return r.Push(ctx, t, desc, dt)
+				if err := r.Push(ctx, t, desc, dt); err != nil {
+					return err
+				}
+
+				for _, a := range atts {
+					ref, err := r.PushAttestation(ctx, a, t)
+					if err != nil {
+						return err
+					}
+					sub.Log(1, []byte(fmt.Sprintf("pushed attestation %s as referrer of %s to %s\n", ref.Digest.String(), a.Subject.Digest.String(), t.String())))
+				}
+
+				if in.referrers {
+					for _, s := range referrerSrcs {
+						if reference.Domain(s.Ref) == reference.Domain(t) && reference.Path(s.Ref) == reference.Path(t) {
+							continue
+						}
+						descs, err := r.CopyReferrers(ctx, s, t)
+						if err != nil {
+							return err
+						}
+						for _, d := range descs {
+							sub.Log(1, []byte(fmt.Sprintf("copied referrer %s from %s to %s\n", d.Digest.String(), s.Ref.String(), t.String())))
+						}
+					}
+				}
+				return nil
 			})
 		})
 	}
@@ -241,14 +323,42 @@ func parseRefs(in []string) ([]reference.Named, error) {
 	return refs, nil
 }
 
+func parsePlatformSelectors(in []string) ([]imagetools.PlatformSelector, error) {
+	var out []imagetools.PlatformSelector
+	for _, in := range in {
+		for _, v := range strings.Split(in, ",") {
+			s, err := imagetools.ParsePlatformSelector(strings.TrimSpace(v))
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
 func parseSource(in string) (*imagetools.Source, error) {
 	// source can be a digest, reference or a descriptor JSON
+	var sels []imagetools.PlatformSelector
+	if !strings.HasPrefix(in, "{") {
+		// digests and references can be suffixed with the platforms to take
+		// from the source, e.g. "foo:latest#linux/arm64"
+		if name, p, ok := strings.Cut(in, "#"); ok {
+			var err error
+			if sels, err = parsePlatformSelectors([]string{p}); err != nil {
+				return nil, err
+			}
+			in = name
+		}
+	}
+
 	dgst, err := digest.Parse(in)
 	if err == nil {
 		return &imagetools.Source{
 			Desc: ocispec.Descriptor{
 				Digest: dgst,
 			},
+			Platforms: sels,
 		}, nil
 	} else if strings.HasPrefix(in, "sha256") {
 		return nil, err
@@ -257,7 +367,8 @@ func parseSource(in string) (*imagetools.Source, error) {
 	ref, err := reference.ParseNormalizedNamed(in)
 	if err == nil {
 		return &imagetools.Source{
-			Ref: ref,
+			Ref:       ref,
+			Platforms: sels,
 		}, nil
 	} else if !strings.HasPrefix(in, "{") {
 		return nil, err
@@ -288,9 +399,12 @@ func createCmd(dockerCli command.Cli, opts RootOptions) *cobra.Command {
 	flags.StringArrayVarP(&options.tags, "tag", "t", []string{}, "Set reference for new image")
 	flags.BoolVar(&options.dryrun, "dry-run", false, "Show final image instead of pushing")
 	flags.BoolVar(&options.actionAppend, "append", false, "Append to existing manifest")
-	flags.StringVar(&options.progress, "progress", "auto", `Set type of progress output ("auto", "plain", "tty", "rawjson"). Use plain to show container output`)
+	flags.StringVar(&options.progress, "progress", "auto", `Set type of progress output ("auto", "plain", "tty", "rawjson", "json", "ci"). Use plain to show container output`)
 	flags.StringArrayVarP(&options.annotations, "annotation", "", []string{}, "Add annotation to the image")
 	flags.BoolVar(&options.preferIndex, "prefer-index", true, "When only a single source is specified, prefer outputting an image index or manifest list instead of performing a carbon copy")
+	flags.StringVar(&options.attestations, "attestations", attestationsInclude, `Set how attestation manifests of the sources are handled ("include", "exclude", "referrers")`)
+	flags.BoolVar(&options.referrers, "referrers", false, "Copy the OCI referrers of the sources, like signatures and SBOMs")
+	flags.StringArrayVar(&options.platforms, "platform", []string{}, "Only take the manifests of these platforms from the sources, optionally rewriting them (e.g., \"linux/arm64=linux/arm64/v8\")")
 
 	return cmd
 }
@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/docker/buildx/builder"
+	"github.com/docker/buildx/util/cobrautil/completion"
+	"github.com/docker/buildx/util/imagetools"
+	"github.com/docker/cli/cli"
+	"github.com/docker/cli/cli/command"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type diffOptions struct {
+	builder string
+	format  string
+}
+
+func runDiff(ctx context.Context, dockerCli command.Cli, in diffOptions, from, to string) error {
+	switch in.format {
+	case "pretty", "json":
+	default:
+		return errors.Errorf("invalid format %q, valid formats are pretty and json", in.format)
+	}
+
+	var imageopt imagetools.Opt
+	if !imagetools.IsLocalRef(from) || !imagetools.IsLocalRef(to) {
+		b, err := builder.New(dockerCli, builder.WithName(in.builder))
+		if err != nil {
+			return err
+		}
+		imageopt, err = b.ImageOpt()
+		if err != nil {
+			return err
+		}
+	}
+
+	d, err := imagetools.New(imageopt).Diff(ctx, from, to)
+	if err != nil {
+		return err
+	}
+
+	if in.format == "json" {
+		enc := json.NewEncoder(dockerCli.Out())
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	}
+	return d.Print(dockerCli.Out())
+}
+
+func diffCmd(dockerCli command.Cli, rootOpts RootOptions) *cobra.Command {
+	var options diffOptions
+
+	cmd := &cobra.Command{
+		Use:   "diff [OPTIONS] IMAGE1 IMAGE2",
+		Short: "Show differences between two images",
+		Args:  cli.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options.builder = *rootOpts.Builder
+			return runDiff(cmd.Context(), dockerCli, options, args[0], args[1])
+		},
+		ValidArgsFunction: completion.Disable,
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.format, "format", "pretty", `Format the output ("pretty", "json")`)
+
+	return cmd
+}
@@ -2,6 +2,7 @@ package commands
 
 import (
 	"context"
+	"os"
 
 	"github.com/docker/buildx/builder"
 	"github.com/docker/buildx/util/cobrautil/completion"
@@ -14,9 +15,10 @@ import (
 )
 
 type inspectOptions struct {
-	builder string
-	format  string
-	raw     bool
+	builder   string
+	format    string
+	raw       bool
+	verifyKey string
 }
 
 func runInspect(ctx context.Context, dockerCli command.Cli, in inspectOptions, name string) error {
@@ -24,13 +26,16 @@ func runInspect(ctx context.Context, dockerCli command.Cli, in inspectOptions, n
 		return errors.Errorf("format and raw cannot be used together")
 	}
 
-	b, err := builder.New(dockerCli, builder.WithName(in.builder))
-	if err != nil {
-		return err
-	}
-	imageopt, err := b.ImageOpt()
-	if err != nil {
-		return err
+	var imageopt imagetools.Opt
+	if !imagetools.IsLocalRef(name) {
+		b, err := builder.New(dockerCli, builder.WithName(in.builder))
+		if err != nil {
+			return err
+		}
+		imageopt, err = b.ImageOpt()
+		if err != nil {
+			return err
+		}
 	}
 
 	p, err := imagetools.NewPrinter(ctx, imageopt, name, in.format)
@@ -38,6 +43,18 @@ func runInspect(ctx context.Context, dockerCli command.Cli, in inspectOptions, n
 		return err
 	}
 
+	if in.verifyKey != "" {
+		dt, err := os.ReadFile(in.verifyKey)
+		if err != nil {
+			return errors.Wrap(err, "failed to read verification key")
+		}
+		key, err := imagetools.LoadPublicKey(dt)
+		if err != nil {
+			return err
+		}
+		p.SetVerifyKey(key)
+	}
+
 	return p.Print(in.raw, dockerCli.Out())
 }
 
@@ -62,5 +79,7 @@ func inspectCmd(dockerCli command.Cli, rootOpts RootOptions) *cobra.Command {
 
 	flags.BoolVar(&options.raw, "raw", false, "Show original, unformatted JSON manifest")
 
+	flags.StringVar(&options.verifyKey, "verify-key", "", "Verify the signatures of the image with a PEM public key")
+
 	return cmd
 }
@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/distribution/reference"
+	"github.com/docker/buildx/builder"
+	"github.com/docker/buildx/util/cobrautil"
+	"github.com/docker/buildx/util/cobrautil/completion"
+	"github.com/docker/buildx/util/imagetools"
+	"github.com/docker/cli/cli"
+	"github.com/docker/cli/cli/command"
+	"github.com/spf13/cobra"
+)
+
+type rmOptions struct {
+	builder   string
+	manifests bool
+	force     bool
+}
+
+const (
+	rmWarning          = `WARNING! This will delete the images from the registry, along with all the tags pointing to them. Are you sure you want to continue?`
+	rmManifestsWarning = `WARNING! This will delete the images from the registry, along with the manifests of the image indexes and all the tags pointing to them, even if other images refer to them. Are you sure you want to continue?`
+)
+
+func runRm(ctx context.Context, dockerCli command.Cli, in rmOptions, args []string) error {
+	refs, err := parseRefs(args)
+	if err != nil {
+		return err
+	}
+
+	if !in.force {
+		warning := rmWarning
+		if in.manifests {
+			warning = rmManifestsWarning
+		}
+		if ok, err := cobrautil.Prompt(ctx, dockerCli.In(), dockerCli.Out(), warning); err != nil {
+			return err
+		} else if !ok {
+			return nil
+		}
+	}
+
+	b, err := builder.New(dockerCli, builder.WithName(in.builder))
+	if err != nil {
+		return err
+	}
+	imageopt, err := b.ImageOpt()
+	if err != nil {
+		return err
+	}
+	r := imagetools.New(imageopt)
+
+	for _, ref := range refs {
+		descs, err := r.Delete(ctx, ref, in.manifests)
+		for _, d := range descs {
+			fmt.Fprintf(dockerCli.Out(), "Deleted: %s@%s\n", reference.TrimNamed(ref).String(), d.Digest)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func rmCmd(dockerCli command.Cli, rootOpts RootOptions) *cobra.Command {
+	var options rmOptions
+
+	cmd := &cobra.Command{
+		Use:     "rm [OPTIONS] IMAGE [IMAGE...]",
+		Aliases: []string{"remove"},
+		Short:   "Delete images from the registry",
+		Args:    cli.RequiresMinArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options.builder = *rootOpts.Builder
+			return runRm(cmd.Context(), dockerCli, options, args)
+		},
+		ValidArgsFunction: completion.Disable,
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&options.manifests, "manifests", false, "Also delete the manifests of an image index")
+	flags.BoolVarP(&options.force, "force", "f", false, "Do not prompt for confirmation")
+
+	return cmd
+}
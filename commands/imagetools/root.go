@@ -20,7 +20,11 @@ func RootCmd(rootcmd *cobra.Command, dockerCli command.Cli, opts RootOptions) *c
 
 	cmd.AddCommand(
 		createCmd(dockerCli, opts),
+		diffCmd(dockerCli, opts),
 		inspectCmd(dockerCli, opts),
+		rmCmd(dockerCli, opts),
+		sbomCmd(dockerCli, opts),
+		tagCmd(dockerCli, opts),
 	)
 
 	return cmd
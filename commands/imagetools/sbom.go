@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/containerd/platforms"
+	"github.com/docker/buildx/builder"
+	"github.com/docker/buildx/util/cobrautil/completion"
+	"github.com/docker/buildx/util/imagetools"
+	"github.com/docker/cli/cli"
+	"github.com/docker/cli/cli/command"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+type sbomOptions struct {
+	builder  string
+	format   string
+	platform string
+	diff     string
+}
+
+func runSBOM(ctx context.Context, dockerCli command.Cli, in sbomOptions, name string) error {
+	switch in.format {
+	case "table", "spdx", "cyclonedx":
+		if in.diff != "" && in.format != "table" {
+			return errors.Errorf("--diff can only be used with the table and json formats")
+		}
+	case "json":
+		if in.diff == "" {
+			return errors.Errorf("the json format can only be used with --diff")
+		}
+	default:
+		return errors.Errorf("invalid format %q, valid formats are table, spdx, cyclonedx and json", in.format)
+	}
+
+	var platform *ocispec.Platform
+	if in.platform != "" {
+		p, err := platforms.Parse(in.platform)
+		if err != nil {
+			return err
+		}
+		platform = &p
+	}
+
+	var imageopt imagetools.Opt
+	if !imagetools.IsLocalRef(name) || (in.diff != "" && !imagetools.IsLocalRef(in.diff)) {
+		b, err := builder.New(dockerCli, builder.WithName(in.builder))
+		if err != nil {
+			return err
+		}
+		imageopt, err = b.ImageOpt()
+		if err != nil {
+			return err
+		}
+	}
+	r := imagetools.New(imageopt)
+
+	names := []string{name}
+	if in.diff != "" {
+		names = append(names, in.diff)
+	}
+	sboms := make([]*imagetools.SBOM, len(names))
+	eg, ctx := errgroup.WithContext(ctx)
+	for i, n := range names {
+		i, n := i, n
+		eg.Go(func() error {
+			var err error
+			sboms[i], err = r.SBOM(ctx, n, platform)
+			return err
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+
+	if in.diff != "" {
+		d := sboms[1].Diff(sboms[0])
+		if in.format == "json" {
+			enc := json.NewEncoder(dockerCli.Out())
+			enc.SetIndent("", "  ")
+			return enc.Encode(d)
+		}
+		return d.Print(dockerCli.Out())
+	}
+
+	var dt []byte
+	var err error
+	switch in.format {
+	case "spdx":
+		dt, err = sboms[0].SPDX()
+	case "cyclonedx":
+		dt, err = sboms[0].CycloneDX()
+	default:
+		return sboms[0].PrintTable(dockerCli.Out())
+	}
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(dockerCli.Out(), string(dt))
+	return err
+}
+
+func sbomCmd(dockerCli command.Cli, rootOpts RootOptions) *cobra.Command {
+	var options sbomOptions
+
+	cmd := &cobra.Command{
+		Use:   "sbom [OPTIONS] IMAGE",
+		Short: "Show the packages and licenses of the SBOM of an image",
+		Args:  cli.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options.builder = *rootOpts.Builder
+			return runSBOM(cmd.Context(), dockerCli, options, args[0])
+		},
+		ValidArgsFunction: completion.Disable,
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.diff, "diff", "", "Show the packages changed from another image")
+	flags.StringVar(&options.format, "format", "table", `Format the output ("table", "spdx", "cyclonedx", "json" with --diff)`)
+	flags.StringVar(&options.platform, "platform", "", "Show the SBOM of a single platform")
+
+	return cmd
+}
@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/distribution/reference"
+	"github.com/docker/buildx/builder"
+	"github.com/docker/buildx/util/cobrautil/completion"
+	"github.com/docker/buildx/util/imagetools"
+	"github.com/docker/cli/cli"
+	"github.com/docker/cli/cli/command"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+type tagOptions struct {
+	builder string
+}
+
+func runTag(ctx context.Context, dockerCli command.Cli, in tagOptions, src string, dests []string) error {
+	srcRef, err := reference.ParseNormalizedNamed(src)
+	if err != nil {
+		return err
+	}
+	tags, err := parseRefs(dests)
+	if err != nil {
+		return err
+	}
+
+	b, err := builder.New(dockerCli, builder.WithName(in.builder))
+	if err != nil {
+		return err
+	}
+	imageopt, err := b.ImageOpt()
+	if err != nil {
+		return err
+	}
+	r := imagetools.New(imageopt)
+
+	_, desc, err := r.Resolve(ctx, srcRef.String())
+	if err != nil {
+		return err
+	}
+	dt, err := r.GetDescriptor(ctx, srcRef.String(), desc)
+	if err != nil {
+		return err
+	}
+
+	// new resolver cause need new auth
+	r = imagetools.New(imageopt)
+
+	eg, ctx := errgroup.WithContext(ctx)
+	for _, t := range tags {
+		t := t
+		eg.Go(func() error {
+			if reference.Domain(srcRef) == reference.Domain(t) && reference.Path(srcRef) == reference.Path(t) {
+				// same repository, only the manifest needs to be pushed
+				if err := r.Push(ctx, t, desc, dt); err != nil {
+					return err
+				}
+			} else if err := r.Copy(ctx, &imagetools.Source{Ref: srcRef, Desc: desc}, t); err != nil {
+				return err
+			}
+			fmt.Fprintf(dockerCli.Out(), "Tagged %s\n", reference.TagNameOnly(t).String())
+			return nil
+		})
+	}
+	return eg.Wait()
+}
+
+func tagCmd(dockerCli command.Cli, rootOpts RootOptions) *cobra.Command {
+	var options tagOptions
+
+	cmd := &cobra.Command{
+		Use:   "tag [OPTIONS] SOURCE TARGET [TARGET...]",
+		Short: "Create tags in the registry that refer to a source image",
+		Args:  cli.RequiresMinArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options.builder = *rootOpts.Builder
+			return runTag(cmd.Context(), dockerCli, options, args[0], args[1:])
+		},
+		ValidArgsFunction: completion.Disable,
+	}
+
+	return cmd
+}
@@ -0,0 +1,458 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/docker/buildx/bake"
+	"github.com/docker/buildx/build"
+	"github.com/docker/buildx/builder"
+	cbuild "github.com/docker/buildx/controller/build"
+	controllerapi "github.com/docker/buildx/controller/pb"
+	"github.com/docker/buildx/util/cobrautil/completion"
+	"github.com/docker/buildx/util/imagetools"
+	"github.com/docker/buildx/util/progress"
+	"github.com/docker/cli/cli"
+	"github.com/docker/cli/cli/command"
+	"github.com/moby/buildkit/frontend/dockerfile/shell"
+	"github.com/moby/buildkit/frontend/subrequests/outline"
+	"github.com/moby/buildkit/frontend/subrequests/targets"
+	spb "github.com/moby/buildkit/sourcepolicy/pb"
+	"github.com/moby/buildkit/util/progress/progressui"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/cobra"
+)
+
+type pinOptions struct {
+	builder      string
+	dockerfile   string
+	buildArgs    []string
+	bakeFiles    []string
+	sourcePolicy string
+	dryRun       bool
+}
+
+// pinImage is an image reference of a file to pin to a digest.
+type pinImage struct {
+	// file is the Dockerfile of the image, or empty for the image contexts
+	// of the bake files.
+	file string
+	line int
+	// name is the reference as written in the file, and ref the reference
+	// to resolve, that differ if build arguments are expanded.
+	name string
+	ref  reference.Named
+}
+
+// pinFile is a file to rewrite with the pinned images.
+type pinFile struct {
+	name string
+	dt   []byte
+}
+
+func runPin(ctx context.Context, dockerCli command.Cli, in pinOptions, contextPath string) error {
+	dockerfile := in.dockerfile
+	if dockerfile == "" {
+		dockerfile = filepath.Join(contextPath, "Dockerfile")
+		if _, err := os.Stat(dockerfile); err != nil && len(in.bakeFiles) > 0 {
+			// only pin the bake files
+			dockerfile = ""
+		}
+	}
+
+	var files []pinFile
+	var images []pinImage
+	if dockerfile != "" {
+		dt, err := os.ReadFile(dockerfile)
+		if err != nil {
+			return errors.Wrap(err, "failed to read Dockerfile")
+		}
+		files = append(files, pinFile{name: dockerfile, dt: dt})
+		imgs, err := resolveDockerfileImages(ctx, dockerCli, in, contextPath, dockerfile)
+		if err != nil {
+			return err
+		}
+		for _, img := range imgs {
+			if strings.Contains(img.name, "$") && in.sourcePolicy == "" {
+				fmt.Fprintf(dockerCli.Err(), "Skipping %s in %s:%d: images with build arguments can only be pinned with --source-policy\n", img.name, dockerfile, img.line)
+				continue
+			}
+			images = append(images, img)
+		}
+	}
+	if len(in.bakeFiles) > 0 {
+		bakeFiles, err := bake.ReadLocalFiles(in.bakeFiles, dockerCli.In(), nil)
+		if err != nil {
+			return err
+		}
+		c, _, err := bake.ParseFiles(bakeFiles, nil)
+		if err != nil {
+			return err
+		}
+		imgs, err := bakeImages(c)
+		if err != nil {
+			return err
+		}
+		images = append(images, imgs...)
+		for _, f := range bakeFiles {
+			if f.Name != "-" {
+				files = append(files, pinFile{name: f.Name, dt: f.Data})
+			}
+		}
+	}
+
+	b, err := builder.New(dockerCli, builder.WithName(in.builder))
+	if err != nil {
+		return err
+	}
+	imageopt, err := b.ImageOpt()
+	if err != nil {
+		return err
+	}
+	r := imagetools.New(imageopt)
+
+	digests := map[string]digest.Digest{}
+	var unpinned []pinImage
+	for _, img := range images {
+		if _, ok := img.ref.(reference.Canonical); ok {
+			continue
+		}
+		ref := reference.TagNameOnly(img.ref)
+		if _, ok := digests[ref.String()]; !ok {
+			_, desc, err := r.Resolve(ctx, ref.String())
+			if err != nil {
+				return err
+			}
+			digests[ref.String()] = desc.Digest
+		}
+		unpinned = append(unpinned, img)
+	}
+
+	if in.sourcePolicy != "" {
+		pol, err := pinSourcePolicy(unpinned, digests)
+		if err != nil {
+			return err
+		}
+		dt, err := json.MarshalIndent(pol, "", "  ")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		dt = append(dt, '\n')
+		if in.dryRun {
+			_, err := dockerCli.Out().Write(dt)
+			return err
+		}
+		if err := os.WriteFile(in.sourcePolicy, dt, 0644); err != nil {
+			return err
+		}
+		printed := map[string]struct{}{}
+		for _, img := range unpinned {
+			ref := reference.TagNameOnly(img.ref).String()
+			if _, ok := printed[ref]; !ok {
+				printed[ref] = struct{}{}
+				fmt.Fprintf(dockerCli.Out(), "Pinned %s to %s\n", ref, digests[ref])
+			}
+		}
+		return nil
+	}
+
+	var dockerfileImages, contextImages []pinImage
+	for _, img := range unpinned {
+		if img.file == "" {
+			contextImages = append(contextImages, img)
+		} else {
+			dockerfileImages = append(dockerfileImages, img)
+		}
+	}
+
+	pinned := map[string]struct{}{}
+	for _, f := range files {
+		var out []byte
+		var p map[string]struct{}
+		if f.name == dockerfile {
+			out, p = pinDockerfile(dockerCli.Err(), f.name, f.dt, dockerfileImages, digests)
+		} else {
+			out, p = pinBakeFile(f.dt, contextImages, digests)
+		}
+		for name := range p {
+			pinned[name] = struct{}{}
+		}
+		if bytes.Equal(out, f.dt) {
+			continue
+		}
+		if in.dryRun {
+			diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+				A:        difflib.SplitLines(string(f.dt)),
+				B:        difflib.SplitLines(string(out)),
+				FromFile: f.name,
+				ToFile:   f.name,
+				Context:  3,
+			})
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			fmt.Fprint(dockerCli.Out(), diff)
+			continue
+		}
+		st, err := os.Stat(f.name)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(f.name, out, st.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	printed := map[string]struct{}{}
+	for _, img := range unpinned {
+		if _, ok := printed[img.name]; ok {
+			continue
+		}
+		printed[img.name] = struct{}{}
+		if _, ok := pinned[img.name]; !ok {
+			if img.file == "" {
+				fmt.Fprintf(dockerCli.Err(), "Skipping %s: image contexts set with variables can only be pinned with --source-policy\n", img.name)
+			}
+			continue
+		}
+		if !in.dryRun {
+			fmt.Fprintf(dockerCli.Out(), "Pinned %s to %s\n", img.name, digests[reference.TagNameOnly(img.ref).String()])
+		}
+	}
+	return nil
+}
+
+// resolveDockerfileImages returns the base images of the stages of the
+// Dockerfile, as listed by the targets subrequest of the frontend.
+func resolveDockerfileImages(ctx context.Context, dockerCli command.Cli, in pinOptions, contextPath, dockerfile string) (_ []pinImage, err error) {
+	buildArgs, err := listToMap(in.buildArgs, true)
+	if err != nil {
+		return nil, err
+	}
+
+	printer, err := progress.NewPrinter(ctx, os.Stderr, progressui.QuietMode)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if perr := printer.Wait(); err == nil {
+			err = perr
+		}
+	}()
+
+	subrequest := func(name, target string, v any) error {
+		opts := &controllerapi.BuildOptions{
+			ContextPath:    contextPath,
+			DockerfileName: dockerfile,
+			BuildArgs:      buildArgs,
+			Target:         target,
+			CallFunc:       &controllerapi.CallFunc{Name: name},
+			Builder:        in.builder,
+		}
+		resp, res, _, err := cbuild.RunBuild(ctx, dockerCli, opts, dockerCli.In(), printer, false)
+		if res != nil {
+			res.Done()
+		}
+		if err != nil {
+			return err
+		}
+		dt, ok := resp.ExporterResponse["result.json"]
+		if !ok {
+			return errors.Errorf("frontend doesn't support the %s subrequest", name)
+		}
+		return errors.WithStack(json.Unmarshal([]byte(dt), v))
+	}
+
+	var list targets.List
+	if err := subrequest("targets", "", &list); err != nil {
+		return nil, err
+	}
+	return targetImages(dockerfile, list.Targets, func(target string) ([]string, error) {
+		var o outline.Outline
+		if err := subrequest("outline", target, &o); err != nil {
+			return nil, err
+		}
+		args := make([]string, 0, len(o.Args))
+		for _, a := range o.Args {
+			args = append(args, a.Name+"="+a.Value)
+		}
+		return args, nil
+	})
+}
+
+// targetImages returns the base images of the targets of the Dockerfile,
+// skipping scratch and the other stages. The base images using build
+// arguments are expanded with the arguments returned by the outline of their
+// target.
+func targetImages(dockerfile string, ts []targets.Target, outlineArgs func(target string) ([]string, error)) ([]pinImage, error) {
+	stages := map[string]struct{}{}
+	for _, t := range ts {
+		if t.Name != "" {
+			stages[strings.ToLower(t.Name)] = struct{}{}
+		}
+	}
+
+	var out []pinImage
+	lex := shell.NewLex('\\')
+	for i, t := range ts {
+		img := pinImage{file: dockerfile, name: t.Base}
+		if t.Location != nil && len(t.Location.Ranges) > 0 {
+			img.line = int(t.Location.Ranges[0].Start.Line)
+		}
+
+		name := t.Base
+		if strings.Contains(name, "$") {
+			target := t.Name
+			if target == "" {
+				target = fmt.Sprintf("stage-%d", i)
+			}
+			args, err := outlineArgs(target)
+			if err != nil {
+				return nil, err
+			}
+			if name, _, err = lex.ProcessWord(name, shell.EnvsFromSlice(args)); err != nil {
+				return nil, errors.Wrapf(err, "failed to expand base image %s in %s:%d", t.Base, dockerfile, img.line)
+			}
+			if name == "" {
+				return nil, errors.Errorf("base image %q in %s:%d expands to an empty name", t.Base, dockerfile, img.line)
+			}
+		}
+		if _, ok := stages[strings.ToLower(name)]; ok || name == "scratch" {
+			continue
+		}
+
+		ref, err := reference.ParseNormalizedNamed(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid base image %q in %s:%d", name, dockerfile, img.line)
+		}
+		img.ref = ref
+		out = append(out, img)
+	}
+	return out, nil
+}
+
+// bakeImages returns the docker-image:// contexts of the targets of the bake
+// definition.
+func bakeImages(c *bake.Config) ([]pinImage, error) {
+	var out []pinImage
+	for _, t := range c.Targets {
+		names := make([]string, 0, len(t.Contexts))
+		for _, v := range t.Contexts {
+			if name, ok := strings.CutPrefix(v, "docker-image://"); ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			ref, err := reference.ParseNormalizedNamed(name)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid image context %q of target %s", name, t.Name)
+			}
+			out = append(out, pinImage{name: name, ref: ref})
+		}
+	}
+	return out, nil
+}
+
+// pinDockerfile pins the images of the FROM instructions of the Dockerfile
+// dt to their digests, and returns the names of the pinned images.
+func pinDockerfile(stderr io.Writer, name string, dt []byte, images []pinImage, digests map[string]digest.Digest) ([]byte, map[string]struct{}) {
+	pinned := map[string]struct{}{}
+	lines := strings.SplitAfter(string(dt), "\n")
+	for _, img := range images {
+		if img.file != name || img.line < 1 || img.line > len(lines) {
+			continue
+		}
+		dgst := digests[reference.TagNameOnly(img.ref).String()]
+		re := regexp.MustCompile(`(?i)^(\s*FROM\s+(?:--\S+\s+)*)` + regexp.QuoteMeta(img.name) + `(\s|$)`)
+		l := lines[img.line-1]
+		if !re.MatchString(l) {
+			fmt.Fprintf(stderr, "Skipping %s in %s:%d: FROM instruction spans multiple lines\n", img.name, name, img.line)
+			continue
+		}
+		lines[img.line-1] = re.ReplaceAllString(l, "${1}"+img.name+"@"+dgst.String()+"${2}")
+		pinned[img.name] = struct{}{}
+	}
+	return []byte(strings.Join(lines, "")), pinned
+}
+
+// pinBakeFile pins the docker-image:// contexts of the bake file dt to their
+// digests, and returns the names of the pinned images. Only the contexts
+// written literally in the file can be pinned, and comments are left
+// unchanged.
+func pinBakeFile(dt []byte, images []pinImage, digests map[string]digest.Digest) ([]byte, map[string]struct{}) {
+	pinned := map[string]struct{}{}
+	lines := strings.SplitAfter(string(dt), "\n")
+	for _, img := range images {
+		dgst := digests[reference.TagNameOnly(img.ref).String()]
+		re := regexp.MustCompile(`docker-image://` + regexp.QuoteMeta(img.name) + `([\s"',]|$)`)
+		for i, l := range lines {
+			if trimmed := strings.TrimSpace(l); strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "//") {
+				continue
+			}
+			if re.MatchString(l) {
+				lines[i] = re.ReplaceAllString(l, "docker-image://"+img.name+"@"+dgst.String()+"${1}")
+				pinned[img.name] = struct{}{}
+			}
+		}
+	}
+	return []byte(strings.Join(lines, "")), pinned
+}
+
+// pinSourcePolicy returns a source policy pinning the images to their
+// digests, in the format read with EXPERIMENTAL_BUILDKIT_SOURCE_POLICY.
+func pinSourcePolicy(images []pinImage, digests map[string]digest.Digest) (*spb.Policy, error) {
+	pol := &spb.Policy{Version: 1}
+	seen := map[string]struct{}{}
+	for _, img := range images {
+		ref := reference.TagNameOnly(img.ref)
+		if _, ok := seen[ref.String()]; ok {
+			continue
+		}
+		seen[ref.String()] = struct{}{}
+		rule, err := build.PinRule(ref, digests[ref.String()])
+		if err != nil {
+			return nil, err
+		}
+		pol.Rules = append(pol.Rules, rule)
+	}
+	return pol, nil
+}
+
+func pinCmd(dockerCli command.Cli, rootOpts *rootOptions) *cobra.Command {
+	var options pinOptions
+
+	cmd := &cobra.Command{
+		Use:   "pin [OPTIONS] [PATH]",
+		Short: "Pin the base images of a build to their digests",
+		Args:  cli.RequiresMaxArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options.builder = rootOpts.builder
+			contextPath := "."
+			if len(args) > 0 {
+				contextPath = args[0]
+			}
+			return runPin(cmd.Context(), dockerCli, options, contextPath)
+		},
+		ValidArgsFunction: completion.Disable,
+	}
+
+	flags := cmd.Flags()
+	flags.StringArrayVar(&options.bakeFiles, "bake-file", nil, "Bake file to pin the image contexts of")
+	flags.StringArrayVar(&options.buildArgs, "build-arg", []string{}, "Set build-time variables")
+	flags.BoolVar(&options.dryRun, "dry-run", false, "Print the changes instead of writing them")
+	flags.StringVarP(&options.dockerfile, "file", "f", "", `Name of the Dockerfile (default: "PATH/Dockerfile")`)
+	flags.StringVar(&options.sourcePolicy, "source-policy", "", "Write a source policy pinning the images instead of rewriting the files")
+
+	return cmd
+}
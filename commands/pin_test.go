@@ -0,0 +1,132 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/distribution/reference"
+	"github.com/docker/buildx/bake"
+	"github.com/moby/buildkit/frontend/subrequests/targets"
+	"github.com/moby/buildkit/solver/pb"
+	spb "github.com/moby/buildkit/sourcepolicy/pb"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPinDockerfile(t *testing.T) {
+	dt := []byte(`ARG GO_VERSION=1.22
+FROM --platform=$BUILDPLATFORM golang:${GO_VERSION} AS build
+FROM alpine:3.20 AS base
+from base
+FROM docker.io/library/busybox@sha256:0000000000000000000000000000000000000000000000000000000000000000
+FROM alpine:3.20
+FROM scratch
+`)
+	location := func(line int32) *pb.Location {
+		return &pb.Location{Ranges: []*pb.Range{{Start: &pb.Position{Line: line}, End: &pb.Position{Line: line}}}}
+	}
+	ts := []targets.Target{
+		{Name: "build", Base: "golang:${GO_VERSION}", Location: location(2)},
+		{Name: "base", Base: "alpine:3.20", Location: location(3)},
+		{Base: "base", Location: location(4)},
+		{Base: "docker.io/library/busybox@sha256:0000000000000000000000000000000000000000000000000000000000000000", Location: location(5)},
+		{Base: "alpine:3.20", Location: location(6)},
+		{Base: "scratch", Location: location(7), Default: true},
+	}
+
+	var outlined []string
+	images, err := targetImages("Dockerfile", ts, func(target string) ([]string, error) {
+		outlined = append(outlined, target)
+		return []string{"GO_VERSION=1.22"}, nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"build"}, outlined)
+	require.Len(t, images, 4)
+	require.Equal(t, "golang:${GO_VERSION}", images[0].name)
+	require.Equal(t, "docker.io/library/golang:1.22", images[0].ref.String())
+	require.Equal(t, 2, images[0].line)
+
+	var unpinned []pinImage
+	for _, img := range images {
+		if _, ok := img.ref.(reference.Canonical); !ok {
+			unpinned = append(unpinned, img)
+		}
+	}
+
+	dgst := digest.FromString("alpine")
+	out, pinned := pinDockerfile(&bytes.Buffer{}, "Dockerfile", dt, unpinned[1:], map[string]digest.Digest{
+		"docker.io/library/alpine:3.20": dgst,
+	})
+	require.Equal(t, map[string]struct{}{"alpine:3.20": {}}, pinned)
+	require.Equal(t, `ARG GO_VERSION=1.22
+FROM --platform=$BUILDPLATFORM golang:${GO_VERSION} AS build
+FROM alpine:3.20@`+dgst.String()+` AS base
+from base
+FROM docker.io/library/busybox@sha256:0000000000000000000000000000000000000000000000000000000000000000
+FROM alpine:3.20@`+dgst.String()+`
+FROM scratch
+`, string(out))
+}
+
+func TestPinBakeFile(t *testing.T) {
+	dt := []byte(`variable "TAG" {
+  default = "1.0"
+}
+target "app" {
+  contexts = {
+    alpine = "docker-image://alpine:3.20"
+    tools  = "docker-image://tools:${TAG}"
+    golang = "docker-image://golang@sha256:0000000000000000000000000000000000000000000000000000000000000000"
+    # debian = "docker-image://alpine:3.20"
+  }
+}
+`)
+	c, _, err := bake.ParseFiles([]bake.File{{Name: "docker-bake.hcl", Data: dt}}, nil)
+	require.NoError(t, err)
+	images, err := bakeImages(c)
+	require.NoError(t, err)
+	require.Len(t, images, 3)
+
+	var unpinned []pinImage
+	for _, img := range images {
+		if _, ok := img.ref.(reference.Canonical); !ok {
+			unpinned = append(unpinned, img)
+		}
+	}
+	require.Len(t, unpinned, 2)
+	require.Equal(t, "tools:1.0", unpinned[1].name)
+
+	dgst := digest.FromString("alpine")
+	out, pinned := pinBakeFile(dt, unpinned, map[string]digest.Digest{
+		"docker.io/library/alpine:3.20": dgst,
+		"docker.io/library/tools:1.0":   digest.FromString("tools"),
+	})
+	require.Equal(t, map[string]struct{}{"alpine:3.20": {}}, pinned)
+	require.Equal(t, `variable "TAG" {
+  default = "1.0"
+}
+target "app" {
+  contexts = {
+    alpine = "docker-image://alpine:3.20@`+dgst.String()+`"
+    tools  = "docker-image://tools:${TAG}"
+    golang = "docker-image://golang@sha256:0000000000000000000000000000000000000000000000000000000000000000"
+    # debian = "docker-image://alpine:3.20"
+  }
+}
+`, string(out))
+}
+
+func TestPinSourcePolicy(t *testing.T) {
+	dgst := digest.FromString("alpine")
+	ref, err := reference.ParseNormalizedNamed("alpine:3.20")
+	require.NoError(t, err)
+
+	pol, err := pinSourcePolicy([]pinImage{{name: "alpine:3.20", ref: ref}, {name: "alpine:3.20", ref: ref}}, map[string]digest.Digest{
+		"docker.io/library/alpine:3.20": dgst,
+	})
+	require.NoError(t, err)
+	require.Len(t, pol.Rules, 1)
+	require.Equal(t, spb.PolicyAction_CONVERT, pol.Rules[0].Action)
+	require.Equal(t, "docker-image://docker.io/library/alpine:3.20", pol.Rules[0].Selector.Identifier)
+	require.Equal(t, "docker-image://docker.io/library/alpine:3.20@"+dgst.String(), pol.Rules[0].Updates.Identifier)
+}
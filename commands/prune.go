@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/docker/buildx/builder"
+	"github.com/docker/buildx/util/cobrautil"
 	"github.com/docker/buildx/util/cobrautil/completion"
 	"github.com/docker/cli/cli"
 	"github.com/docker/cli/cli/command"
@@ -55,7 +56,7 @@ func runPrune(ctx context.Context, dockerCli command.Cli, opts pruneOptions) err
 	}
 
 	if !opts.force {
-		if ok, err := prompt(ctx, dockerCli.In(), dockerCli.Out(), warning); err != nil {
+		if ok, err := cobrautil.Prompt(ctx, dockerCli.In(), dockerCli.Out(), warning); err != nil {
 			return err
 		} else if !ok {
 			return nil
@@ -8,6 +8,7 @@ import (
 	"github.com/docker/buildx/builder"
 	"github.com/docker/buildx/store"
 	"github.com/docker/buildx/store/storeutil"
+	"github.com/docker/buildx/util/cobrautil"
 	"github.com/docker/buildx/util/cobrautil/completion"
 	"github.com/docker/cli/cli/command"
 	"github.com/pkg/errors"
@@ -29,7 +30,7 @@ const (
 
 func runRm(ctx context.Context, dockerCli command.Cli, in rmOptions) error {
 	if in.allInactive && !in.force {
-		if ok, err := prompt(ctx, dockerCli.In(), dockerCli.Out(), rmInactiveWarning); err != nil {
+		if ok, err := cobrautil.Prompt(ctx, dockerCli.In(), dockerCli.Out(), rmInactiveWarning); err != nil {
 			return err
 		} else if !ok {
 			return nil
@@ -74,10 +74,35 @@ func RunBuild(ctx context.Context, dockerCli command.Cli, in *controllerapi.Buil
 	}
 	opts.Platforms = platforms
 
-	dockerConfig := dockerCli.ConfigFile()
-	opts.Session = append(opts.Session, authprovider.NewDockerAuthProvider(dockerConfig, nil))
+	var dockerConfig controllerapi.RegistryCredentials = dockerCli.ConfigFile()
+	if len(in.RegistryAuth) > 0 || in.RegistryAuthIsolated {
+		baseConfig := dockerCli.ConfigFile()
+		if in.RegistryAuthIsolated {
+			baseConfig = nil
+		}
+		registryAuth := controllerapi.NewRegistryAuthConfig(ctx, baseConfig, in.RegistryAuth)
+		opts.Session = append(opts.Session, registryAuth.AuthProvider())
+		opts.RegistryAuth = registryAuth
+		dockerConfig = registryAuth
+	} else {
+		opts.Session = append(opts.Session, authprovider.NewDockerAuthProvider(dockerCli.ConfigFile(), nil))
+	}
+
+	if in.Sign != nil {
+		opts.Signer, err = build.NewSigner(in.Sign)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	if in.VerifyBaseImage != "" {
+		opts.BaseImagePolicy, err = build.ReadBaseImagePolicy(in.VerifyBaseImage)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
 
-	secrets, err := controllerapi.CreateSecrets(in.Secrets)
+	secrets, err := controllerapi.CreateSecrets(in.Secrets, dockerConfig)
 	if err != nil {
 		return nil, nil, nil, err
 	}
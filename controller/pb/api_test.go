@@ -0,0 +1,124 @@
+package pb
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var updateAPI = flag.Bool("update", false, "record the description of the API in testdata")
+
+// TestAPICompatibility checks that the API is compatible with the one
+// recorded for APIVersion in testdata. Run it with -update to record
+// compatible changes.
+func TestAPICompatibility(t *testing.T) {
+	current := describeAPI(File_github_com_docker_buildx_controller_pb_controller_proto)
+	path := filepath.Join("testdata", fmt.Sprintf("api-v%d.txt", APIVersion))
+
+	dt, err := os.ReadFile(path)
+	if os.IsNotExist(err) && *updateAPI {
+		require.NoError(t, os.WriteFile(path, []byte(strings.Join(current, "\n")+"\n"), 0644))
+		return
+	}
+	require.NoError(t, err)
+	recorded := strings.Split(strings.TrimSpace(string(dt)), "\n")
+
+	var removed []string
+	for _, l := range recorded {
+		if !slices.Contains(current, l) {
+			removed = append(removed, l)
+		}
+	}
+	require.Empty(t, removed, "backwards incompatible change of the API, increase APIVersion and run the test with -update")
+
+	if !slices.Equal(recorded, current) {
+		if *updateAPI {
+			require.NoError(t, os.WriteFile(path, []byte(strings.Join(current, "\n")+"\n"), 0644))
+			return
+		}
+		require.Fail(t, "API changed, run the test with -update to record it")
+	}
+}
+
+// describeAPI returns the methods, fields and enum values of the API that
+// clients depend on, one per line.
+func describeAPI(fd protoreflect.FileDescriptor) []string {
+	var out []string
+	services := fd.Services()
+	for i := 0; i < services.Len(); i++ {
+		s := services.Get(i)
+		methods := s.Methods()
+		for j := 0; j < methods.Len(); j++ {
+			m := methods.Get(j)
+			out = append(out, fmt.Sprintf("rpc %s.%s(%s%s) returns (%s%s)", s.Name(), m.Name(), stream(m.IsStreamingClient()), m.Input().FullName(), stream(m.IsStreamingServer()), m.Output().FullName()))
+		}
+	}
+	var messages func(protoreflect.MessageDescriptors)
+	messages = func(msgs protoreflect.MessageDescriptors) {
+		for i := 0; i < msgs.Len(); i++ {
+			m := msgs.Get(i)
+			if m.IsMapEntry() {
+				continue
+			}
+			fields := m.Fields()
+			for j := 0; j < fields.Len(); j++ {
+				f := fields.Get(j)
+				var oneof string
+				if o := f.ContainingOneof(); o != nil {
+					oneof = " oneof " + string(o.Name())
+				}
+				out = append(out, fmt.Sprintf("field %s.%s = %d %s%s", m.FullName(), f.Name(), f.Number(), fieldType(f), oneof))
+			}
+			enums(&out, m.Enums())
+			messages(m.Messages())
+		}
+	}
+	enums(&out, fd.Enums())
+	messages(fd.Messages())
+	slices.Sort(out)
+	return out
+}
+
+func enums(out *[]string, enums protoreflect.EnumDescriptors) {
+	for i := 0; i < enums.Len(); i++ {
+		e := enums.Get(i)
+		values := e.Values()
+		for j := 0; j < values.Len(); j++ {
+			v := values.Get(j)
+			*out = append(*out, fmt.Sprintf("enum %s.%s = %d", e.FullName(), v.Name(), v.Number()))
+		}
+	}
+}
+
+func fieldType(f protoreflect.FieldDescriptor) string {
+	if f.IsMap() {
+		return fmt.Sprintf("map<%s, %s>", fieldType(f.MapKey()), fieldType(f.MapValue()))
+	}
+	var typ string
+	switch f.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		typ = string(f.Message().FullName())
+	case protoreflect.EnumKind:
+		typ = string(f.Enum().FullName())
+	default:
+		typ = f.Kind().String()
+	}
+	if f.IsList() {
+		return "repeated " + typ
+	}
+	return typ
+}
+
+func stream(ok bool) string {
+	if ok {
+		return "stream "
+	}
+	return ""
+}
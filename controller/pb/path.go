@@ -141,6 +141,32 @@ func ResolveOptionPaths(options *BuildOptions) (_ *BuildOptions, err error) {
 	}
 	options.Secrets = secrets
 
+	var registryAuth []*RegistryAuth
+	for _, a := range options.RegistryAuth {
+		if a.FilePath != "" {
+			a.FilePath, err = filepath.Abs(a.FilePath)
+			if err != nil {
+				return nil, err
+			}
+		}
+		registryAuth = append(registryAuth, a)
+	}
+	options.RegistryAuth = registryAuth
+
+	if options.Sign != nil && options.Sign.Key != "" {
+		options.Sign.Key, err = filepath.Abs(options.Sign.Key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if options.VerifyBaseImage != "" {
+		options.VerifyBaseImage, err = filepath.Abs(options.VerifyBaseImage)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var ssh []*SSH
 	for _, s := range options.SSH {
 		var ps []string
@@ -0,0 +1,196 @@
+package pb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/docker/cli/cli/config/configfile"
+	"github.com/docker/cli/cli/config/types"
+	"github.com/google/shlex"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/auth"
+	"github.com/moby/buildkit/session/auth/authprovider"
+	"github.com/moby/buildkit/util/progress/progresswriter"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+)
+
+// RegistryAuthConfig is a docker config with the credentials of registry
+// auths added to the ones of a base docker config. It is kept in memory only
+// and is never saved, so the credentials only last for the build. The
+// credentials are resolved when they are first requested.
+type RegistryAuthConfig struct {
+	ctx   context.Context
+	cfg   *configfile.ConfigFile
+	auths []*RegistryAuth
+
+	once sync.Once
+	err  error
+}
+
+// NewRegistryAuthConfig returns a RegistryAuthConfig adding the credentials of
+// auths to dockerConfig. dockerConfig can be nil to isolate the build from the
+// credentials of the docker config. ctx is used to resolve the credentials
+// looked up with GetAuthConfig, like the commands run for them.
+func NewRegistryAuthConfig(ctx context.Context, dockerConfig *configfile.ConfigFile, auths []*RegistryAuth) *RegistryAuthConfig {
+	cfg := configfile.New("")
+	cfg.CredentialHelpers = map[string]string{}
+	if dockerConfig != nil {
+		for k, v := range dockerConfig.AuthConfigs {
+			cfg.AuthConfigs[k] = v
+		}
+		cfg.CredentialsStore = dockerConfig.CredentialsStore
+		for k, v := range dockerConfig.CredentialHelpers {
+			cfg.CredentialHelpers[k] = v
+		}
+	}
+	return &RegistryAuthConfig{ctx: ctx, cfg: cfg, auths: auths}
+}
+
+func (c *RegistryAuthConfig) resolve(ctx context.Context) error {
+	c.once.Do(func() {
+		for _, a := range c.auths {
+			ac, err := resolveRegistryAuth(ctx, a)
+			if err != nil {
+				c.err = errors.Wrapf(err, "failed to get credentials for %s", a.Host)
+				return
+			}
+			host := a.Host
+			switch host {
+			case "docker.io", "index.docker.io", "registry-1.docker.io":
+				host = "https://index.docker.io/v1/"
+			}
+			ac.ServerAddress = host
+			c.cfg.AuthConfigs[host] = ac
+			// an empty helper makes the lookup of this host use AuthConfigs
+			// instead of the credentials store of the docker config
+			c.cfg.CredentialHelpers[host] = ""
+		}
+	})
+	return c.err
+}
+
+// GetAuthConfig returns the credentials of a registry.
+func (c *RegistryAuthConfig) GetAuthConfig(host string) (types.AuthConfig, error) {
+	if err := c.resolve(c.ctx); err != nil {
+		return types.AuthConfig{}, err
+	}
+	return c.cfg.GetAuthConfig(host)
+}
+
+// AuthProvider returns the session attachable providing the credentials to
+// the build.
+func (c *RegistryAuthConfig) AuthProvider() session.Attachable {
+	return &registryAuthProvider{
+		AuthServer: authprovider.NewDockerAuthProvider(c.cfg, nil).(auth.AuthServer),
+		c:          c,
+	}
+}
+
+type registryAuthProvider struct {
+	auth.AuthServer
+	c *RegistryAuthConfig
+}
+
+func (ap *registryAuthProvider) Register(server *grpc.Server) {
+	auth.RegisterAuthServer(server, ap)
+}
+
+func (ap *registryAuthProvider) SetLogger(l progresswriter.Logger) {
+	if sl, ok := ap.AuthServer.(interface{ SetLogger(progresswriter.Logger) }); ok {
+		sl.SetLogger(l)
+	}
+}
+
+func (ap *registryAuthProvider) Credentials(ctx context.Context, req *auth.CredentialsRequest) (*auth.CredentialsResponse, error) {
+	if err := ap.c.resolve(ctx); err != nil {
+		return nil, err
+	}
+	return ap.AuthServer.Credentials(ctx, req)
+}
+
+func (ap *registryAuthProvider) FetchToken(ctx context.Context, req *auth.FetchTokenRequest) (*auth.FetchTokenResponse, error) {
+	if err := ap.c.resolve(ctx); err != nil {
+		return nil, err
+	}
+	return ap.AuthServer.FetchToken(ctx, req)
+}
+
+func (ap *registryAuthProvider) GetTokenAuthority(ctx context.Context, req *auth.GetTokenAuthorityRequest) (*auth.GetTokenAuthorityResponse, error) {
+	if err := ap.c.resolve(ctx); err != nil {
+		return nil, err
+	}
+	return ap.AuthServer.GetTokenAuthority(ctx, req)
+}
+
+func (ap *registryAuthProvider) VerifyTokenAuthority(ctx context.Context, req *auth.VerifyTokenAuthorityRequest) (*auth.VerifyTokenAuthorityResponse, error) {
+	if err := ap.c.resolve(ctx); err != nil {
+		return nil, err
+	}
+	return ap.AuthServer.VerifyTokenAuthority(ctx, req)
+}
+
+func resolveRegistryAuth(ctx context.Context, a *RegistryAuth) (types.AuthConfig, error) {
+	if a.Host == "" {
+		return types.AuthConfig{}, errors.Errorf("registry auth missing host")
+	}
+
+	var dt []byte
+	switch {
+	case a.Cmd != "":
+		args, err := shlex.Split(a.Cmd)
+		if err != nil {
+			return types.AuthConfig{}, errors.Wrap(err, "invalid command")
+		}
+		if len(args) == 0 {
+			return types.AuthConfig{}, errors.Errorf("empty command")
+		}
+		dt, err = runSecretCommand(ctx, args)
+		if err != nil {
+			return types.AuthConfig{}, err
+		}
+	case a.Env != "":
+		v, ok := os.LookupEnv(a.Env)
+		if !ok {
+			return types.AuthConfig{}, errors.Errorf("environment variable %s is not set", a.Env)
+		}
+		dt = []byte(v)
+	case a.FilePath != "":
+		var err error
+		dt, err = os.ReadFile(a.FilePath)
+		if err != nil {
+			return types.AuthConfig{}, err
+		}
+		dt = bytes.TrimRight(dt, "\r\n")
+	default:
+		return types.AuthConfig{}, errors.Errorf("registry auth requires one of cmd, env or src")
+	}
+
+	// the output of a docker credential helper
+	var creds struct {
+		Username string
+		Secret   string
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(dt), []byte("{")) {
+		if err := json.Unmarshal(dt, &creds); err != nil {
+			return types.AuthConfig{}, errors.Wrap(err, "invalid credentials")
+		}
+	} else {
+		creds.Username = a.Username
+		creds.Secret = string(dt)
+	}
+	if a.Username != "" {
+		creds.Username = a.Username
+	}
+	if strings.TrimSpace(creds.Secret) == "" {
+		return types.AuthConfig{}, errors.Errorf("empty credentials")
+	}
+	if creds.Username == "" || creds.Username == "<token>" {
+		return types.AuthConfig{IdentityToken: creds.Secret}, nil
+	}
+	return types.AuthConfig{Username: creds.Username, Password: creds.Secret}, nil
+}
@@ -0,0 +1,60 @@
+package pb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/cli/cli/config/configfile"
+	"github.com/docker/cli/cli/config/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryAuthConfig(t *testing.T) {
+	t.Setenv("GHCR_TOKEN", "s3cret")
+	t.Setenv("HUB_CREDS", `{"Username":"hubuser","Secret":"hubtoken"}`)
+
+	base := configfile.New("config.json")
+	base.AuthConfigs["example.com"] = types.AuthConfig{Username: "user", Password: "pass"}
+	base.AuthConfigs["ghcr.io"] = types.AuthConfig{Username: "user", Password: "pass"}
+
+	c := NewRegistryAuthConfig(context.TODO(), base, []*RegistryAuth{
+		{Host: "ghcr.io", Username: "bot", Env: "GHCR_TOKEN"},
+		{Host: "docker.io", Env: "HUB_CREDS"},
+	})
+
+	ac, err := c.GetAuthConfig("ghcr.io")
+	require.NoError(t, err)
+	require.Equal(t, "bot", ac.Username)
+	require.Equal(t, "s3cret", ac.Password)
+
+	ac, err = c.GetAuthConfig("https://index.docker.io/v1/")
+	require.NoError(t, err)
+	require.Equal(t, "hubuser", ac.Username)
+	require.Equal(t, "hubtoken", ac.Password)
+
+	ac, err = c.GetAuthConfig("example.com")
+	require.NoError(t, err)
+	require.Equal(t, "user", ac.Username)
+
+	// the base config is left untouched
+	require.Equal(t, "user", base.AuthConfigs["ghcr.io"].Username)
+	require.Empty(t, base.CredentialHelpers)
+}
+
+func TestRegistryAuthConfigMissingEnv(t *testing.T) {
+	c := NewRegistryAuthConfig(context.TODO(), nil, []*RegistryAuth{
+		{Host: "ghcr.io", Env: "BUILDX_TEST_UNSET_TOKEN"},
+	})
+	_, err := c.GetAuthConfig("ghcr.io")
+	require.ErrorContains(t, err, "environment variable BUILDX_TEST_UNSET_TOKEN is not set")
+}
+
+func TestRegistryAuthConfigContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	c := NewRegistryAuthConfig(ctx, nil, []*RegistryAuth{
+		{Host: "ghcr.io", Cmd: "echo s3cret"},
+	})
+	_, err := c.GetAuthConfig("ghcr.io")
+	require.ErrorContains(t, err, "failed to get credentials for ghcr.io")
+}
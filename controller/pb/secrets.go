@@ -1,22 +1,155 @@
 package pb
 
 import (
+	"bytes"
+	"context"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/docker/cli/cli/config/types"
+	"github.com/google/shlex"
 	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/secrets"
 	"github.com/moby/buildkit/session/secrets/secretsprovider"
+	"github.com/pkg/errors"
 )
 
-func CreateSecrets(secrets []*Secret) (session.Attachable, error) {
+// RegistryCredentials looks up the credentials of a registry, like the
+// docker config file.
+type RegistryCredentials interface {
+	GetAuthConfig(registryHostname string) (types.AuthConfig, error)
+}
+
+// CreateSecrets returns the session attachable providing the secrets to a
+// build. Secrets read from a command, the keyring or a registry credential
+// are resolved when the build requests them. dockerConfig is used to look up
+// registry credentials and can be nil if no secret uses them.
+func CreateSecrets(secrets []*Secret, dockerConfig RegistryCredentials) (session.Attachable, error) {
 	fs := make([]secretsprovider.Source, 0, len(secrets))
+	ext := map[string]*externalSecret{}
 	for _, secret := range secrets {
-		fs = append(fs, secretsprovider.Source{
-			ID:       secret.ID,
-			FilePath: secret.FilePath,
-			Env:      secret.Env,
-		})
+		var get func(ctx context.Context) ([]byte, error)
+		switch {
+		case secret.Cmd != "":
+			args, err := shlex.Split(secret.Cmd)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid command for secret %s", secret.ID)
+			}
+			if len(args) == 0 {
+				return nil, errors.Errorf("empty command for secret %s", secret.ID)
+			}
+			get = func(ctx context.Context) ([]byte, error) {
+				return runSecretCommand(ctx, args)
+			}
+		case secret.Keyring != "":
+			if runtime.GOOS != "linux" {
+				return nil, errors.Errorf("keyring secrets are only supported on Linux")
+			}
+			service := secret.Keyring
+			get = func(ctx context.Context) ([]byte, error) {
+				return runSecretCommand(ctx, []string{"secret-tool", "lookup", "service", service})
+			}
+		case secret.Registry != "":
+			if dockerConfig == nil {
+				return nil, errors.Errorf("registry credentials are not available for secret %s", secret.ID)
+			}
+			host := secret.Registry
+			get = func(context.Context) ([]byte, error) {
+				return registrySecret(dockerConfig, host)
+			}
+		default:
+			fs = append(fs, secretsprovider.Source{
+				ID:       secret.ID,
+				FilePath: secret.FilePath,
+				Env:      secret.Env,
+			})
+			continue
+		}
+		if secret.ID == "" {
+			return nil, errors.Errorf("secret missing ID")
+		}
+		ext[secret.ID] = &externalSecret{get: get}
 	}
 	store, err := secretsprovider.NewStore(fs)
 	if err != nil {
 		return nil, err
 	}
+	if len(ext) > 0 {
+		store = &externalStore{SecretStore: store, m: ext}
+	}
 	return secretsprovider.NewSecretProvider(store), nil
 }
+
+// externalStore resolves secrets from external sources on first use and
+// falls back to the file and env secrets of the embedded store.
+type externalStore struct {
+	secrets.SecretStore
+	m map[string]*externalSecret
+}
+
+func (s *externalStore) GetSecret(ctx context.Context, id string) ([]byte, error) {
+	if v, ok := s.m[id]; ok {
+		return v.value(ctx)
+	}
+	return s.SecretStore.GetSecret(ctx, id)
+}
+
+type externalSecret struct {
+	get func(ctx context.Context) ([]byte, error)
+
+	mu       sync.Mutex
+	resolved bool
+	dt       []byte
+}
+
+func (s *externalSecret) value(ctx context.Context) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.resolved {
+		dt, err := s.get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.dt = dt
+		s.resolved = true
+	}
+	return s.dt, nil
+}
+
+// runSecretCommand returns the output of a command without its trailing
+// newlines, like a shell command substitution.
+func runSecretCommand(ctx context.Context, args []string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, errors.Wrapf(err, "failed to run %s: %s", args[0], msg)
+		}
+		return nil, errors.Wrapf(err, "failed to run %s", args[0])
+	}
+	return bytes.TrimRight(stdout.Bytes(), "\r\n"), nil
+}
+
+// registrySecret returns the token, or else the password, stored for a
+// registry in the docker config or its credential helpers.
+func registrySecret(dockerConfig RegistryCredentials, host string) ([]byte, error) {
+	switch host {
+	case "docker.io", "index.docker.io", "registry-1.docker.io":
+		host = "https://index.docker.io/v1/"
+	}
+	ac, err := dockerConfig.GetAuthConfig(host)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get credentials for %s", host)
+	}
+	if ac.IdentityToken != "" {
+		return []byte(ac.IdentityToken), nil
+	}
+	if ac.Password != "" {
+		return []byte(ac.Password), nil
+	}
+	return nil, errors.Errorf("no credentials found for %s", host)
+}
@@ -0,0 +1,50 @@
+package pb
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+
+	"github.com/moby/buildkit/session/secrets"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateSecretsCmd(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	a, err := CreateSecrets([]*Secret{
+		{ID: "ok", Cmd: `sh -c "echo s3cret"`},
+		{ID: "fail", Cmd: `sh -c "echo denied >&2; exit 1"`},
+	}, nil)
+	require.NoError(t, err)
+	sp, ok := a.(secrets.SecretsServer)
+	require.True(t, ok)
+
+	resp, err := sp.GetSecret(context.TODO(), &secrets.GetSecretRequest{ID: "ok"})
+	require.NoError(t, err)
+	require.Equal(t, "s3cret", string(resp.Data))
+
+	// the command only runs when the secret is requested
+	_, err = sp.GetSecret(context.TODO(), &secrets.GetSecretRequest{ID: "fail"})
+	require.ErrorContains(t, err, "denied")
+}
+
+func TestExternalSecretEmpty(t *testing.T) {
+	var calls int
+	s := &externalSecret{get: func(context.Context) ([]byte, error) {
+		calls++
+		return nil, nil
+	}}
+	for i := 0; i < 2; i++ {
+		dt, err := s.value(context.TODO())
+		require.NoError(t, err)
+		require.Empty(t, dt)
+	}
+	require.Equal(t, 1, calls)
+}
+
+func TestCreateSecretsRegistryNoConfig(t *testing.T) {
+	_, err := CreateSecrets([]*Secret{{ID: "ghcr", Registry: "ghcr.io"}}, nil)
+	require.ErrorContains(t, err, "registry credentials are not available")
+}
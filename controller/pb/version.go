@@ -0,0 +1,6 @@
+package pb
+
+// APIVersion is the version of the controller API. It is increased on every
+// backwards incompatible change of controller.proto, like removing or
+// renumbering a field. Adding messages, fields and methods is compatible.
+const APIVersion = 1
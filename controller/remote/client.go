@@ -3,6 +3,7 @@ package remote
 import (
 	"context"
 	"io"
+	"strings"
 	"sync"
 	"time"
 
@@ -21,6 +22,10 @@ import (
 	"google.golang.org/grpc/credentials/insecure"
 )
 
+// NewClient connects to the controller API served at addr. addr is either the
+// path of a unix socket or an address with the "unix://" scheme, as accepted by
+// "buildx controller serve". It fails if the server serves another version of
+// the API than pb.APIVersion.
 func NewClient(ctx context.Context, addr string) (*Client, error) {
 	backoffConfig := backoff.DefaultConfig
 	backoffConfig.MaxDelay = 3 * time.Second
@@ -38,14 +43,28 @@ func NewClient(ctx context.Context, addr string) (*Client, error) {
 		grpc.WithUnaryInterceptor(grpcerrors.UnaryClientInterceptor),
 		grpc.WithStreamInterceptor(grpcerrors.StreamClientInterceptor),
 	}
+	if !strings.HasPrefix(addr, "unix://") {
+		addr = dialer.DialAddress(addr)
+	}
 	//nolint:staticcheck // ignore SA1019: Recommended NewClient has different behavior from DialContext.
-	conn, err := grpc.DialContext(ctx, dialer.DialAddress(addr), gopts...)
+	conn, err := grpc.DialContext(ctx, addr, gopts...)
 	if err != nil {
 		return nil, err
 	}
-	return &Client{conn: conn}, nil
+	c := &Client{conn: conn}
+	v, err := c.APIVersion(ctx)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	if v != pb.APIVersion {
+		c.Close()
+		return nil, errors.Errorf("unsupported controller API version %d, expected %d", v, pb.APIVersion)
+	}
+	return c, nil
 }
 
+// Client is a client of the controller API.
 type Client struct {
 	conn      *grpc.ClientConn
 	closeOnce sync.Once
@@ -67,6 +86,16 @@ func (c *Client) Version(ctx context.Context) (string, string, string, error) {
 	return v.Package, v.Version, v.Revision, nil
 }
 
+// APIVersion returns the version of the controller API served. Servers
+// predating the versioning of the API return 0.
+func (c *Client) APIVersion(ctx context.Context) (uint32, error) {
+	res, err := c.client().Info(ctx, &pb.InfoRequest{})
+	if err != nil {
+		return 0, err
+	}
+	return res.ApiVersion, nil
+}
+
 func (c *Client) List(ctx context.Context) (keys []string, retErr error) {
 	res, err := c.client().List(ctx, &pb.ListRequest{})
 	if err != nil {
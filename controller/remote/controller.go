@@ -10,8 +10,10 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"os/user"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -20,12 +22,14 @@ import (
 	cbuild "github.com/docker/buildx/controller/build"
 	"github.com/docker/buildx/controller/control"
 	controllerapi "github.com/docker/buildx/controller/pb"
+	"github.com/docker/buildx/util/cobrautil"
+	"github.com/docker/buildx/util/cobrautil/completion"
 	"github.com/docker/buildx/util/confutil"
 	"github.com/docker/buildx/util/progress"
 	"github.com/docker/buildx/version"
+	"github.com/docker/cli/cli"
 	"github.com/docker/cli/cli/command"
 	"github.com/moby/buildkit/client"
-	"github.com/moby/buildkit/util/grpcerrors"
 	"github.com/pelletier/go-toml"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -43,6 +47,11 @@ var (
 	defaultPIDFilename    = fmt.Sprintf("buildx.%s.pid", version.Revision)
 )
 
+// defaultAPISocketFilename is the socket of the controller API served by
+// "buildx controller serve". Unlike the debug server socket, it does not
+// depend on the buildx revision.
+const defaultAPISocketFilename = "buildx.sock"
+
 type serverConfig struct {
 	// Specify buildx server root
 	Root string `toml:"root"`
@@ -109,6 +118,7 @@ func NewRemoteBuildxController(ctx context.Context, dockerCli command.Cli, opts
 func AddControllerCommands(cmd *cobra.Command, dockerCli command.Cli) {
 	cmd.AddCommand(
 		serveCmd(dockerCli),
+		controllerCmd(dockerCli),
 	)
 }
 
@@ -123,14 +133,8 @@ func serveCmd(dockerCli command.Cli) *cobra.Command {
 			if err != nil {
 				return err
 			}
-			if config.LogLevel == "" {
-				logrus.SetLevel(logrus.InfoLevel)
-			} else {
-				lvl, err := logrus.ParseLevel(config.LogLevel)
-				if err != nil {
-					return errors.Wrap(err, "failed to prepare logger")
-				}
-				logrus.SetLevel(lvl)
+			if err := setLogLevel(config); err != nil {
+				return err
 			}
 			logrus.SetFormatter(&logrus.JSONFormatter{
 				TimestampFormat: log.RFC3339NanoFixed,
@@ -170,35 +174,7 @@ func serveCmd(dockerCli command.Cli) *cobra.Command {
 			if err != nil {
 				return err
 			}
-			rpc := grpc.NewServer(
-				grpc.UnaryInterceptor(grpcerrors.UnaryServerInterceptor),
-				grpc.StreamInterceptor(grpcerrors.StreamServerInterceptor),
-			)
-			controllerapi.RegisterControllerServer(rpc, b)
-			doneCh := make(chan struct{})
-			errCh := make(chan error, 1)
-			go func() {
-				defer close(doneCh)
-				if err := rpc.Serve(l); err != nil {
-					errCh <- errors.Wrapf(err, "error on serving via socket %q", addr)
-				}
-			}()
-
-			var s os.Signal
-			sigCh := make(chan os.Signal, 1)
-			signal.Notify(sigCh, syscall.SIGINT)
-			signal.Notify(sigCh, syscall.SIGTERM)
-			select {
-			case err := <-errCh:
-				logrus.Errorf("got error %s, exiting", err)
-				return err
-			case s = <-sigCh:
-				logrus.Infof("got signal %s, exiting", s)
-				return nil
-			case <-doneCh:
-				logrus.Infof("rpc server done, exiting")
-				return nil
-			}
+			return serve(NewGRPCServer(b), l, addr)
 		},
 	}
 
@@ -207,6 +183,193 @@ func serveCmd(dockerCli command.Cli) *cobra.Command {
 	return cmd
 }
 
+func controllerCmd(dockerCli command.Cli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "controller",
+		Short: "Manage the buildx controller API",
+	}
+	cobrautil.MarkCommandExperimental(cmd)
+
+	cmd.AddCommand(
+		controllerServeCmd(dockerCli),
+	)
+	return cmd
+}
+
+type controllerServeOptions struct {
+	addr       string
+	group      string
+	configPath string
+}
+
+func controllerServeCmd(dockerCli command.Cli) *cobra.Command {
+	var options controllerServeOptions
+	cmd := &cobra.Command{
+		Use:   "serve [OPTIONS]",
+		Short: "Serve the controller API over a unix socket",
+		Args:  cli.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runControllerServe(dockerCli, options)
+		},
+		ValidArgsFunction: completion.Disable,
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.addr, "addr", "", `Address to listen on (e.g., "unix:///run/buildx/buildx.sock")`)
+	flags.StringVar(&options.group, "group", "", "Group allowed to access the socket, in addition to the current user")
+	flags.StringVar(&options.configPath, "config", "", "Specify buildx server config file")
+	return cmd
+}
+
+func runControllerServe(dockerCli command.Cli, options controllerServeOptions) error {
+	config, err := getConfig(dockerCli, options.configPath)
+	if err != nil {
+		return err
+	}
+	if err := setLogLevel(config); err != nil {
+		return err
+	}
+
+	addr := options.addr
+	if addr == "" {
+		root, err := prepareRootDir(dockerCli, config)
+		if err != nil {
+			return err
+		}
+		addr = "unix://" + filepath.Join(root, defaultAPISocketFilename)
+	}
+	l, err := listenSocket(addr, options.group)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.Remove(strings.TrimPrefix(addr, "unix://")); err != nil && !os.IsNotExist(err) {
+			logrus.Errorf("failed to clean up socket %q: %v", addr, err)
+		}
+	}()
+
+	b := NewServer(func(ctx context.Context, options *controllerapi.BuildOptions, stdin io.Reader, progress progress.Writer) (*client.SolveResponse, *build.ResultHandle, *build.Inputs, error) {
+		return cbuild.RunBuild(ctx, dockerCli, options, stdin, progress, true)
+	})
+	defer b.Close()
+
+	logrus.Infof("serving controller API at %q", addr)
+	return serve(NewGRPCServer(b), l, addr)
+}
+
+// listenSocket listens on the unix socket at addr. Clients are authorized by
+// the socket permissions: only the current user, and the members of group if
+// set, can connect.
+func listenSocket(addr string, group string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, "unix://")
+	if !ok {
+		return nil, errors.Errorf("unsupported address %q: only unix sockets are supported", addr)
+	}
+	gid := -1
+	if group != "" {
+		var err error
+		if gid, err = lookupGID(group); err != nil {
+			return nil, err
+		}
+	}
+	dir := filepath.Dir(path)
+	_, err := os.Stat(dir)
+	created := os.IsNotExist(err)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	if gid != -1 && created {
+		// the members of the group must be able to reach the socket, an
+		// existing directory is left as is
+		if err := os.Chown(dir, -1, gid); err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(dir, 0750); err != nil {
+			return nil, err
+		}
+	}
+	if fi, err := os.Lstat(path); err == nil { // avoid EADDRINUSE
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, errors.Errorf("failed to listen on %q: file exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	mode := os.FileMode(0600)
+	if gid != -1 {
+		if err := os.Chown(path, -1, gid); err != nil {
+			l.Close()
+			return nil, err
+		}
+		mode = 0660
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func lookupGID(group string) (int, error) {
+	if gid, err := strconv.Atoi(group); err == nil {
+		return gid, nil
+	}
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return -1, errors.Wrapf(err, "failed to look up group %q", group)
+	}
+	return strconv.Atoi(g.Gid)
+}
+
+// serve serves rpc on l until the server fails or the process receives
+// SIGINT or SIGTERM.
+func serve(rpc *grpc.Server, l net.Listener, addr string) error {
+	doneCh := make(chan struct{})
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(doneCh)
+		if err := rpc.Serve(l); err != nil {
+			errCh <- errors.Wrapf(err, "error on serving via socket %q", addr)
+		}
+	}()
+
+	var s os.Signal
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT)
+	signal.Notify(sigCh, syscall.SIGTERM)
+	select {
+	case err := <-errCh:
+		logrus.Errorf("got error %s, exiting", err)
+		return err
+	case s = <-sigCh:
+		logrus.Infof("got signal %s, exiting", s)
+		return nil
+	case <-doneCh:
+		logrus.Infof("rpc server done, exiting")
+		return nil
+	}
+}
+
+func setLogLevel(config *serverConfig) error {
+	if config.LogLevel == "" {
+		logrus.SetLevel(logrus.InfoLevel)
+		return nil
+	}
+	lvl, err := logrus.ParseLevel(config.LogLevel)
+	if err != nil {
+		return errors.Wrap(err, "failed to prepare logger")
+	}
+	logrus.SetLevel(lvl)
+	return nil
+}
+
 func getLogFilePath(dockerCli command.Cli, configPath string) (string, error) {
 	config, err := getConfig(dockerCli, configPath)
 	if err != nil {
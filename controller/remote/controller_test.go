@@ -0,0 +1,60 @@
+//go:build linux
+
+package remote
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenSocket(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "run")
+	path := filepath.Join(dir, "buildx.sock")
+
+	l, err := listenSocket("unix://"+path, "")
+	require.NoError(t, err)
+	fi, err := os.Stat(dir)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0700), fi.Mode().Perm())
+	fi, err = os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+
+	// a stale socket is replaced, and the existing directory is left as is
+	l, err = listenSocket("unix://"+path, strconv.Itoa(os.Getgid()))
+	require.NoError(t, err)
+	fi, err = os.Stat(dir)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0700), fi.Mode().Perm())
+	fi, err = os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0660), fi.Mode().Perm())
+	l.Close()
+
+	t.Run("Group", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "run")
+		l, err := listenSocket("unix://"+filepath.Join(dir, "buildx.sock"), strconv.Itoa(os.Getgid()))
+		require.NoError(t, err)
+		defer l.Close()
+		fi, err := os.Stat(dir)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0750), fi.Mode().Perm())
+	})
+
+	t.Run("NotSocket", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "buildx.sock")
+		require.NoError(t, os.WriteFile(path, []byte("data"), 0600))
+		_, err := listenSocket("unix://"+path, "")
+		require.ErrorContains(t, err, "is not a socket")
+		dt, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "data", string(dt))
+	})
+}
@@ -16,12 +16,24 @@ import (
 	"github.com/docker/buildx/util/progress"
 	"github.com/docker/buildx/version"
 	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/util/grpcerrors"
 	"github.com/pkg/errors"
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
 )
 
 type BuildFunc func(ctx context.Context, options *pb.BuildOptions, stdin io.Reader, progress progress.Writer) (resp *client.SolveResponse, res *build.ResultHandle, inp *build.Inputs, err error)
 
+// NewGRPCServer returns a gRPC server exposing the controller API served by m.
+func NewGRPCServer(m *Server) *grpc.Server {
+	rpc := grpc.NewServer(
+		grpc.UnaryInterceptor(grpcerrors.UnaryServerInterceptor),
+		grpc.StreamInterceptor(grpcerrors.StreamServerInterceptor),
+	)
+	pb.RegisterControllerServer(rpc, m)
+	return rpc
+}
+
 func NewServer(buildFunc BuildFunc) *Server {
 	return &Server{
 		buildFunc: buildFunc,
@@ -79,6 +91,7 @@ func (m *Server) Info(ctx context.Context, req *pb.InfoRequest) (res *pb.InfoRes
 			Version:  version.Version,
 			Revision: version.Revision,
 		},
+		ApiVersion: pb.APIVersion,
 	}, nil
 }
 
@@ -0,0 +1,124 @@
+package remote
+
+import (
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/buildx/build"
+	"github.com/docker/buildx/controller/pb"
+	"github.com/docker/buildx/util/progress"
+	"github.com/docker/buildx/version"
+	"github.com/moby/buildkit/client"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerClient(t *testing.T) {
+	vtx := &client.Vertex{
+		Digest: digest.FromString("vertex"),
+		Name:   "[internal] load build definition",
+	}
+	srv := NewServer(func(ctx context.Context, options *pb.BuildOptions, stdin io.Reader, pw progress.Writer) (*client.SolveResponse, *build.ResultHandle, *build.Inputs, error) {
+		dt, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		pw.Write(&client.SolveStatus{Vertexes: []*client.Vertex{vtx}})
+		return &client.SolveResponse{
+			ExporterResponse: map[string]string{
+				"target": options.Target,
+				"stdin":  string(dt),
+			},
+		}, nil, nil, nil
+	})
+	defer srv.Close()
+
+	c := newTestClient(t, srv)
+
+	p, v, r, err := c.Version(context.TODO())
+	require.NoError(t, err)
+	require.Equal(t, version.Package, p)
+	require.Equal(t, version.Version, v)
+	require.Equal(t, version.Revision, r)
+
+	apiVersion, err := c.APIVersion(context.TODO())
+	require.NoError(t, err)
+	require.Equal(t, uint32(pb.APIVersion), apiVersion)
+
+	pw := &testWriter{}
+	ref, resp, _, err := c.Build(context.TODO(), &pb.BuildOptions{Target: "app"}, io.NopCloser(strings.NewReader("hello")), pw)
+	require.NoError(t, err)
+	require.NotEmpty(t, ref)
+	require.Equal(t, map[string]string{"target": "app", "stdin": "hello"}, resp.ExporterResponse)
+
+	require.Len(t, pw.statuses, 1)
+	require.Len(t, pw.statuses[0].Vertexes, 1)
+	require.Equal(t, vtx.Digest, pw.statuses[0].Vertexes[0].Digest)
+	require.Equal(t, vtx.Name, pw.statuses[0].Vertexes[0].Name)
+
+	refs, err := c.List(context.TODO())
+	require.NoError(t, err)
+	require.Equal(t, []string{ref}, refs)
+
+	require.NoError(t, c.Disconnect(context.TODO(), ref))
+	refs, err = c.List(context.TODO())
+	require.NoError(t, err)
+	require.Empty(t, refs)
+}
+
+func TestServerBuildError(t *testing.T) {
+	srv := NewServer(func(ctx context.Context, options *pb.BuildOptions, stdin io.Reader, pw progress.Writer) (*client.SolveResponse, *build.ResultHandle, *build.Inputs, error) {
+		return nil, nil, nil, errors.New("failed to solve")
+	})
+	defer srv.Close()
+
+	c := newTestClient(t, srv)
+	_, _, _, err := c.Build(context.TODO(), &pb.BuildOptions{}, nil, &testWriter{})
+	require.ErrorContains(t, err, "failed to solve")
+
+	_, err = c.Inspect(context.TODO(), "unknown")
+	require.ErrorContains(t, err, "unknown key")
+}
+
+func newTestClient(t *testing.T, srv *Server) *Client {
+	addr := "unix://" + filepath.Join(t.TempDir(), "buildx.sock")
+	l, err := net.Listen("unix", strings.TrimPrefix(addr, "unix://"))
+	require.NoError(t, err)
+
+	rpc := NewGRPCServer(srv)
+	go rpc.Serve(l)
+	t.Cleanup(rpc.Stop)
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancel()
+	c, err := NewClient(ctx, addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+type testWriter struct {
+	mu       sync.Mutex
+	statuses []*client.SolveStatus
+}
+
+func (w *testWriter) Write(st *client.SolveStatus) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.statuses = append(w.statuses, st)
+}
+
+func (w *testWriter) WriteBuildRef(string, string) {}
+
+func (w *testWriter) ValidateLogSource(digest.Digest, interface{}) bool {
+	return true
+}
+
+func (w *testWriter) ClearLogSource(interface{}) {}
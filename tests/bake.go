@@ -57,6 +57,8 @@ var bakeTests = []func(t *testing.T, sb integration.Sandbox){
 	testBakeDefinitionSymlinkOutsideGrantedNoParallel,
 	testBakeShmSize,
 	testBakeUlimits,
+	testBakeNoFailFast,
+	testBakeTest,
 	testBakeMetadataProvenance,
 	testBakeMetadataWarnings,
 	testBakeMetadataWarningsDedup,
@@ -682,6 +684,98 @@ func testBakeEmpty(t *testing.T, sb integration.Sandbox) {
 	require.Contains(t, out, "couldn't find a bake definition")
 }
 
+func testBakeNoFailFast(t *testing.T, sb integration.Sandbox) {
+	bakefile := []byte(`
+group "default" {
+  targets = ["ok", "fail", "dep"]
+}
+target "ok" {
+  dockerfile-inline = <<EOT
+FROM scratch
+COPY foo /foo
+EOT
+}
+target "fail" {
+  dockerfile-inline = <<EOT
+FROM busybox
+RUN exit 1
+EOT
+}
+target "dep" {
+  contexts = {
+    base = "target:fail"
+  }
+  dockerfile-inline = <<EOT
+FROM base
+EOT
+}
+`)
+	dir := tmpdir(
+		t,
+		fstest.CreateFile("docker-bake.hcl", bakefile, 0600),
+		fstest.CreateFile("foo", []byte("foo"), 0600),
+	)
+
+	dirDest := t.TempDir()
+
+	cmd := buildxCmd(sb, withDir(dir), withArgs("bake", "--progress=plain", "--fail-fast=false", "--max-parallelism=1", "--set", "ok.output=type=local,dest="+dirDest))
+	dt, err := cmd.CombinedOutput()
+	out := string(dt)
+	require.Error(t, err, out)
+	require.FileExists(t, filepath.Join(dirDest, "foo"))
+	require.Regexp(t, `dep\s+skipped \(dependency fail failed\)`, out)
+	require.Regexp(t, `fail\s+failed`, out)
+	require.Regexp(t, `ok\s+done`, out)
+}
+
+func testBakeTest(t *testing.T, sb integration.Sandbox) {
+	bakefile := []byte(`
+target "default" {
+  dockerfile-inline = <<EOT
+FROM busybox
+RUN echo "hello" > /greeting
+ENV NAME=bake
+EOT
+}
+test "greeting" {
+  target = "default"
+  command = ["cat", "/greeting"]
+  output-contains = ["hello"]
+}
+test "env" {
+  target = "default"
+  command = ["sh", "-c", "echo $NAME-$SUFFIX; exit 3"]
+  env = {
+    SUFFIX = "test"
+  }
+  exit-code = 3
+  output-matches = ["(?m)^bake-test$"]
+}
+test "fail" {
+  target = "default"
+  command = ["false"]
+}
+`)
+	dir := tmpdir(
+		t,
+		fstest.CreateFile("docker-bake.hcl", bakefile, 0600),
+	)
+
+	cmd := buildxCmd(sb, withDir(dir), withArgs("bake", "--progress=plain", "--test", "--junit-file=junit.xml"))
+	dt, err := cmd.CombinedOutput()
+	out := string(dt)
+	require.Error(t, err, out)
+	require.Contains(t, out, "1 of 3 tests failed")
+	require.Regexp(t, `env\s+default\s+pass`, out)
+	require.Regexp(t, `greeting\s+default\s+pass`, out)
+	require.Regexp(t, `fail\s+default\s+fail: expected exit code 0, got 1`, out)
+
+	dt, err = os.ReadFile(filepath.Join(dir, "junit.xml"))
+	require.NoError(t, err)
+	require.Contains(t, string(dt), `<testsuites tests="3" failures="1" errors="0"`)
+	require.Contains(t, string(dt), `<failure message="expected exit code 0, got 1"></failure>`)
+}
+
 func testBakeShmSize(t *testing.T, sb integration.Sandbox) {
 	dockerfile := []byte(`
 FROM busybox AS build
@@ -1415,7 +1509,7 @@ target "default" {
 	)
 	require.NoError(t, err, out)
 
-	require.Equal(t, "VARIABLE\tVALUE\tDESCRIPTION\nabc\t\t<null>\t\ndef\t\t\t\nfoo\t\tbar\tThis is foo", strings.TrimSpace(out))
+	require.Equal(t, "VARIABLE\tVALUE\tSOURCE\tDESCRIPTION\nabc\t\t<null>\tdefault\t\ndef\t\t\tdefault\t\nfoo\t\tbar\tdefault\tThis is foo", strings.TrimSpace(out))
 }
 
 func testBakeCallCheck(t *testing.T, sb integration.Sandbox) {
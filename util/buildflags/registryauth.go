@@ -0,0 +1,66 @@
+package buildflags
+
+import (
+	"strings"
+
+	controllerapi "github.com/docker/buildx/controller/pb"
+	"github.com/pkg/errors"
+	"github.com/tonistiigi/go-csvvalue"
+)
+
+func ParseRegistryAuthSpecs(sl []string) ([]*controllerapi.RegistryAuth, error) {
+	as := make([]*controllerapi.RegistryAuth, 0, len(sl))
+	for _, v := range sl {
+		if v == "" {
+			continue
+		}
+		a, err := parseRegistryAuth(v)
+		if err != nil {
+			return nil, err
+		}
+		as = append(as, a)
+	}
+	return as, nil
+}
+
+func parseRegistryAuth(value string) (*controllerapi.RegistryAuth, error) {
+	fields, err := csvvalue.Fields(value, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse csv registry auth")
+	}
+
+	a := controllerapi.RegistryAuth{}
+	for _, field := range fields {
+		key, v, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, errors.Errorf("invalid field '%s' must be a key=value pair", field)
+		}
+		switch strings.ToLower(key) {
+		case "host":
+			a.Host = v
+		case "username", "user":
+			a.Username = v
+		case "cmd":
+			a.Cmd = v
+		case "env":
+			a.Env = v
+		case "source", "src":
+			a.FilePath = v
+		default:
+			return nil, errors.Errorf("unexpected key '%s' in '%s'", key, field)
+		}
+	}
+	if a.Host == "" {
+		return nil, errors.Errorf("registry auth %q requires a host", value)
+	}
+	var sources int
+	for _, v := range []string{a.Cmd, a.Env, a.FilePath} {
+		if v != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return nil, errors.Errorf("registry auth %q requires one of cmd, env or src", value)
+	}
+	return &a, nil
+}
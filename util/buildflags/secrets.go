@@ -1,6 +1,7 @@
 package buildflags
 
 import (
+	"cmp"
 	"strings"
 
 	controllerapi "github.com/docker/buildx/controller/pb"
@@ -43,7 +44,9 @@ func parseSecret(value string) (*controllerapi.Secret, error) {
 		value := parts[1]
 		switch key {
 		case "type":
-			if value != "file" && value != "env" {
+			switch value {
+			case "file", "env", "cmd", "keyring", "registry":
+			default:
 				return nil, errors.Errorf("unsupported secret type %q", value)
 			}
 			typ = value
@@ -53,13 +56,43 @@ func parseSecret(value string) (*controllerapi.Secret, error) {
 			fs.FilePath = value
 		case "env":
 			fs.Env = value
+		case "cmd":
+			fs.Cmd = value
+		case "keyring":
+			fs.Keyring = value
+		case "registry":
+			fs.Registry = value
 		default:
 			return nil, errors.Errorf("unexpected key '%s' in '%s'", key, field)
 		}
 	}
-	if typ == "env" && fs.Env == "" {
-		fs.Env = fs.FilePath
-		fs.FilePath = ""
+	switch typ {
+	case "env":
+		if fs.Env == "" {
+			fs.Env = fs.FilePath
+			fs.FilePath = ""
+		}
+	case "cmd":
+		if fs.Cmd == "" {
+			fs.Cmd = fs.FilePath
+			fs.FilePath = ""
+		}
+		if fs.Cmd == "" {
+			return nil, errors.Errorf("secret %q requires a command", fs.ID)
+		}
+	case "keyring":
+		if fs.Keyring == "" {
+			fs.Keyring = cmp.Or(fs.FilePath, fs.ID)
+			fs.FilePath = ""
+		}
+	case "registry":
+		if fs.Registry == "" {
+			fs.Registry = fs.FilePath
+			fs.FilePath = ""
+		}
+		if fs.Registry == "" {
+			return nil, errors.Errorf("secret %q requires a registry", fs.ID)
+		}
 	}
 	return &fs, nil
 }
@@ -0,0 +1,84 @@
+package buildflags
+
+import (
+	"testing"
+
+	controllerapi "github.com/docker/buildx/controller/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSecretSpecs(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    *controllerapi.Secret
+		wantErr string
+	}{
+		{
+			name: "file",
+			in:   "id=mysecret,src=/local/secret",
+			want: &controllerapi.Secret{ID: "mysecret", FilePath: "/local/secret"},
+		},
+		{
+			name: "env",
+			in:   "id=mysecret,type=env,src=TOKEN",
+			want: &controllerapi.Secret{ID: "mysecret", Env: "TOKEN"},
+		},
+		{
+			name: "cmd",
+			in:   `id=npm,"cmd=pass show npm"`,
+			want: &controllerapi.Secret{ID: "npm", Cmd: "pass show npm"},
+		},
+		{
+			name: "cmd type",
+			in:   `id=npm,type=cmd,"src=pass show npm"`,
+			want: &controllerapi.Secret{ID: "npm", Cmd: "pass show npm"},
+		},
+		{
+			name: "keyring",
+			in:   "id=npm,type=keyring,src=npm",
+			want: &controllerapi.Secret{ID: "npm", Keyring: "npm"},
+		},
+		{
+			name: "keyring defaults to id",
+			in:   "id=npm,type=keyring",
+			want: &controllerapi.Secret{ID: "npm", Keyring: "npm"},
+		},
+		{
+			name:    "cmd missing",
+			in:      "id=npm,type=cmd",
+			wantErr: `secret "npm" requires a command`,
+		},
+		{
+			name: "registry",
+			in:   "id=ghcr,registry=ghcr.io",
+			want: &controllerapi.Secret{ID: "ghcr", Registry: "ghcr.io"},
+		},
+		{
+			name: "file and env",
+			in:   "id=mysecret,src=/local/secret,env=TOKEN",
+			want: &controllerapi.Secret{ID: "mysecret", FilePath: "/local/secret", Env: "TOKEN"},
+		},
+		{
+			name: "multiple sources",
+			in:   "id=npm,keyring=npm,registry=ghcr.io",
+			want: &controllerapi.Secret{ID: "npm", Keyring: "npm", Registry: "ghcr.io"},
+		},
+		{
+			name:    "unsupported type",
+			in:      "id=npm,type=vault",
+			wantErr: `unsupported secret type "vault"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSecretSpecs([]string{tt.in})
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, []*controllerapi.Secret{tt.want}, got)
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package buildflags
+
+import (
+	"strings"
+
+	controllerapi "github.com/docker/buildx/controller/pb"
+	"github.com/pkg/errors"
+	"github.com/tonistiigi/go-csvvalue"
+)
+
+// ParseSign parses the --sign flag value, either "key=<path>" for a local
+// private key or "keyless", with optional "fulcio" and "rekor" endpoints.
+func ParseSign(value string) (*controllerapi.SignOptions, error) {
+	if value == "" {
+		return nil, nil
+	}
+	fields, err := csvvalue.Fields(value, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse csv sign options")
+	}
+
+	s := controllerapi.SignOptions{}
+	for _, field := range fields {
+		key, v, ok := strings.Cut(field, "=")
+		if !ok {
+			if strings.ToLower(field) == "keyless" {
+				s.Keyless = true
+				continue
+			}
+			return nil, errors.Errorf("invalid field '%s' must be a key=value pair or keyless", field)
+		}
+		switch strings.ToLower(key) {
+		case "key":
+			s.Key = v
+		case "fulcio":
+			s.FulcioURL = v
+		case "rekor":
+			s.RekorURL = v
+		default:
+			return nil, errors.Errorf("unexpected key '%s' in '%s'", key, field)
+		}
+	}
+	if s.Keyless == (s.Key != "") {
+		return nil, errors.Errorf("sign options %q require one of key or keyless", value)
+	}
+	if s.FulcioURL != "" && !s.Keyless {
+		return nil, errors.Errorf("sign options %q set fulcio without keyless", value)
+	}
+	return &s, nil
+}
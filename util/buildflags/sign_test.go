@@ -0,0 +1,62 @@
+package buildflags
+
+import (
+	"testing"
+
+	controllerapi "github.com/docker/buildx/controller/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSign(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    *controllerapi.SignOptions
+		wantErr string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "key",
+			in:   "key=cosign.key",
+			want: &controllerapi.SignOptions{Key: "cosign.key"},
+		},
+		{
+			name: "key with rekor",
+			in:   "key=cosign.key,rekor=https://rekor.example.com",
+			want: &controllerapi.SignOptions{Key: "cosign.key", RekorURL: "https://rekor.example.com"},
+		},
+		{
+			name: "keyless",
+			in:   "keyless,fulcio=https://fulcio.example.com,rekor=https://rekor.example.com",
+			want: &controllerapi.SignOptions{Keyless: true, FulcioURL: "https://fulcio.example.com", RekorURL: "https://rekor.example.com"},
+		},
+		{
+			name:    "key and keyless",
+			in:      "keyless,key=cosign.key",
+			wantErr: "require one of key or keyless",
+		},
+		{
+			name:    "fulcio with key",
+			in:      "key=cosign.key,fulcio=https://fulcio.example.com",
+			wantErr: "set fulcio without keyless",
+		},
+		{
+			name:    "unknown",
+			in:      "key=cosign.key,foo=bar",
+			wantErr: "unexpected key 'foo'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSign(tt.in)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -1,4 +1,4 @@
-package commands
+package cobrautil
 
 import (
 	"bufio"
@@ -12,7 +12,9 @@ import (
 	"github.com/docker/cli/cli/streams"
 )
 
-func prompt(ctx context.Context, ins io.Reader, out io.Writer, msg string) (bool, error) {
+// Prompt asks the user to confirm msg. It returns an error if ctx is done
+// before they answer.
+func Prompt(ctx context.Context, ins io.Reader, out io.Writer, msg string) (bool, error) {
 	done := make(chan struct{})
 	var ok bool
 	go func() {
@@ -66,6 +66,16 @@ func (c *Git) IsDirty() bool {
 	return strings.TrimSpace(out) != "" || err != nil
 }
 
+// HasChanges reports whether the working tree has changes, including the
+// untracked files but not the ignored ones, unlike IsDirty.
+func (c *Git) HasChanges() (bool, error) {
+	out, err := c.run("status", "--porcelain")
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(out) != "", nil
+}
+
 func (c *Git) RootDir() (string, error) {
 	root, err := c.clean(c.run("rev-parse", "--show-toplevel"))
 	if err != nil {
@@ -112,9 +122,7 @@ func (c *Git) Tag() (string, error) {
 	var tag string
 	var err error
 	for _, fn := range []func() (string, error){
-		func() (string, error) {
-			return c.clean(c.run("tag", "--points-at", "HEAD", "--sort", "-version:creatordate"))
-		},
+		c.TagAtHead,
 		func() (string, error) {
 			return c.clean(c.run("describe", "--tags", "--abbrev=0"))
 		},
@@ -127,6 +135,22 @@ func (c *Git) Tag() (string, error) {
 	return tag, err
 }
 
+// TagAtHead returns the most recent tag pointing at HEAD, or an empty string
+// if HEAD is not tagged.
+func (c *Git) TagAtHead() (string, error) {
+	return c.clean(c.run("tag", "--points-at", "HEAD", "--sort", "-version:creatordate"))
+}
+
+// Branch returns the name of the current branch, or an empty string if HEAD
+// is detached.
+func (c *Git) Branch() (string, error) {
+	branch, err := c.clean(c.run("rev-parse", "--abbrev-ref", "HEAD"))
+	if err != nil || branch == "HEAD" {
+		return "", err
+	}
+	return branch, nil
+}
+
 func (c *Git) run(args ...string) (string, error) {
 	var extraArgs = []string{
 		"-c", "log.showSignature=false",
@@ -1,6 +1,7 @@
 package gitutil
 
 import (
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -104,6 +105,67 @@ func TestGitDescribeTags(t *testing.T) {
 	require.Equal(t, "v0.9.0", out)
 }
 
+func TestGitTagAtHead(t *testing.T) {
+	Mktmp(t)
+	c, err := New()
+	require.NoError(t, err)
+
+	GitInit(c, t)
+	GitCommit(c, t, "bar")
+	GitTag(c, t, "v0.8.0")
+	GitCommit(c, t, "foo")
+
+	out, err := c.TagAtHead()
+	require.NoError(t, err)
+	require.Empty(t, out)
+
+	GitTag(c, t, "v0.9.0")
+	out, err = c.TagAtHead()
+	require.NoError(t, err)
+	require.Equal(t, "v0.9.0", out)
+}
+
+func TestGitHasChanges(t *testing.T) {
+	Mktmp(t)
+	c, err := New()
+	require.NoError(t, err)
+
+	GitInit(c, t)
+	require.NoError(t, os.WriteFile(".gitignore", []byte("ignored\n"), 0644))
+	GitAdd(c, t, ".gitignore")
+	GitCommit(c, t, "foo")
+
+	require.NoError(t, os.WriteFile("ignored", []byte("foo"), 0644))
+	changes, err := c.HasChanges()
+	require.NoError(t, err)
+	require.False(t, changes)
+	require.True(t, c.IsDirty())
+
+	require.NoError(t, os.WriteFile("untracked", []byte("foo"), 0644))
+	changes, err = c.HasChanges()
+	require.NoError(t, err)
+	require.True(t, changes)
+}
+
+func TestGitBranch(t *testing.T) {
+	Mktmp(t)
+	c, err := New()
+	require.NoError(t, err)
+
+	GitInit(c, t)
+	GitCommit(c, t, "foo")
+
+	out, err := c.Branch()
+	require.NoError(t, err)
+	require.Equal(t, "main", out)
+
+	_, err = fakeGit(c, "checkout", "--detach")
+	require.NoError(t, err)
+	out, err = c.Branch()
+	require.NoError(t, err)
+	require.Empty(t, out)
+}
+
 func TestGitRemoteURL(t *testing.T) {
 	type remote struct {
 		name     string
@@ -26,6 +26,105 @@ import (
 type Source struct {
 	Desc ocispec.Descriptor
 	Ref  reference.Named
+
+	// Platforms limits the manifests taken from the source to the ones
+	// matching one of the selectors. All manifests are taken if empty.
+	Platforms []PlatformSelector
+}
+
+// PlatformSelector matches the manifests of a platform and optionally
+// replaces their platform in the resulting index.
+type PlatformSelector struct {
+	Platform ocispec.Platform
+	Rewrite  *ocispec.Platform
+}
+
+// ParsePlatformSelector parses a platform selector in the form of
+// "platform[=rewrite]", e.g. "linux/arm64=linux/arm64/v8".
+func ParsePlatformSelector(in string) (PlatformSelector, error) {
+	from, to, ok := strings.Cut(in, "=")
+	p, err := platforms.Parse(from)
+	if err != nil {
+		return PlatformSelector{}, errors.Wrapf(err, "invalid platform %q", from)
+	}
+	s := PlatformSelector{Platform: p}
+	if ok {
+		p, err := platforms.Parse(to)
+		if err != nil {
+			return PlatformSelector{}, errors.Wrapf(err, "invalid platform %q", to)
+		}
+		s.Rewrite = &p
+	}
+	return s, nil
+}
+
+func (s PlatformSelector) String() string {
+	if s.Rewrite != nil {
+		return platforms.Format(s.Platform) + "=" + platforms.Format(*s.Rewrite)
+	}
+	return platforms.Format(s.Platform)
+}
+
+// selectPlatform returns the descriptor with its platform rewritten if it
+// matches one of the platform selectors of the source.
+func (s *Source) selectPlatform(desc ocispec.Descriptor) (ocispec.Descriptor, bool) {
+	if len(s.Platforms) == 0 {
+		return desc, true
+	}
+	if desc.Platform == nil {
+		return desc, false
+	}
+	for _, sel := range s.Platforms {
+		if !platforms.NewMatcher(sel.Platform).Match(*desc.Platform) {
+			continue
+		}
+		if sel.Rewrite != nil {
+			p := *sel.Rewrite
+			desc.Platform = &p
+		}
+		return desc, true
+	}
+	return desc, false
+}
+
+// selectManifests filters the manifests of an index source with its platform
+// selectors. Attestation manifests are kept if the manifest they refer to is.
+func (s *Source) selectManifests(descs []ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+	if len(s.Platforms) == 0 {
+		return descs, nil
+	}
+	var mfsts, atts []ocispec.Descriptor
+	for _, d := range descs {
+		if d.Annotations[annotationReferenceType] == attestationManifestType {
+			atts = append(atts, d)
+			continue
+		}
+		if d, ok := s.selectPlatform(d); ok {
+			mfsts = append(mfsts, d)
+		}
+	}
+	if len(mfsts) == 0 {
+		return nil, s.noPlatformError()
+	}
+	out := mfsts
+	for _, d := range atts {
+		if _, ok := attestationSubject(d, mfsts); ok {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+func (s *Source) noPlatformError() error {
+	name := s.Desc.Digest.String()
+	if s.Ref != nil {
+		name = s.Ref.String()
+	}
+	sels := make([]string, len(s.Platforms))
+	for i, sel := range s.Platforms {
+		sels[i] = platforms.Format(sel.Platform)
+	}
+	return errors.Errorf("no manifest of %s matches platforms %s", name, strings.Join(sels, ","))
 }
 
 func (r *Resolver) Combine(ctx context.Context, srcs []*Source, ann map[exptypes.AnnotationKey]string, preferIndex bool) ([]byte, ocispec.Descriptor, error) {
@@ -77,7 +176,7 @@ func (r *Resolver) Combine(ctx context.Context, srcs []*Source, ann map[exptypes
 	}
 
 	// on single source, return original bytes
-	if len(srcs) == 1 && len(ann) == 0 {
+	if len(srcs) == 1 && len(ann) == 0 && len(srcs[0].Platforms) == 0 {
 		switch srcs[0].Desc.MediaType {
 		// if the source is already an image index or manifest list, there is no need to consider the value
 		// of preferIndex since if set to true then the source is already in the preferred format, and if false
@@ -124,14 +223,26 @@ func (r *Resolver) Combine(ctx context.Context, srcs []*Source, ann map[exptypes
 			if err := json.Unmarshal(dts[i], &mfst); err != nil {
 				return nil, ocispec.Descriptor{}, errors.WithStack(err)
 			}
-			for _, d := range mfst.Manifests {
+			descs, err := src.selectManifests(mfst.Manifests)
+			if err != nil {
+				return nil, ocispec.Descriptor{}, err
+			}
+			for _, d := range descs {
 				addDesc(d)
 			}
 		default:
-			addDesc(src.Desc)
+			d, ok := src.selectPlatform(src.Desc)
+			if !ok {
+				return nil, ocispec.Descriptor{}, src.noPlatformError()
+			}
+			addDesc(d)
 		}
 	}
 
+	if err := validatePlatforms(newDescs); err != nil {
+		return nil, ocispec.Descriptor{}, err
+	}
+
 	dockerMfsts := 0
 	for _, desc := range newDescs {
 		if strings.HasPrefix(desc.MediaType, "application/vnd.docker.") {
@@ -191,10 +302,34 @@ func (r *Resolver) Combine(ctx context.Context, srcs []*Source, ann map[exptypes
 	}, nil
 }
 
+// validatePlatforms checks that no platform appears more than once in the
+// manifests of an index, as only one of them could ever be pulled.
+func validatePlatforms(descs []ocispec.Descriptor) error {
+	seen := map[string]digest.Digest{}
+	for _, d := range descs {
+		if d.Platform == nil || d.Annotations[annotationReferenceType] == attestationManifestType {
+			continue
+		}
+		p := platforms.Normalize(*d.Platform)
+		key := platforms.Format(p)
+		if p.OSVersion != "" {
+			key += ":" + p.OSVersion
+		}
+		if dgst, ok := seen[key]; ok {
+			return errors.Errorf("platform %s is provided by both %s and %s", platforms.Format(p), dgst, d.Digest)
+		}
+		seen[key] = d.Digest
+	}
+	return nil
+}
+
 func (r *Resolver) Push(ctx context.Context, ref reference.Named, desc ocispec.Descriptor, dt []byte) error {
+	return r.push(ctx, reference.TagNameOnly(ref), desc, dt)
+}
+
+func (r *Resolver) push(ctx context.Context, ref reference.Named, desc ocispec.Descriptor, dt []byte) error {
 	ctx = remotes.WithMediaTypeKeyPrefix(ctx, "application/vnd.in-toto+json", "intoto")
 
-	ref = reference.TagNameOnly(ref)
 	p, err := r.resolver().Pusher(ctx, ref.String())
 	if err != nil {
 		return err
@@ -215,9 +350,12 @@ func (r *Resolver) Push(ctx context.Context, ref reference.Named, desc ocispec.D
 }
 
 func (r *Resolver) Copy(ctx context.Context, src *Source, dest reference.Named) error {
+	return r.copy(ctx, src, reference.TagNameOnly(dest))
+}
+
+func (r *Resolver) copy(ctx context.Context, src *Source, dest reference.Named) error {
 	ctx = remotes.WithMediaTypeKeyPrefix(ctx, "application/vnd.in-toto+json", "intoto")
 
-	dest = reference.TagNameOnly(dest)
 	p, err := r.resolver().Pusher(ctx, dest.String())
 	if err != nil {
 		return err
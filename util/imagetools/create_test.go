@@ -0,0 +1,104 @@
+package imagetools
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/containerd/platforms"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCombinePlatforms(t *testing.T) {
+	reg := newTestRegistry(t)
+	r := New(Opt{})
+	ctx := context.TODO()
+
+	amd64 := pushPlatformImage(t, reg, "src/app", "linux/amd64")
+	arm64 := pushPlatformImage(t, reg, "src/app", "linux/arm64")
+	armv7 := pushPlatformImage(t, reg, "src/app", "linux/arm/v7")
+	idx := reg.pushIndex(t, "src/app", "latest", amd64, arm64, armv7)
+	single := reg.pushManifest(t, "src/other", "latest", ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    reg.pushBlob(t, ocispec.MediaTypeImageConfig, []byte(`{"architecture":"arm64","os":"linux"}`)),
+	})
+
+	combine := func(srcs ...*Source) (ocispec.Index, error) {
+		dt, _, err := r.Combine(ctx, srcs, nil, true)
+		if err != nil {
+			return ocispec.Index{}, err
+		}
+		var out ocispec.Index
+		require.NoError(t, json.Unmarshal(dt, &out))
+		return out, nil
+	}
+	selectors := func(in ...string) []PlatformSelector {
+		var out []PlatformSelector
+		for _, v := range in {
+			s, err := ParsePlatformSelector(v)
+			require.NoError(t, err)
+			out = append(out, s)
+		}
+		return out
+	}
+
+	out, err := combine(&Source{Ref: reg.ref(t, "src/app"), Desc: idx, Platforms: selectors("linux/amd64", "linux/arm/v7=linux/arm/v6")})
+	require.NoError(t, err)
+	require.Len(t, out.Manifests, 2)
+	require.Equal(t, amd64.Digest, out.Manifests[0].Digest)
+	require.Equal(t, armv7.Digest, out.Manifests[1].Digest)
+	require.Equal(t, "linux/arm/v6", platforms.Format(*out.Manifests[1].Platform))
+
+	out, err = combine(
+		&Source{Ref: reg.ref(t, "src/app"), Desc: idx, Platforms: selectors("linux/amd64")},
+		&Source{Ref: reg.ref(t, "src/other"), Desc: single, Platforms: selectors("linux/arm64=linux/arm64/v8")},
+	)
+	require.NoError(t, err)
+	require.Len(t, out.Manifests, 2)
+	require.Equal(t, single.Digest, out.Manifests[1].Digest)
+	require.Equal(t, "linux/arm64/v8", platforms.Format(*out.Manifests[1].Platform))
+
+	_, err = combine(
+		&Source{Ref: reg.ref(t, "src/app"), Desc: idx},
+		&Source{Ref: reg.ref(t, "src/other"), Desc: single},
+	)
+	require.ErrorContains(t, err, "platform linux/arm64 is provided by both")
+
+	_, err = combine(&Source{Ref: reg.ref(t, "src/other"), Desc: single, Platforms: selectors("linux/amd64")})
+	require.ErrorContains(t, err, "matches platforms linux/amd64")
+
+	_, err = combine(&Source{Ref: reg.ref(t, "src/app"), Desc: idx, Platforms: selectors("linux/s390x")})
+	require.ErrorContains(t, err, "matches platforms linux/s390x")
+}
+
+func TestParsePlatformSelector(t *testing.T) {
+	s, err := ParsePlatformSelector("linux/arm64")
+	require.NoError(t, err)
+	require.Nil(t, s.Rewrite)
+	require.Equal(t, "linux/arm64", s.String())
+
+	s, err = ParsePlatformSelector("linux/arm64=linux/arm64/v8")
+	require.NoError(t, err)
+	require.NotNil(t, s.Rewrite)
+	require.Equal(t, "v8", s.Rewrite.Variant)
+	require.Equal(t, "linux/arm64=linux/arm64/v8", s.String())
+
+	_, err = ParsePlatformSelector("linux/arm64=")
+	require.Error(t, err)
+}
+
+func pushPlatformImage(t *testing.T, reg *testRegistry, repo string, platform string) ocispec.Descriptor {
+	p := platforms.MustParse(platform)
+	config, err := json.Marshal(ocispec.Image{Platform: p})
+	require.NoError(t, err)
+	desc := reg.pushManifest(t, repo, "", ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    reg.pushBlob(t, ocispec.MediaTypeImageConfig, config),
+	})
+	desc.Platform = &p
+	return desc
+}
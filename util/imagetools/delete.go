@@ -0,0 +1,96 @@
+package imagetools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/remotes/docker"
+	"github.com/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// Delete deletes the manifest ref points to from the registry, along with
+// the manifests of an index if manifests is set. As manifests are deleted by
+// digest, all the tags pointing to them are removed too, and the manifests of
+// an index may break other indexes referring to them. It returns the
+// descriptors of the deleted manifests.
+func (r *Resolver) Delete(ctx context.Context, ref reference.Named, manifests bool) ([]ocispec.Descriptor, error) {
+	_, desc, err := r.Resolve(ctx, ref.String())
+	if err != nil {
+		return nil, err
+	}
+
+	descs := []ocispec.Descriptor{desc}
+	switch desc.MediaType {
+	case images.MediaTypeDockerSchema2ManifestList, ocispec.MediaTypeImageIndex:
+		if !manifests {
+			break
+		}
+		dt, err := r.GetDescriptor(ctx, ref.String(), desc)
+		if err != nil {
+			return nil, err
+		}
+		var idx ocispec.Index
+		if err := json.Unmarshal(dt, &idx); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		seen := map[digest.Digest]struct{}{desc.Digest: {}}
+		for _, d := range idx.Manifests {
+			if _, ok := seen[d.Digest]; ok {
+				continue
+			}
+			seen[d.Digest] = struct{}{}
+			descs = append(descs, d)
+		}
+	}
+
+	host, err := r.registryHost(ref)
+	if err != nil {
+		return nil, err
+	}
+	ctx = docker.WithScope(ctx, "repository:"+reference.Path(ref)+":delete")
+
+	// the index goes first so a failure never leaves it pointing to
+	// deleted manifests
+	var deleted []ocispec.Descriptor
+	for i, d := range descs {
+		ok, err := r.deleteManifest(ctx, host, ref, d.Digest)
+		if err != nil {
+			return deleted, err
+		}
+		if !ok {
+			if i == 0 {
+				return nil, errors.Errorf("manifest %s not found in %s", d.Digest, reference.TrimNamed(ref))
+			}
+			// already deleted, e.g. along with another index
+			continue
+		}
+		deleted = append(deleted, d)
+	}
+	return deleted, nil
+}
+
+// deleteManifest deletes a manifest with the registry API. It returns false
+// if the manifest does not exist.
+func (r *Resolver) deleteManifest(ctx context.Context, host docker.RegistryHost, ref reference.Named, dgst digest.Digest) (bool, error) {
+	resp, err := r.doRegistryRequest(ctx, host, http.MethodDelete, registryURL(host, ref, "/manifests/"+dgst.String()))
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusAccepted, http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	case http.StatusMethodNotAllowed:
+		return false, errors.Errorf("registry %s does not allow deleting manifests", reference.Domain(ref))
+	default:
+		return false, errors.Errorf("unexpected status deleting %s: %s", dgst, resp.Status)
+	}
+}
@@ -0,0 +1,56 @@
+package imagetools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelete(t *testing.T) {
+	reg := newTestRegistry(t)
+	r := New(Opt{})
+	ctx := context.TODO()
+
+	amd64 := pushPlatformImage(t, reg, "src/app", "linux/amd64")
+	arm64 := pushPlatformImage(t, reg, "src/app", "linux/arm64")
+	idx := reg.pushIndex(t, "src/app", "latest", amd64, arm64)
+	reg.pushIndex(t, "src/app", "v1", amd64, arm64)
+
+	ref := reg.ref(t, "src/app:v1")
+	descs, err := r.Delete(ctx, ref, false)
+	require.NoError(t, err)
+	require.Len(t, descs, 1)
+	require.Equal(t, idx.Digest, descs[0].Digest)
+	require.False(t, reg.hasManifest("src/app", "latest"))
+	require.False(t, reg.hasManifest("src/app", "v1"))
+	require.True(t, reg.hasManifest("src/app", amd64.Digest.String()))
+
+	_, err = r.Delete(ctx, ref, false)
+	require.Error(t, err)
+
+	idx = reg.pushIndex(t, "src/app", "latest", amd64, arm64)
+	descs, err = r.Delete(ctx, reg.ref(t, "src/app"), true)
+	require.NoError(t, err)
+	require.Len(t, descs, 3)
+	require.False(t, reg.hasManifest("src/app", idx.Digest.String()))
+	require.False(t, reg.hasManifest("src/app", amd64.Digest.String()))
+	require.False(t, reg.hasManifest("src/app", arm64.Digest.String()))
+}
+
+func TestDeleteManifest(t *testing.T) {
+	reg := newTestRegistry(t)
+	r := New(Opt{})
+	ctx := context.TODO()
+
+	amd64 := pushPlatformImage(t, reg, "src/app", "linux/amd64")
+	arm64 := pushPlatformImage(t, reg, "src/app", "linux/arm64")
+	reg.pushIndex(t, "src/app", "latest", amd64, arm64)
+
+	descs, err := r.Delete(ctx, reg.ref(t, "src/app@"+amd64.Digest.String()), false)
+	require.NoError(t, err)
+	require.Len(t, descs, 1)
+	require.Equal(t, amd64.Digest, descs[0].Digest)
+	require.False(t, reg.hasManifest("src/app", amd64.Digest.String()))
+	require.True(t, reg.hasManifest("src/app", arm64.Digest.String()))
+}
@@ -0,0 +1,426 @@
+package imagetools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"golang.org/x/sync/errgroup"
+)
+
+// Diff is the difference between two images.
+type Diff struct {
+	From      DiffImage      `json:"from"`
+	To        DiffImage      `json:"to"`
+	Platforms *StringsDiff   `json:"platforms,omitempty"`
+	Manifests []ManifestDiff `json:"manifests,omitempty"`
+	// Packages is the difference between the packages of the SBOMs of all
+	// the platforms, set when comparing SBOMs.
+	Packages *PackageDiff `json:"packages,omitempty"`
+}
+
+type DiffImage struct {
+	Name   string        `json:"name"`
+	Digest digest.Digest `json:"digest"`
+}
+
+// ManifestDiff is the difference between the images of a platform.
+type ManifestDiff struct {
+	Platform   string        `json:"platform"`
+	From       digest.Digest `json:"from"`
+	To         digest.Digest `json:"to"`
+	Env        *StringsDiff  `json:"env,omitempty"`
+	Entrypoint *ListChange   `json:"entrypoint,omitempty"`
+	Cmd        *ListChange   `json:"cmd,omitempty"`
+	User       *ValueChange  `json:"user,omitempty"`
+	WorkingDir *ValueChange  `json:"workingDir,omitempty"`
+	Labels     *MapDiff      `json:"labels,omitempty"`
+	Layers     *StringsDiff  `json:"layers,omitempty"`
+	Packages   *PackageDiff  `json:"packages,omitempty"`
+}
+
+type StringsDiff struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+type ValueChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type ListChange struct {
+	From []string `json:"from"`
+	To   []string `json:"to"`
+}
+
+type MapDiff struct {
+	Added   map[string]string      `json:"added,omitempty"`
+	Removed map[string]string      `json:"removed,omitempty"`
+	Changed map[string]ValueChange `json:"changed,omitempty"`
+}
+
+// PackageDiff is the difference between the packages of the SBOM attestations
+// of two images.
+type PackageDiff struct {
+	Added   []Package       `json:"added,omitempty"`
+	Removed []Package       `json:"removed,omitempty"`
+	Changed []PackageChange `json:"changed,omitempty"`
+}
+
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type PackageChange struct {
+	Name string `json:"name"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Diff compares the images from and to, from a registry or from a local OCI
+// layout or docker archive.
+func (r *Resolver) Diff(ctx context.Context, from, to string) (*Diff, error) {
+	var (
+		res   [2]*result
+		descs [2]ocispec.Descriptor
+	)
+	eg, ctx := errgroup.WithContext(ctx)
+	for i, name := range []string{from, to} {
+		i, name := i, name
+		eg.Go(func() error {
+			var err error
+			res[i], descs[i], err = r.loadImage(ctx, name)
+			return err
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	d := &Diff{
+		From: DiffImage{Name: from, Digest: descs[0].Digest},
+		To:   DiffImage{Name: to, Digest: descs[1].Digest},
+	}
+	if d.From.Digest == d.To.Digest {
+		return d, nil
+	}
+
+	d.Platforms = diffStrings(res[0].platforms, res[1].platforms)
+
+	fromConfigs, toConfigs := res[0].Configs(), res[1].Configs()
+	fromSBOMs, err := res[0].SBOM()
+	if err != nil {
+		return nil, err
+	}
+	toSBOMs, err := res[1].SBOM()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range res[0].platforms {
+		toDgst, ok := res[1].images[p]
+		if !ok {
+			continue
+		}
+		fromDgst := res[0].images[p]
+		if fromDgst == toDgst {
+			continue
+		}
+		md := ManifestDiff{
+			Platform: p,
+			From:     fromDgst,
+			To:       toDgst,
+			Layers:   diffStrings(layerDigests(res[0].manifests[fromDgst].manifest), layerDigests(res[1].manifests[toDgst].manifest)),
+		}
+		if fc, tc := fromConfigs[p], toConfigs[p]; fc != nil && tc != nil {
+			md.Env = diffStrings(fc.Config.Env, tc.Config.Env)
+			md.Entrypoint = diffList(fc.Config.Entrypoint, tc.Config.Entrypoint)
+			md.Cmd = diffList(fc.Config.Cmd, tc.Config.Cmd)
+			md.User = diffValue(fc.Config.User, tc.Config.User)
+			md.WorkingDir = diffValue(fc.Config.WorkingDir, tc.Config.WorkingDir)
+			md.Labels = diffMap(fc.Config.Labels, tc.Config.Labels)
+		}
+		if fs, ok := fromSBOMs[p]; ok {
+			if ts, ok := toSBOMs[p]; ok {
+				md.Packages = diffPackages(sbomPackages(fs), sbomPackages(ts))
+			}
+		}
+		d.Manifests = append(d.Manifests, md)
+	}
+	return d, nil
+}
+
+// loadImage loads the image name from a registry, or from a local OCI layout
+// or docker archive.
+func (r *Resolver) loadImage(ctx context.Context, name string) (*result, ocispec.Descriptor, error) {
+	if IsLocalRef(name) {
+		lr, err := newLocalResolver(ctx, name)
+		if err != nil {
+			return nil, ocispec.Descriptor{}, err
+		}
+		res, err := newLoader(lr).LoadDescriptor(ctx, name, lr.desc)
+		return res, lr.desc, err
+	}
+
+	ref, err := parseRef(name)
+	if err != nil {
+		return nil, ocispec.Descriptor{}, err
+	}
+	_, desc, err := r.Resolve(ctx, ref.String())
+	if err != nil {
+		return nil, ocispec.Descriptor{}, err
+	}
+	canonical, err := reference.WithDigest(ref, desc.Digest)
+	if err != nil {
+		return nil, ocispec.Descriptor{}, err
+	}
+	res, err := newLoader(r.resolver()).LoadDescriptor(ctx, canonical.String(), desc)
+	return res, desc, err
+}
+
+func layerDigests(mfst ocispec.Manifest) []string {
+	out := make([]string, len(mfst.Layers))
+	for i, l := range mfst.Layers {
+		out[i] = l.Digest.String()
+	}
+	return out
+}
+
+func diffStrings(from, to []string) *StringsDiff {
+	fromSet := make(map[string]struct{}, len(from))
+	for _, v := range from {
+		fromSet[v] = struct{}{}
+	}
+	toSet := make(map[string]struct{}, len(to))
+	for _, v := range to {
+		toSet[v] = struct{}{}
+	}
+	var d StringsDiff
+	for _, v := range from {
+		if _, ok := toSet[v]; !ok {
+			d.Removed = append(d.Removed, v)
+		}
+	}
+	for _, v := range to {
+		if _, ok := fromSet[v]; !ok {
+			d.Added = append(d.Added, v)
+		}
+	}
+	if len(d.Added) == 0 && len(d.Removed) == 0 {
+		return nil
+	}
+	return &d
+}
+
+func diffList(from, to []string) *ListChange {
+	if slices.Equal(from, to) {
+		return nil
+	}
+	return &ListChange{From: from, To: to}
+}
+
+func diffValue(from, to string) *ValueChange {
+	if from == to {
+		return nil
+	}
+	return &ValueChange{From: from, To: to}
+}
+
+func diffMap(from, to map[string]string) *MapDiff {
+	d := MapDiff{
+		Added:   map[string]string{},
+		Removed: map[string]string{},
+		Changed: map[string]ValueChange{},
+	}
+	for k, v := range from {
+		if v2, ok := to[k]; !ok {
+			d.Removed[k] = v
+		} else if v != v2 {
+			d.Changed[k] = ValueChange{From: v, To: v2}
+		}
+	}
+	for k, v := range to {
+		if _, ok := from[k]; !ok {
+			d.Added[k] = v
+		}
+	}
+	if len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 {
+		return nil
+	}
+	return &d
+}
+
+// sbomPackages returns the versions of the packages of the SPDX documents of
+// an SBOM attestation, by package name.
+func sbomPackages(sbom sbomStub) map[string]string {
+	return packageVersions(spdxPackages(sbom))
+}
+
+// packageVersions returns the versions of pkgs by package name, joined if a
+// package has several versions.
+func packageVersions(pkgs []SBOMPackage) map[string]string {
+	out := map[string]string{}
+	for _, p := range pkgs {
+		if v, ok := out[p.Name]; ok && v != p.Version && p.Version != "" {
+			// several versions of the same package
+			vs := append(strings.Split(v, ", "), p.Version)
+			sort.Strings(vs)
+			out[p.Name] = strings.Join(dedupeSorted(vs), ", ")
+			continue
+		}
+		out[p.Name] = p.Version
+	}
+	return out
+}
+
+func dedupeSorted(s []string) []string {
+	out := s[:0]
+	for i, v := range s {
+		if i == 0 || v != s[i-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func diffPackages(from, to map[string]string) *PackageDiff {
+	var d PackageDiff
+	for name, v := range from {
+		if v2, ok := to[name]; !ok {
+			d.Removed = append(d.Removed, Package{Name: name, Version: v})
+		} else if v != v2 {
+			d.Changed = append(d.Changed, PackageChange{Name: name, From: v, To: v2})
+		}
+	}
+	for name, v := range to {
+		if _, ok := from[name]; !ok {
+			d.Added = append(d.Added, Package{Name: name, Version: v})
+		}
+	}
+	if len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 {
+		return nil
+	}
+	sort.Slice(d.Added, func(i, j int) bool { return d.Added[i].Name < d.Added[j].Name })
+	sort.Slice(d.Removed, func(i, j int) bool { return d.Removed[i].Name < d.Removed[j].Name })
+	sort.Slice(d.Changed, func(i, j int) bool { return d.Changed[i].Name < d.Changed[j].Name })
+	return &d
+}
+
+// Print writes the differences in a human-readable form.
+func (d *Diff) Print(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(w, "From:\t%s\t%s\n", d.From.Name, d.From.Digest)
+	fmt.Fprintf(w, "To:\t%s\t%s\n", d.To.Name, d.To.Digest)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if d.Platforms == nil && len(d.Manifests) == 0 && d.Packages == nil {
+		if d.From.Digest == d.To.Digest {
+			_, err := fmt.Fprintf(out, "\nImages are identical\n")
+			return err
+		}
+		_, err := fmt.Fprintf(out, "\nNo differences in platforms, configs, layers or packages\n")
+		return err
+	}
+
+	if d.Platforms != nil {
+		fmt.Fprintf(out, "\nPlatforms:\n")
+		printStringsDiff(out, defaultPfx, d.Platforms)
+	}
+	if d.Packages != nil {
+		fmt.Fprintf(out, "\nPackages:\n")
+		printPackageDiff(out, defaultPfx, d.Packages)
+	}
+
+	for _, md := range d.Manifests {
+		fmt.Fprintf(out, "\n%s:\n", md.Platform)
+		fmt.Fprintf(out, "%sDigest: %s -> %s\n", defaultPfx, md.From, md.To)
+		if md.Env != nil {
+			fmt.Fprintf(out, "%sEnv:\n", defaultPfx)
+			printStringsDiff(out, defaultPfx+defaultPfx, md.Env)
+		}
+		if md.Entrypoint != nil {
+			fmt.Fprintf(out, "%sEntrypoint: %s -> %s\n", defaultPfx, formatList(md.Entrypoint.From), formatList(md.Entrypoint.To))
+		}
+		if md.Cmd != nil {
+			fmt.Fprintf(out, "%sCmd: %s -> %s\n", defaultPfx, formatList(md.Cmd.From), formatList(md.Cmd.To))
+		}
+		if md.User != nil {
+			fmt.Fprintf(out, "%sUser: %q -> %q\n", defaultPfx, md.User.From, md.User.To)
+		}
+		if md.WorkingDir != nil {
+			fmt.Fprintf(out, "%sWorkingDir: %q -> %q\n", defaultPfx, md.WorkingDir.From, md.WorkingDir.To)
+		}
+		if md.Labels != nil {
+			fmt.Fprintf(out, "%sLabels:\n", defaultPfx)
+			printMapDiff(out, defaultPfx+defaultPfx, md.Labels)
+		}
+		if md.Layers != nil {
+			fmt.Fprintf(out, "%sLayers:\n", defaultPfx)
+			printStringsDiff(out, defaultPfx+defaultPfx, md.Layers)
+		}
+		if md.Packages != nil {
+			fmt.Fprintf(out, "%sPackages:\n", defaultPfx)
+			printPackageDiff(out, defaultPfx+defaultPfx, md.Packages)
+		}
+	}
+	return nil
+}
+
+func printStringsDiff(out io.Writer, pfx string, d *StringsDiff) {
+	for _, v := range d.Added {
+		fmt.Fprintf(out, "%s+ %s\n", pfx, v)
+	}
+	for _, v := range d.Removed {
+		fmt.Fprintf(out, "%s- %s\n", pfx, v)
+	}
+}
+
+func printPackageDiff(out io.Writer, pfx string, d *PackageDiff) {
+	for _, p := range d.Added {
+		fmt.Fprintf(out, "%s+ %s %s\n", pfx, p.Name, p.Version)
+	}
+	for _, p := range d.Removed {
+		fmt.Fprintf(out, "%s- %s %s\n", pfx, p.Name, p.Version)
+	}
+	for _, p := range d.Changed {
+		fmt.Fprintf(out, "%s~ %s %s -> %s\n", pfx, p.Name, p.From, p.To)
+	}
+}
+
+func printMapDiff(out io.Writer, pfx string, d *MapDiff) {
+	for _, k := range sortedKeys(d.Added) {
+		fmt.Fprintf(out, "%s+ %s=%s\n", pfx, k, d.Added[k])
+	}
+	for _, k := range sortedKeys(d.Removed) {
+		fmt.Fprintf(out, "%s- %s=%s\n", pfx, k, d.Removed[k])
+	}
+	for _, k := range sortedKeys(d.Changed) {
+		fmt.Fprintf(out, "%s~ %s: %s -> %s\n", pfx, k, d.Changed[k].From, d.Changed[k].To)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatList(l []string) string {
+	dt, _ := json.Marshal(l)
+	return string(dt)
+}
@@ -0,0 +1,80 @@
+package imagetools
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	from, to := t.TempDir(), t.TempDir()
+	writeTestLayoutWith(t, from,
+		`{"architecture":"amd64","os":"linux","config":{"Env":["PATH=/bin","A=1"],"Entrypoint":["/app"],"Labels":{"version":"1","old":"x"}},"rootfs":{"type":"layers","diff_ids":[]}}`,
+		`{"packages":[{"name":"openssl","versionInfo":"3.1.4"},{"name":"zlib","versionInfo":"1.3"}]}`,
+		"base", "app-v1",
+	)
+	writeTestLayoutWith(t, to,
+		`{"architecture":"amd64","os":"linux","config":{"Env":["PATH=/bin","A=2"],"Entrypoint":["/app","serve"],"Labels":{"version":"2","new":"y"}},"rootfs":{"type":"layers","diff_ids":[]}}`,
+		`{"packages":[{"name":"openssl","versionInfo":"3.1.5"},{"name":"curl","versionInfo":"8.5.0"}]}`,
+		"base", "app-v2",
+	)
+
+	d, err := New(Opt{}).Diff(context.TODO(), "oci-layout://"+from, "oci-layout://"+to)
+	require.NoError(t, err)
+	require.Nil(t, d.Platforms)
+	require.Len(t, d.Manifests, 1)
+
+	md := d.Manifests[0]
+	require.Equal(t, "linux/amd64", md.Platform)
+	require.Equal(t, &StringsDiff{Added: []string{"A=2"}, Removed: []string{"A=1"}}, md.Env)
+	require.Equal(t, &ListChange{From: []string{"/app"}, To: []string{"/app", "serve"}}, md.Entrypoint)
+	require.Nil(t, md.Cmd)
+	require.Equal(t, &MapDiff{
+		Added:   map[string]string{"new": "y"},
+		Removed: map[string]string{"old": "x"},
+		Changed: map[string]ValueChange{"version": {From: "1", To: "2"}},
+	}, md.Labels)
+	require.NotNil(t, md.Layers)
+	require.Len(t, md.Layers.Added, 1)
+	require.Len(t, md.Layers.Removed, 1)
+	require.Equal(t, &PackageDiff{
+		Added:   []Package{{Name: "curl", Version: "8.5.0"}},
+		Removed: []Package{{Name: "zlib", Version: "1.3"}},
+		Changed: []PackageChange{{Name: "openssl", From: "3.1.4", To: "3.1.5"}},
+	}, md.Packages)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, d.Print(buf))
+	out := buf.String()
+	require.Contains(t, out, "linux/amd64:\n")
+	require.Contains(t, out, "    + A=2\n    - A=1\n")
+	require.Contains(t, out, `  Entrypoint: ["/app"] -> ["/app","serve"]`)
+	require.Contains(t, out, "    ~ version: 1 -> 2\n")
+	require.Contains(t, out, "    ~ openssl 3.1.4 -> 3.1.5\n")
+}
+
+func TestDiffPlatforms(t *testing.T) {
+	from, to := t.TempDir(), t.TempDir()
+	writeTestLayout(t, from)
+	writeTestLayoutWith(t, to, `{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`, `{}`)
+
+	d, err := New(Opt{}).Diff(context.TODO(), "oci-layout://"+from, "oci-layout://"+to)
+	require.NoError(t, err)
+	require.Equal(t, &StringsDiff{Added: []string{"linux/amd64"}, Removed: []string{"linux/arm64"}}, d.Platforms)
+	require.Empty(t, d.Manifests)
+}
+
+func TestDiffIdentical(t *testing.T) {
+	dir := t.TempDir()
+	writeTestLayout(t, dir)
+
+	d, err := New(Opt{}).Diff(context.TODO(), "oci-layout://"+dir, "oci-layout://"+dir+":v1")
+	require.NoError(t, err)
+	require.Equal(t, d.From.Digest, d.To.Digest)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, d.Print(buf))
+	require.Contains(t, buf.String(), "Images are identical")
+}
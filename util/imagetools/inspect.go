@@ -52,7 +52,9 @@ func (r *Resolver) resolver() remotes.Resolver {
 			}
 			for i := range res {
 				res[i].Authorizer = r.auth
-				res[i].Client = tracing.DefaultClient
+				if res[i].Client == nil {
+					res[i].Client = tracing.DefaultClient
+				}
 			}
 			return res, nil
 		},
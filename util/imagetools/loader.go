@@ -100,7 +100,12 @@ func (l *loader) Load(ctx context.Context, ref string) (*result, error) {
 		return nil, err
 	}
 
-	fetcher, err := l.resolver.Fetcher(ctx, canonical.String())
+	return l.LoadDescriptor(ctx, canonical.String(), desc)
+}
+
+// LoadDescriptor loads the image desc already resolved from ref.
+func (l *loader) LoadDescriptor(ctx context.Context, ref string, desc ocispec.Descriptor) (*result, error) {
+	fetcher, err := l.resolver.Fetcher(ctx, ref)
 	if err != nil {
 		return nil, err
 	}
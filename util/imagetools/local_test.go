@@ -0,0 +1,235 @@
+package imagetools
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containerd/containerd/images"
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspectOCILayout(t *testing.T) {
+	dir := t.TempDir()
+	idx := writeTestLayout(t, dir)
+
+	out := printLocal(t, "oci-layout://"+dir, "")
+	require.Contains(t, out, "Name:      oci-layout://"+dir+"\n")
+	require.Contains(t, out, "Digest:    "+idx.Digest.String())
+	require.Contains(t, out, "oci-layout://"+dir+"@"+idx.Digest.Algorithm().String()+":")
+	require.Contains(t, out, "linux/arm64")
+
+	out = printLocal(t, "oci-layout://"+dir+":v1", "{{json .Image}}")
+	var img ocispec.Image
+	require.NoError(t, json.Unmarshal([]byte(out), &img))
+	require.Equal(t, "arm64", img.Architecture)
+
+	out = printLocal(t, "oci-layout://"+dir, `{{json .SBOM}}`)
+	require.Contains(t, out, `"name": "test-sbom"`)
+
+	_, err := NewPrinter(context.TODO(), Opt{}, "oci-layout://"+dir+":v2", "")
+	require.ErrorContains(t, err, `image "v2" not found`)
+}
+
+func TestInspectDockerArchive(t *testing.T) {
+	dir := t.TempDir()
+	idx := writeTestLayout(t, dir)
+
+	archive := filepath.Join(t.TempDir(), "image.tar")
+	writeTestTar(t, archive, dir)
+
+	out := printLocal(t, "docker-archive://"+archive, "")
+	require.Contains(t, out, "Digest:    "+idx.Digest.String())
+
+	out = printLocal(t, "docker-archive://"+archive, `{{json .SBOM}}`)
+	require.Contains(t, out, `"name": "test-sbom"`)
+
+	out = printLocal(t, "docker-archive://"+archive+"@"+idx.Digest.String(), "")
+	require.Contains(t, out, "Digest:    "+idx.Digest.String())
+}
+
+func TestInspectLegacyDockerArchive(t *testing.T) {
+	dir := t.TempDir()
+	config := []byte(`{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":["` + digest.FromString("layer").String() + `"]}}`)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), config, 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "abc"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "abc", "layer.tar"), []byte("layer"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "manifest.json"), []byte(`[{"Config":"config.json","RepoTags":["foo:latest","foo:v1"],"Layers":["abc/layer.tar"]}]`), 0644))
+
+	archive := filepath.Join(t.TempDir(), "image.tar")
+	writeTestTar(t, archive, dir)
+
+	out := printLocal(t, "docker-archive://"+archive+":foo:v1", "{{json .Image}}")
+	var img ocispec.Image
+	require.NoError(t, json.Unmarshal([]byte(out), &img))
+	require.Equal(t, "amd64", img.Architecture)
+
+	out = printLocal(t, "docker-archive://"+archive+":docker.io/library/foo", "")
+	require.Contains(t, out, "MediaType: "+images.MediaTypeDockerSchema2Manifest)
+
+	out = printLocal(t, "docker-archive://"+archive, "{{json .Image}}")
+	require.NoError(t, json.Unmarshal([]byte(out), &img))
+	require.Equal(t, "amd64", img.Architecture)
+
+	_, err := NewPrinter(context.TODO(), Opt{}, "docker-archive://"+archive+":bar", "")
+	require.ErrorContains(t, err, `image "bar" not found`)
+}
+
+func printLocal(t *testing.T, name, format string) string {
+	p, err := NewPrinter(context.TODO(), Opt{}, name, format)
+	require.NoError(t, err)
+	buf := &bytes.Buffer{}
+	require.NoError(t, p.Print(false, buf))
+	return buf.String()
+}
+
+// writeTestLayout writes an OCI layout of an image with an SBOM attestation,
+// like the one exported with the oci exporter.
+func writeTestLayout(t *testing.T, dir string) ocispec.Descriptor {
+	return writeTestLayoutWith(t, dir, `{"architecture":"arm64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`, `{"name":"test-sbom"}`)
+}
+
+func writeTestLayoutWith(t *testing.T, dir string, config string, spdx string, layers ...string) ocispec.Descriptor {
+	writeBlob := func(mt string, dt []byte) ocispec.Descriptor {
+		dgst := digest.FromBytes(dt)
+		p := filepath.Join(dir, "blobs", dgst.Algorithm().String(), dgst.Encoded())
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, dt, 0644))
+		return ocispec.Descriptor{MediaType: mt, Digest: dgst, Size: int64(len(dt))}
+	}
+	writeJSON := func(mt string, v interface{}) ocispec.Descriptor {
+		dt, err := json.Marshal(v)
+		require.NoError(t, err)
+		return writeBlob(mt, dt)
+	}
+
+	layerDescs := []ocispec.Descriptor{}
+	for _, l := range layers {
+		layerDescs = append(layerDescs, writeBlob(ocispec.MediaTypeImageLayerGzip, []byte(l)))
+	}
+
+	img := writeJSON(ocispec.MediaTypeImageManifest, ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    writeBlob(ocispec.MediaTypeImageConfig, []byte(config)),
+		Layers:    layerDescs,
+	})
+	var p ocispec.Platform
+	require.NoError(t, json.Unmarshal([]byte(config), &p))
+	img.Platform = &p
+
+	sbom := writeBlob(inTotoGenericMime, []byte(`{"_type":"https://in-toto.io/Statement/v0.1","predicateType":"https://spdx.dev/Document","predicate":`+spdx+`}`))
+	sbom.Annotations = map[string]string{
+		"in-toto.io/predicate-type": intoto.PredicateSPDX,
+	}
+	att := writeJSON(ocispec.MediaTypeImageManifest, ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    writeBlob(ocispec.MediaTypeImageConfig, []byte(`{"architecture":"unknown","os":"unknown","rootfs":{"type":"layers","diff_ids":[]}}`)),
+		Layers:    []ocispec.Descriptor{sbom},
+	})
+	att.Platform = &ocispec.Platform{OS: "unknown", Architecture: "unknown"}
+	att.Annotations = map[string]string{
+		annotationReferenceType:       attestationManifestType,
+		"vnd.docker.reference.digest": img.Digest.String(),
+	}
+
+	idx := writeJSON(ocispec.MediaTypeImageIndex, ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{img, att},
+	})
+
+	root := idx
+	root.Annotations = map[string]string{
+		ocispec.AnnotationRefName: "v1",
+	}
+	dt, err := json.Marshal(ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{root},
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ocispec.ImageIndexFile), dt, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ocispec.ImageLayoutFile), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0644))
+	return idx
+}
+
+func writeTestTar(t *testing.T, archive, dir string) {
+	f, err := os.Create(archive)
+	require.NoError(t, err)
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	err = filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if fi.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+		dt, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(dt)
+		return err
+	})
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+}
+
+func TestParseLocalRef(t *testing.T) {
+	dgst := digest.FromString("image")
+	dir := filepath.Join(t.TempDir(), "build:1")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "layout"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "image.tar"), nil, 0644))
+	for _, tt := range []struct {
+		in   string
+		path string
+		tag  string
+		dgst digest.Digest
+	}{
+		{in: "layout", path: "layout"},
+		{in: "layout:v1", path: "layout", tag: "v1"},
+		{in: "layout@" + dgst.String(), path: "layout", dgst: dgst},
+		{in: "layout:v1@" + dgst.String(), path: "layout", tag: "v1", dgst: dgst},
+		{in: "/tmp/build:1/layout:v1", path: "/tmp/build:1/layout", tag: "v1"},
+		{in: "/tmp/user@host/layout", path: "/tmp/user@host/layout"},
+		{in: "/tmp/user@host/layout@" + dgst.String(), path: "/tmp/user@host/layout", dgst: dgst},
+		{in: dir + "/layout", path: dir + "/layout"},
+		{in: dir + "/layout:v1", path: dir + "/layout", tag: "v1"},
+		{in: dir + "/image.tar:docker.io/library/foo:v1", path: dir + "/image.tar", tag: "docker.io/library/foo:v1"},
+		{in: dir + "/image.tar:foo:v1@" + dgst.String(), path: dir + "/image.tar", tag: "foo:v1", dgst: dgst},
+		{in: `C:\images\layout`, path: `C:\images\layout`},
+		{in: `C:\images\layout:v1`, path: `C:\images\layout`, tag: "v1"},
+	} {
+		p, tag, d := parseLocalRef(tt.in)
+		require.Equal(t, tt.path, p, tt.in)
+		require.Equal(t, tt.tag, tag, tt.in)
+		require.Equal(t, tt.dgst, d, tt.in)
+	}
+}
@@ -2,15 +2,16 @@ package imagetools
 
 import (
 	"context"
+	"crypto"
 	"encoding/json"
 	"fmt"
 	"io"
-	"os"
 	"strings"
 	"text/tabwriter"
 	"text/template"
 
 	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/remotes"
 	"github.com/containerd/platforms"
 	"github.com/distribution/reference"
 	"github.com/opencontainers/go-digest"
@@ -21,18 +22,28 @@ const defaultPfx = "  "
 
 type Printer struct {
 	ctx      context.Context
-	resolver *Resolver
+	resolver remotes.Resolver
+	registry *Resolver // nil for local images
+
+	verifyKey crypto.PublicKey
 
 	name   string
 	format string
 
 	raw      []byte
-	ref      reference.Named
+	ref      reference.Named // nil for local images
 	manifest ocispecs.Descriptor
 	index    ocispecs.Index
 }
 
+// NewPrinter returns a printer for the image name in a registry, or in a local
+// OCI layout or docker archive if name starts with oci-layout:// or
+// docker-archive://.
 func NewPrinter(ctx context.Context, opt Opt, name string, format string) (*Printer, error) {
+	if IsLocalRef(name) {
+		return newLocalPrinter(ctx, name, format)
+	}
+
 	resolver := New(opt)
 
 	ref, err := parseRef(name)
@@ -52,7 +63,8 @@ func NewPrinter(ctx context.Context, opt Opt, name string, format string) (*Prin
 
 	return &Printer{
 		ctx:      ctx,
-		resolver: resolver,
+		resolver: resolver.resolver(),
+		registry: resolver,
 		name:     name,
 		format:   format,
 		raw:      dt,
@@ -62,6 +74,73 @@ func NewPrinter(ctx context.Context, opt Opt, name string, format string) (*Prin
 	}, nil
 }
 
+func newLocalPrinter(ctx context.Context, name string, format string) (*Printer, error) {
+	resolver, err := newLocalResolver(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, err := resolver.Fetch(ctx, resolver.desc)
+	if err != nil {
+		return nil, err
+	}
+	dt, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var idx ocispecs.Index
+	if err = json.Unmarshal(dt, &idx); err != nil {
+		return nil, err
+	}
+
+	return &Printer{
+		ctx:      ctx,
+		resolver: resolver,
+		name:     name,
+		format:   format,
+		raw:      dt,
+		manifest: resolver.desc,
+		index:    idx,
+	}, nil
+}
+
+// SetVerifyKey sets the public key to verify the signatures of the image
+// with.
+func (p *Printer) SetVerifyKey(key crypto.PublicKey) {
+	p.verifyKey = key
+}
+
+// signatures returns the cosign signatures of the image. Local images have
+// no signatures.
+func (p *Printer) signatures() ([]Signature, error) {
+	if p.registry == nil {
+		return nil, nil
+	}
+	return p.registry.Signatures(p.ctx, p.ref, p.manifest.Digest, p.verifyKey)
+}
+
+// refName returns the name of the printed image.
+func (p *Printer) refName() string {
+	if p.ref == nil {
+		return p.name
+	}
+	return p.ref.String()
+}
+
+// manifestName returns the name of a manifest of the printed index.
+func (p *Printer) manifestName(dgst digest.Digest) (string, error) {
+	if p.ref == nil {
+		return localManifestName(p.name, dgst), nil
+	}
+	cr, err := reference.WithDigest(p.ref, dgst)
+	if err != nil {
+		return "", err
+	}
+	return cr.String(), nil
+}
+
 func (p *Printer) Print(raw bool, out io.Writer) error {
 	if raw {
 		_, err := fmt.Fprintf(out, "%s", p.raw) // avoid newline to keep digest
@@ -70,10 +149,13 @@ func (p *Printer) Print(raw bool, out io.Writer) error {
 
 	if p.format == "" {
 		w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
-		_, _ = fmt.Fprintf(w, "Name:\t%s\n", p.ref.String())
+		_, _ = fmt.Fprintf(w, "Name:\t%s\n", p.refName())
 		_, _ = fmt.Fprintf(w, "MediaType:\t%s\n", p.manifest.MediaType)
 		_, _ = fmt.Fprintf(w, "Digest:\t%s\n", p.manifest.Digest)
 		_ = w.Flush()
+		if err := p.printSignatures(out); err != nil {
+			return err
+		}
 		switch p.manifest.MediaType {
 		case images.MediaTypeDockerSchema2ManifestList, ocispecs.MediaTypeImageIndex:
 			if err := p.printManifestList(out); err != nil {
@@ -83,7 +165,7 @@ func (p *Printer) Print(raw bool, out io.Writer) error {
 		return nil
 	}
 
-	res, err := newLoader(p.resolver.resolver()).Load(p.ctx, p.name)
+	res, err := newLoader(p.resolver).LoadDescriptor(p.ctx, p.refName(), p.manifest)
 	if err != nil {
 		return err
 	}
@@ -127,7 +209,7 @@ func (p *Printer) Print(raw bool, out io.Writer) error {
 	// TODO: print formatted config
 	case strings.HasPrefix(format, "{{.Manifest"):
 		w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
-		_, _ = fmt.Fprintf(w, "Name:\t%s\n", p.ref.String())
+		_, _ = fmt.Fprintf(w, "Name:\t%s\n", p.refName())
 		switch {
 		case strings.HasPrefix(format, "{{.Manifest"):
 			_, _ = fmt.Fprintf(w, "MediaType:\t%s\n", p.manifest.MediaType)
@@ -145,6 +227,7 @@ func (p *Printer) Print(raw bool, out io.Writer) error {
 				Manifest: mfst,
 				Image:    imageconfigs,
 				result:   res,
+				printer:  p,
 			})
 		}
 		var ic *ocispecs.Image
@@ -156,28 +239,63 @@ func (p *Printer) Print(raw bool, out io.Writer) error {
 			Manifest: mfst,
 			Image:    ic,
 			result:   res,
+			printer:  p,
 		})
 	}
 
 	return nil
 }
 
+// printSignatures prints the signatures of the image when they have to be
+// verified, to avoid looking them up on every inspect.
+func (p *Printer) printSignatures(out io.Writer) error {
+	if p.verifyKey == nil {
+		return nil
+	}
+	sigs, err := p.signatures()
+	if err != nil {
+		return err
+	}
+	if len(sigs) == 0 {
+		_, _ = fmt.Fprintf(out, "\nSignatures: none\n")
+		return nil
+	}
+
+	_, _ = fmt.Fprintf(out, "\nSignatures:\n")
+	w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
+	for i, sig := range sigs {
+		if i != 0 {
+			_, _ = fmt.Fprintf(w, "\t\n")
+		}
+		_, _ = fmt.Fprintf(w, "%sDigest:\t%s\n", defaultPfx, sig.Digest)
+		status := string(sig.Status)
+		if sig.Error != "" {
+			status += " (" + sig.Error + ")"
+		}
+		_, _ = fmt.Fprintf(w, "%sStatus:\t%s\n", defaultPfx, status)
+		if sig.Identity != "" {
+			_, _ = fmt.Fprintf(w, "%sIdentity:\t%s\n", defaultPfx, sig.Identity)
+		}
+	}
+	return w.Flush()
+}
+
 func (p *Printer) printManifestList(out io.Writer) error {
 	w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
 	_, _ = fmt.Fprintf(w, "\t\n")
 	_, _ = fmt.Fprintf(w, "Manifests:\t\n")
 	_ = w.Flush()
 
-	w = tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	w = tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
 	for i, m := range p.index.Manifests {
 		if i != 0 {
 			_, _ = fmt.Fprintf(w, "\t\n")
 		}
-		cr, err := reference.WithDigest(p.ref, m.Digest)
+		name, err := p.manifestName(m.Digest)
 		if err != nil {
 			return err
 		}
-		_, _ = fmt.Fprintf(w, "%sName:\t%s\n", defaultPfx, cr.String())
+		_, _ = fmt.Fprintf(w, "%sName:\t%s\n", defaultPfx, name)
 		_, _ = fmt.Fprintf(w, "%sMediaType:\t%s\n", defaultPfx, m.MediaType)
 		if p := m.Platform; p != nil {
 			_, _ = fmt.Fprintf(w, "%sPlatform:\t%s\n", defaultPfx, platforms.Format(*p))
@@ -193,7 +311,7 @@ func (p *Printer) printManifestList(out io.Writer) error {
 			if len(m.Annotations) > 0 {
 				_, _ = fmt.Fprintf(w, "%sAnnotations:\t\n", defaultPfx)
 				_ = w.Flush()
-				w2 := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+				w2 := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
 				for k, v := range m.Annotations {
 					_, _ = fmt.Fprintf(w2, "%s%s:\t%s\n", defaultPfx+defaultPfx, k, v)
 				}
@@ -209,7 +327,12 @@ type tplInput struct {
 	Manifest interface{}     `json:"manifest,omitempty"`
 	Image    *ocispecs.Image `json:"image,omitempty"`
 
-	result *result
+	result  *result
+	printer *Printer
+}
+
+func (inp tplInput) Signatures() ([]Signature, error) {
+	return inp.printer.signatures()
 }
 
 func (inp tplInput) SBOM() (sbomStub, error) {
@@ -239,7 +362,12 @@ type tplInputs struct {
 	Manifest interface{}                `json:"manifest,omitempty"`
 	Image    map[string]*ocispecs.Image `json:"image,omitempty"`
 
-	result *result
+	result  *result
+	printer *Printer
+}
+
+func (inp tplInputs) Signatures() ([]Signature, error) {
+	return inp.printer.signatures()
 }
 
 func (inp tplInputs) SBOM() (map[string]sbomStub, error) {
@@ -0,0 +1,138 @@
+package imagetools
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// ImageOrigin is the origin of the image of a platform, as recorded by its
+// provenance attestation and config.
+type ImageOrigin struct {
+	Platform string `json:"platform"`
+	// Provenance is false if the image has no provenance attestation, in
+	// which case only Created is set.
+	Provenance bool       `json:"provenance"`
+	BuilderID  string     `json:"builderId,omitempty"`
+	Sources    []string   `json:"sources,omitempty"`
+	Created    *time.Time `json:"created,omitempty"`
+}
+
+// slsaPredicate holds the fields of the v0.2 and v1 SLSA provenance
+// predicates describing the origin of an image.
+type slsaPredicate struct {
+	// v0.2
+	Builder struct {
+		ID string `json:"id"`
+	} `json:"builder"`
+	Invocation struct {
+		ConfigSource struct {
+			URI string `json:"uri"`
+		} `json:"configSource"`
+	} `json:"invocation"`
+	Metadata struct {
+		BuildFinishedOn *time.Time `json:"buildFinishedOn"`
+		BuildKit        struct {
+			VCS map[string]string `json:"vcs"`
+		} `json:"https://mobyproject.org/buildkit@v1#metadata"`
+	} `json:"metadata"`
+
+	// v1
+	BuildDefinition struct {
+		ExternalParameters struct {
+			ConfigSource struct {
+				URI string `json:"uri"`
+			} `json:"configSource"`
+		} `json:"externalParameters"`
+	} `json:"buildDefinition"`
+	RunDetails struct {
+		Builder struct {
+			ID string `json:"id"`
+		} `json:"builder"`
+		Metadata struct {
+			FinishedOn *time.Time `json:"finishedOn"`
+			BuildKit   struct {
+				VCS map[string]string `json:"vcs"`
+			} `json:"buildkit_metadata"`
+		} `json:"metadata"`
+	} `json:"runDetails"`
+}
+
+// Origins resolves the image name and returns its descriptor and the origin
+// of the image of each platform, sorted by platform.
+func (r *Resolver) Origins(ctx context.Context, name string) (ocispec.Descriptor, []ImageOrigin, error) {
+	ctx = withDiscardLogger(ctx)
+
+	res, desc, err := r.loadImage(ctx, name)
+	if err != nil {
+		return ocispec.Descriptor{}, nil, err
+	}
+	provenance, err := res.Provenance()
+	if err != nil {
+		return ocispec.Descriptor{}, nil, err
+	}
+	configs := res.Configs()
+
+	origins := make([]ImageOrigin, 0, len(res.platforms))
+	for _, p := range res.platforms {
+		o := ImageOrigin{Platform: p}
+		if c, ok := configs[p]; ok {
+			o.Created = c.Created
+		}
+		if prv, ok := provenance[p]; ok {
+			if err := o.setProvenance(prv.SLSA); err != nil {
+				return ocispec.Descriptor{}, nil, errors.Wrapf(err, "invalid provenance of %s for %s", name, p)
+			}
+		}
+		origins = append(origins, o)
+	}
+	return desc, origins, nil
+}
+
+func (o *ImageOrigin) setProvenance(v interface{}) error {
+	dt, err := json.Marshal(v)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	var pred slsaPredicate
+	if err := json.Unmarshal(dt, &pred); err != nil {
+		return errors.WithStack(err)
+	}
+
+	o.Provenance = true
+	o.BuilderID = pred.Builder.ID
+	if pred.RunDetails.Builder.ID != "" {
+		o.BuilderID = pred.RunDetails.Builder.ID
+	}
+
+	sources := map[string]struct{}{}
+	for _, s := range []string{
+		pred.Invocation.ConfigSource.URI,
+		pred.BuildDefinition.ExternalParameters.ConfigSource.URI,
+		pred.Metadata.BuildKit.VCS["source"],
+		pred.RunDetails.Metadata.BuildKit.VCS["source"],
+	} {
+		if s != "" {
+			sources[s] = struct{}{}
+		}
+	}
+	for s := range sources {
+		o.Sources = append(o.Sources, s)
+	}
+	sort.Strings(o.Sources)
+
+	// the config creation time can be reset for reproducible builds, the
+	// build time is a better hint of the age of the image
+	finished := pred.Metadata.BuildFinishedOn
+	if pred.RunDetails.Metadata.FinishedOn != nil {
+		finished = pred.RunDetails.Metadata.FinishedOn
+	}
+	if finished != nil && (o.Created == nil || finished.After(*o.Created)) {
+		o.Created = finished
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package imagetools
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageOriginProvenance(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tcs := []struct {
+		name      string
+		predicate string
+		builderID string
+		sources   []string
+		created   time.Time
+	}{
+		{
+			name: "v0.2",
+			predicate: `{
+				"builder": {"id": "https://github.com/actions/runner"},
+				"invocation": {"configSource": {"uri": "https://github.com/docker/buildx.git#refs/heads/master"}},
+				"metadata": {
+					"buildFinishedOn": "2024-02-01T00:00:00Z",
+					"https://mobyproject.org/buildkit@v1#metadata": {"vcs": {"source": "https://github.com/docker/buildx.git"}}
+				}
+			}`,
+			builderID: "https://github.com/actions/runner",
+			sources:   []string{"https://github.com/docker/buildx.git", "https://github.com/docker/buildx.git#refs/heads/master"},
+			created:   time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "v1",
+			predicate: `{
+				"buildDefinition": {"externalParameters": {"configSource": {"uri": "https://github.com/docker/buildx.git"}}},
+				"runDetails": {
+					"builder": {"id": "https://github.com/actions/runner"},
+					"metadata": {"finishedOn": "2023-12-01T00:00:00Z"}
+				}
+			}`,
+			builderID: "https://github.com/actions/runner",
+			sources:   []string{"https://github.com/docker/buildx.git"},
+			created:   created,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			var pred interface{}
+			require.NoError(t, json.Unmarshal([]byte(tc.predicate), &pred))
+
+			o := ImageOrigin{Platform: "linux/amd64", Created: &created}
+			require.NoError(t, o.setProvenance(pred))
+			require.True(t, o.Provenance)
+			require.Equal(t, tc.builderID, o.BuilderID)
+			require.Equal(t, tc.sources, o.Sources)
+			require.True(t, tc.created.Equal(*o.Created))
+		})
+	}
+}
@@ -0,0 +1,509 @@
+package imagetools
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/remotes/docker"
+	"github.com/containerd/errdefs"
+	"github.com/containerd/log"
+	"github.com/distribution/reference"
+	"github.com/moby/buildkit/util/tracing"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	annotationReferenceType = "vnd.docker.reference.type"
+	attestationManifestType = "attestation-manifest"
+)
+
+// cosignTagSuffixes are the suffixes of the tags cosign stores signatures,
+// attestations and SBOMs with when not using OCI referrers.
+var cosignTagSuffixes = []string{".sig", ".att", ".sbom"}
+
+// Attestation is an attestation manifest along with the image manifest it
+// refers to.
+type Attestation struct {
+	Source  *Source
+	Subject ocispec.Descriptor
+}
+
+// SplitAttestations separates the attestation manifests from the image
+// manifests of the index sources. Index sources holding attestations are
+// replaced by their image manifests matching the platforms of the source,
+// other sources are returned unchanged.
+func (r *Resolver) SplitAttestations(ctx context.Context, srcs []*Source) ([]*Source, []*Attestation, error) {
+	out := make([]*Source, 0, len(srcs))
+	var atts []*Attestation
+	for _, src := range srcs {
+		switch src.Desc.MediaType {
+		case images.MediaTypeDockerSchema2ManifestList, ocispec.MediaTypeImageIndex:
+		default:
+			out = append(out, src)
+			continue
+		}
+
+		dt, err := r.GetDescriptor(ctx, src.Ref.String(), src.Desc)
+		if err != nil {
+			return nil, nil, err
+		}
+		var idx ocispec.Index
+		if err := json.Unmarshal(dt, &idx); err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+
+		var mfsts, attDescs []ocispec.Descriptor
+		for _, d := range idx.Manifests {
+			if d.Annotations[annotationReferenceType] == attestationManifestType {
+				attDescs = append(attDescs, d)
+			} else if d, ok := src.selectPlatform(d); ok {
+				mfsts = append(mfsts, d)
+			}
+		}
+		if len(attDescs) == 0 {
+			out = append(out, src)
+			continue
+		}
+		if len(mfsts) == 0 {
+			return nil, nil, src.noPlatformError()
+		}
+
+		for _, d := range mfsts {
+			out = append(out, &Source{Ref: src.Ref, Desc: d})
+		}
+		for _, d := range attDescs {
+			subject, ok := attestationSubject(d, mfsts)
+			if !ok {
+				// dangling attestation, nothing to refer to
+				continue
+			}
+			atts = append(atts, &Attestation{
+				Source:  &Source{Ref: src.Ref, Desc: d},
+				Subject: subject,
+			})
+		}
+	}
+	return out, atts, nil
+}
+
+func attestationSubject(desc ocispec.Descriptor, mfsts []ocispec.Descriptor) (ocispec.Descriptor, bool) {
+	for _, annotationReference := range annotationReferences {
+		ref, ok := desc.Annotations[annotationReference]
+		if !ok {
+			continue
+		}
+		for _, d := range mfsts {
+			if d.Digest.String() == ref {
+				return ocispec.Descriptor{
+					MediaType: d.MediaType,
+					Digest:    d.Digest,
+					Size:      d.Size,
+				}, true
+			}
+		}
+	}
+	return ocispec.Descriptor{}, false
+}
+
+// PushAttestation pushes an attestation manifest to the repository of dest
+// as an OCI referrer of the image manifest it refers to.
+func (r *Resolver) PushAttestation(ctx context.Context, a *Attestation, dest reference.Named) (ocispec.Descriptor, error) {
+	ctx = withDiscardLogger(ctx)
+
+	dt, err := r.GetDescriptor(ctx, a.Source.Ref.String(), a.Source.Desc)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	var mfst ocispec.Manifest
+	if err := json.Unmarshal(dt, &mfst); err != nil {
+		return ocispec.Descriptor{}, errors.WithStack(err)
+	}
+
+	for _, d := range append([]ocispec.Descriptor{mfst.Config}, mfst.Layers...) {
+		if err := r.copy(ctx, &Source{Ref: a.Source.Ref, Desc: d}, dest); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+	}
+
+	dt, desc, err := attestationReferrer(mfst, a.Source.Desc, a.Subject)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	ref, err := reference.WithDigest(reference.TrimNamed(dest), desc.Digest)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if err := r.push(ctx, ref, desc, dt); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if err := r.addReferrers(ctx, dest, a.Subject, []ocispec.Descriptor{desc}); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	return desc, nil
+}
+
+// attestationReferrer returns the attestation manifest mfst with its subject
+// set, so it can be pushed as an OCI referrer.
+func attestationReferrer(mfst ocispec.Manifest, desc ocispec.Descriptor, subject ocispec.Descriptor) ([]byte, ocispec.Descriptor, error) {
+	mfst.MediaType = ocispec.MediaTypeImageManifest
+	mfst.Subject = &subject
+	if mfst.ArtifactType == "" {
+		mfst.ArtifactType = inTotoGenericMime
+	}
+	dt, err := json.MarshalIndent(mfst, "", "  ")
+	if err != nil {
+		return nil, ocispec.Descriptor{}, errors.Wrap(err, "failed to marshal attestation manifest")
+	}
+	annotations := make(map[string]string, len(desc.Annotations))
+	for k, v := range desc.Annotations {
+		annotations[k] = v
+	}
+	return dt, ocispec.Descriptor{
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: mfst.ArtifactType,
+		Digest:       digest.FromBytes(dt),
+		Size:         int64(len(dt)),
+		Annotations:  annotations,
+	}, nil
+}
+
+// CopyReferrers copies the referrers of src, like signatures and SBOMs, to the
+// repository of dest. The referrers of the manifests of an index are copied as
+// well. Referrers are looked up with the referrers API of the registry, or the
+// referrers tag schema if the registry doesn't support it, and with the tags
+// cosign uses when not storing signatures as OCI referrers.
+func (r *Resolver) CopyReferrers(ctx context.Context, src *Source, dest reference.Named) ([]ocispec.Descriptor, error) {
+	return r.referrers(ctx, src, dest)
+}
+
+// ListReferrers returns the referrers CopyReferrers would copy for src.
+func (r *Resolver) ListReferrers(ctx context.Context, src *Source) ([]ocispec.Descriptor, error) {
+	return r.referrers(ctx, src, nil)
+}
+
+// referrers copies the referrers of src to dest, or only lists them if dest is
+// nil.
+func (r *Resolver) referrers(ctx context.Context, src *Source, dest reference.Named) ([]ocispec.Descriptor, error) {
+	ctx = withDiscardLogger(ctx)
+
+	subjects := []ocispec.Descriptor{src.Desc}
+	switch src.Desc.MediaType {
+	case images.MediaTypeDockerSchema2ManifestList, ocispec.MediaTypeImageIndex:
+		dt, err := r.GetDescriptor(ctx, src.Ref.String(), src.Desc)
+		if err != nil {
+			return nil, err
+		}
+		var idx ocispec.Index
+		if err := json.Unmarshal(dt, &idx); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		subjects = append(subjects, idx.Manifests...)
+	}
+
+	var copied []ocispec.Descriptor
+	seen := map[digest.Digest]struct{}{}
+	for _, subject := range subjects {
+		descs, err := r.copyReferrers(ctx, src.Ref, dest, subject, seen)
+		if err != nil {
+			return nil, err
+		}
+		copied = append(copied, descs...)
+	}
+	return copied, nil
+}
+
+func (r *Resolver) copyReferrers(ctx context.Context, src, dest reference.Named, subject ocispec.Descriptor, seen map[digest.Digest]struct{}) ([]ocispec.Descriptor, error) {
+	if _, ok := seen[subject.Digest]; ok {
+		return nil, nil
+	}
+	seen[subject.Digest] = struct{}{}
+
+	descs, err := r.Referrers(ctx, src, subject.Digest)
+	if err != nil {
+		return nil, err
+	}
+	if dest != nil {
+		for _, d := range descs {
+			ref, err := reference.WithDigest(reference.TrimNamed(dest), d.Digest)
+			if err != nil {
+				return nil, err
+			}
+			if err := r.copy(ctx, &Source{Ref: src, Desc: d}, ref); err != nil {
+				return nil, err
+			}
+		}
+		if len(descs) > 0 {
+			if err := r.addReferrers(ctx, dest, subject, descs); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	copied := descs
+	for _, suffix := range cosignTagSuffixes {
+		tag := referrersTag(subject.Digest) + suffix
+		srcTag, err := reference.WithTag(reference.TrimNamed(src), tag)
+		if err != nil {
+			return nil, err
+		}
+		_, desc, err := r.Resolve(ctx, srcTag.String())
+		if err != nil {
+			if errdefs.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		if dest != nil {
+			destTag, err := reference.WithTag(reference.TrimNamed(dest), tag)
+			if err != nil {
+				return nil, err
+			}
+			if err := r.copy(ctx, &Source{Ref: srcTag, Desc: desc}, destTag); err != nil {
+				return nil, err
+			}
+		}
+		copied = append(copied, desc)
+	}
+
+	// referrers can have referrers of their own, like the signature of an SBOM
+	for _, d := range descs {
+		sub, err := r.copyReferrers(ctx, src, dest, d, seen)
+		if err != nil {
+			return nil, err
+		}
+		copied = append(copied, sub...)
+	}
+	return copied, nil
+}
+
+// Referrers returns the descriptors of the manifests referring to the manifest
+// dgst in the repository of ref.
+func (r *Resolver) Referrers(ctx context.Context, ref reference.Named, dgst digest.Digest) ([]ocispec.Descriptor, error) {
+	descs, ok, err := r.fetchReferrers(ctx, ref, dgst)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return descs, nil
+	}
+	idx, err := r.referrersTagIndex(ctx, ref, dgst)
+	if err != nil || idx == nil {
+		return nil, err
+	}
+	return idx.Manifests, nil
+}
+
+// addReferrers makes descs discoverable as referrers of subject in the
+// repository of ref. Registries supporting the referrers API index the
+// subject of the pushed manifests themselves, others get the referrers tag
+// schema index of the subject updated.
+func (r *Resolver) addReferrers(ctx context.Context, ref reference.Named, subject ocispec.Descriptor, descs []ocispec.Descriptor) error {
+	_, ok, err := r.fetchReferrers(ctx, ref, subject.Digest)
+	if err != nil || ok {
+		return err
+	}
+
+	idx, err := r.referrersTagIndex(ctx, ref, subject.Digest)
+	if err != nil {
+		return err
+	}
+	if idx == nil {
+		idx = &ocispec.Index{
+			Versioned: specs.Versioned{
+				SchemaVersion: 2,
+			},
+			MediaType: ocispec.MediaTypeImageIndex,
+		}
+	}
+	existing := make(map[digest.Digest]struct{}, len(idx.Manifests))
+	for _, d := range idx.Manifests {
+		existing[d.Digest] = struct{}{}
+	}
+	n := len(idx.Manifests)
+	for _, d := range descs {
+		if _, ok := existing[d.Digest]; ok {
+			continue
+		}
+		existing[d.Digest] = struct{}{}
+		idx.Manifests = append(idx.Manifests, d)
+	}
+	if len(idx.Manifests) == n {
+		return nil
+	}
+
+	dt, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal referrers index")
+	}
+	tagRef, err := reference.WithTag(reference.TrimNamed(ref), referrersTag(subject.Digest))
+	if err != nil {
+		return err
+	}
+	return r.push(ctx, tagRef, ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Digest:    digest.FromBytes(dt),
+		Size:      int64(len(dt)),
+	}, dt)
+}
+
+// referrersTagIndex returns the index of the referrers tag schema for the
+// manifest dgst, or nil if there is none.
+func (r *Resolver) referrersTagIndex(ctx context.Context, ref reference.Named, dgst digest.Digest) (*ocispec.Index, error) {
+	tagRef, err := reference.WithTag(reference.TrimNamed(ref), referrersTag(dgst))
+	if err != nil {
+		return nil, err
+	}
+	dt, _, err := r.Get(ctx, tagRef.String())
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var idx ocispec.Index
+	if err := json.Unmarshal(dt, &idx); err != nil {
+		return nil, errors.Wrapf(err, "invalid referrers index %s", tagRef)
+	}
+	return &idx, nil
+}
+
+// referrersTag returns the tag of the referrers tag schema for the manifest
+// dgst, like sha256-<hex>.
+func referrersTag(dgst digest.Digest) string {
+	return dgst.Algorithm().String() + "-" + dgst.Encoded()
+}
+
+// fetchReferrers lists the referrers of the manifest dgst with the referrers
+// API of the registry. It returns false if the registry does not support the
+// referrers API.
+func (r *Resolver) fetchReferrers(ctx context.Context, ref reference.Named, dgst digest.Digest) ([]ocispec.Descriptor, bool, error) {
+	host, err := r.registryHost(ref)
+	if err != nil {
+		return nil, false, err
+	}
+
+	ctx = docker.WithScope(ctx, "repository:"+reference.Path(ref)+":pull")
+	u := registryURL(host, ref, "/referrers/"+dgst.String())
+	var descs []ocispec.Descriptor
+	for u != nil {
+		resp, err := r.doRegistryRequest(ctx, host, http.MethodGet, u)
+		if err != nil {
+			return nil, false, err
+		}
+		switch resp.StatusCode {
+		case http.StatusOK:
+		case http.StatusNotFound:
+			resp.Body.Close()
+			return nil, false, nil
+		default:
+			resp.Body.Close()
+			return nil, false, errors.Errorf("unexpected status listing referrers of %s: %s", dgst, resp.Status)
+		}
+		if mt, _, _ := strings.Cut(resp.Header.Get("Content-Type"), ";"); mt != ocispec.MediaTypeImageIndex {
+			// registries not supporting the referrers API may serve anything
+			resp.Body.Close()
+			return nil, false, nil
+		}
+		var idx ocispec.Index
+		err = json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(&idx)
+		resp.Body.Close()
+		if err != nil {
+			return nil, false, errors.Wrapf(err, "invalid referrers response for %s", dgst)
+		}
+		descs = append(descs, idx.Manifests...)
+
+		next, err := nextLink(u, resp.Header)
+		if err != nil {
+			return nil, false, err
+		}
+		u = next
+	}
+	return descs, true, nil
+}
+
+// registryHost returns the registry host images of ref are pushed to,
+// skipping mirrors.
+func (r *Resolver) registryHost(ref reference.Named) (docker.RegistryHost, error) {
+	hosts, err := r.hosts(reference.Domain(ref))
+	if err != nil {
+		return docker.RegistryHost{}, err
+	}
+	for _, host := range hosts {
+		if host.Capabilities.Has(docker.HostCapabilityPush) {
+			if host.Client == nil {
+				host.Client = tracing.DefaultClient
+			}
+			return host, nil
+		}
+	}
+	return docker.RegistryHost{}, errors.Errorf("no registry host for %s", reference.Domain(ref))
+}
+
+// registryURL returns the URL of an API endpoint of the repository of ref.
+func registryURL(host docker.RegistryHost, ref reference.Named, endpoint string) *url.URL {
+	return &url.URL{
+		Scheme: host.Scheme,
+		Host:   host.Host,
+		Path:   host.Path + "/" + reference.Path(ref) + endpoint,
+	}
+}
+
+func (r *Resolver) doRegistryRequest(ctx context.Context, host docker.RegistryHost, method string, u *url.URL) (*http.Response, error) {
+	for i := 0; ; i++ {
+		req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", ocispec.MediaTypeImageIndex)
+		if err := r.auth.Authorize(ctx, req); err != nil {
+			return nil, err
+		}
+		resp, err := host.Client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusUnauthorized || i > 0 {
+			return resp, nil
+		}
+		err = r.auth.AddResponses(ctx, []*http.Response{resp})
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// withDiscardLogger discards the containerd logger, which warns about every
+// blob of the artifact media types referrers commonly use.
+func withDiscardLogger(ctx context.Context) context.Context {
+	logger := logrus.New()
+	logger.Out = io.Discard
+	return log.WithLogger(ctx, logrus.NewEntry(logger))
+}
+
+// nextLink returns the next page of a paginated response from the Link
+// header, or nil if there is none.
+func nextLink(u *url.URL, h http.Header) (*url.URL, error) {
+	for _, link := range h.Values("Link") {
+		target, params, ok := strings.Cut(link, ";")
+		if !ok || !strings.Contains(params, `rel="next"`) {
+			continue
+		}
+		target = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(target), "<"), ">")
+		next, err := u.Parse(target)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid link %q", link)
+		}
+		return next, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,376 @@
+package imagetools
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyReferrers(t *testing.T) {
+	reg := newTestRegistry(t)
+	reg.noReferrers["fallback/app"] = true
+	r := New(Opt{})
+	ctx := context.TODO()
+
+	img := reg.pushImage(t, "src/app", "latest")
+	sig := reg.pushManifest(t, "src/app", "", ocispec.Manifest{
+		Versioned:    specs.Versioned{SchemaVersion: 2},
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: "application/vnd.dev.cosign.artifact.sig.v1+json",
+		Config:       reg.pushBlob(t, ocispec.MediaTypeEmptyJSON, []byte("{}")),
+		Layers:       []ocispec.Descriptor{reg.pushBlob(t, "application/vnd.dev.cosign.simplesigning.v1+json", []byte("signature"))},
+		Subject:      &img,
+	})
+	legacySig := reg.pushManifest(t, "src/app", referrersTag(img.Digest)+".sig", ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    reg.pushBlob(t, ocispec.MediaTypeEmptyJSON, []byte("{}")),
+		Layers:    []ocispec.Descriptor{reg.pushBlob(t, "application/vnd.dev.cosign.simplesigning.v1+json", []byte("legacy"))},
+	})
+
+	// listing only
+	descs, err := r.ListReferrers(ctx, &Source{Ref: reg.ref(t, "src/app"), Desc: img})
+	require.NoError(t, err)
+	require.Len(t, descs, 2)
+	require.Equal(t, sig.Digest, descs[0].Digest)
+	require.Equal(t, legacySig.Digest, descs[1].Digest)
+	require.False(t, reg.hasManifest("fallback/app", sig.Digest.String()))
+
+	// referrers API to tag schema fallback
+	descs, err = r.CopyReferrers(ctx, &Source{Ref: reg.ref(t, "src/app"), Desc: img}, reg.ref(t, "fallback/app"))
+	require.NoError(t, err)
+	require.Len(t, descs, 2)
+	require.Equal(t, sig.Digest, descs[0].Digest)
+	require.Equal(t, legacySig.Digest, descs[1].Digest)
+
+	require.True(t, reg.hasManifest("fallback/app", sig.Digest.String()))
+	require.True(t, reg.hasManifest("fallback/app", referrersTag(img.Digest)+".sig"))
+	idx, err := r.referrersTagIndex(ctx, reg.ref(t, "fallback/app"), img.Digest)
+	require.NoError(t, err)
+	require.NotNil(t, idx)
+	require.Len(t, idx.Manifests, 1)
+	require.Equal(t, sig.Digest, idx.Manifests[0].Digest)
+
+	// tag schema fallback to referrers API
+	_, err = r.CopyReferrers(ctx, &Source{Ref: reg.ref(t, "fallback/app"), Desc: img}, reg.ref(t, "dest/app"))
+	require.NoError(t, err)
+	refs, err := r.Referrers(ctx, reg.ref(t, "dest/app"), img.Digest)
+	require.NoError(t, err)
+	require.Len(t, refs, 1)
+	require.Equal(t, sig.Digest, refs[0].Digest)
+	require.False(t, reg.hasManifest("dest/app", referrersTag(img.Digest)))
+}
+
+func TestSplitAttestations(t *testing.T) {
+	reg := newTestRegistry(t)
+	r := New(Opt{})
+	ctx := context.TODO()
+
+	img := reg.pushImage(t, "src/app", "")
+	img.Platform = &ocispec.Platform{OS: "linux", Architecture: "amd64"}
+	att := reg.pushManifest(t, "src/app", "", ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    reg.pushBlob(t, ocispec.MediaTypeImageConfig, []byte(`{"architecture":"unknown","os":"unknown"}`)),
+		Layers:    []ocispec.Descriptor{reg.pushBlob(t, inTotoGenericMime, []byte(`{"predicateType":"https://spdx.dev/Document"}`))},
+	})
+	att.Annotations = map[string]string{
+		annotationReferenceType:       attestationManifestType,
+		"vnd.docker.reference.digest": img.Digest.String(),
+	}
+	att.Platform = &ocispec.Platform{OS: "unknown", Architecture: "unknown"}
+	idx := reg.pushIndex(t, "src/app", "latest", img, att)
+
+	plain := reg.pushImage(t, "src/other", "latest")
+
+	srcs, atts, err := r.SplitAttestations(ctx, []*Source{
+		{Ref: reg.ref(t, "src/app"), Desc: idx},
+		{Ref: reg.ref(t, "src/other"), Desc: plain},
+	})
+	require.NoError(t, err)
+	require.Len(t, srcs, 2)
+	require.Equal(t, img.Digest, srcs[0].Desc.Digest)
+	require.Equal(t, plain.Digest, srcs[1].Desc.Digest)
+	require.Len(t, atts, 1)
+	require.Equal(t, att.Digest, atts[0].Source.Desc.Digest)
+	require.Equal(t, img.Digest, atts[0].Subject.Digest)
+
+	desc, err := r.PushAttestation(ctx, atts[0], reg.ref(t, "dest/app"))
+	require.NoError(t, err)
+	require.Equal(t, inTotoGenericMime, desc.ArtifactType)
+
+	refs, err := r.Referrers(ctx, reg.ref(t, "dest/app"), img.Digest)
+	require.NoError(t, err)
+	require.Len(t, refs, 1)
+	require.Equal(t, desc.Digest, refs[0].Digest)
+
+	dt, err := r.GetDescriptor(ctx, reg.ref(t, "dest/app").String(), desc)
+	require.NoError(t, err)
+	var mfst ocispec.Manifest
+	require.NoError(t, json.Unmarshal(dt, &mfst))
+	require.NotNil(t, mfst.Subject)
+	require.Equal(t, img.Digest, mfst.Subject.Digest)
+}
+
+func TestNextLink(t *testing.T) {
+	u, err := url.Parse("http://localhost:5000/v2/app/referrers/sha256:abc")
+	require.NoError(t, err)
+
+	next, err := nextLink(u, http.Header{"Link": []string{`</v2/app/referrers/sha256:abc?n=1&last=x>; rel="next"`}})
+	require.NoError(t, err)
+	require.Equal(t, "http://localhost:5000/v2/app/referrers/sha256:abc?n=1&last=x", next.String())
+
+	next, err = nextLink(u, http.Header{})
+	require.NoError(t, err)
+	require.Nil(t, next)
+}
+
+type testManifest struct {
+	mediaType string
+	dt        []byte
+}
+
+// testRegistry is an in-memory registry serving the subset of the
+// distribution API used by the resolver.
+type testRegistry struct {
+	host string
+
+	mu          sync.Mutex
+	blobs       map[digest.Digest][]byte
+	manifests   map[string]map[string]testManifest
+	noReferrers map[string]bool
+}
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	reg := &testRegistry{
+		blobs:       map[digest.Digest][]byte{},
+		manifests:   map[string]map[string]testManifest{},
+		noReferrers: map[string]bool{},
+	}
+	srv := httptest.NewServer(reg)
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	reg.host = "localhost:" + u.Port()
+	return reg
+}
+
+func (reg *testRegistry) ref(t *testing.T, repo string) reference.Named {
+	ref, err := reference.ParseNormalizedNamed(reg.host + "/" + repo)
+	require.NoError(t, err)
+	return ref
+}
+
+func (reg *testRegistry) hasManifest(repo, ref string) bool {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	_, ok := reg.manifests[repo][ref]
+	return ok
+}
+
+func (reg *testRegistry) putManifest(repo, ref string, m testManifest) digest.Digest {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.manifests[repo] == nil {
+		reg.manifests[repo] = map[string]testManifest{}
+	}
+	dgst := digest.FromBytes(m.dt)
+	reg.manifests[repo][dgst.String()] = m
+	if ref != "" {
+		reg.manifests[repo][ref] = m
+	}
+	return dgst
+}
+
+func (reg *testRegistry) pushBlob(t *testing.T, mt string, dt []byte) ocispec.Descriptor {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	dgst := digest.FromBytes(dt)
+	reg.blobs[dgst] = dt
+	return ocispec.Descriptor{MediaType: mt, Digest: dgst, Size: int64(len(dt))}
+}
+
+func (reg *testRegistry) pushManifest(t *testing.T, repo, tag string, mfst ocispec.Manifest) ocispec.Descriptor {
+	dt, err := json.Marshal(mfst)
+	require.NoError(t, err)
+	dgst := reg.putManifest(repo, tag, testManifest{mediaType: mfst.MediaType, dt: dt})
+	return ocispec.Descriptor{MediaType: mfst.MediaType, Digest: dgst, Size: int64(len(dt))}
+}
+
+func (reg *testRegistry) pushIndex(t *testing.T, repo, tag string, descs ...ocispec.Descriptor) ocispec.Descriptor {
+	dt, err := json.Marshal(ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: descs,
+	})
+	require.NoError(t, err)
+	dgst := reg.putManifest(repo, tag, testManifest{mediaType: ocispec.MediaTypeImageIndex, dt: dt})
+	return ocispec.Descriptor{MediaType: ocispec.MediaTypeImageIndex, Digest: dgst, Size: int64(len(dt))}
+}
+
+func (reg *testRegistry) pushImage(t *testing.T, repo, tag string) ocispec.Descriptor {
+	return reg.pushManifest(t, repo, tag, ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    reg.pushBlob(t, ocispec.MediaTypeImageConfig, []byte(`{"architecture":"amd64","os":"linux"}`)),
+		Layers:    []ocispec.Descriptor{reg.pushBlob(t, ocispec.MediaTypeImageLayerGzip, []byte("layer"))},
+	})
+}
+
+func (reg *testRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	p := strings.TrimPrefix(req.URL.Path, "/v2/")
+	if p == "" || p == req.URL.Path {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	for _, kind := range []string{"/manifests/", "/blobs/uploads/", "/blobs/", "/referrers/"} {
+		repo, ref, ok := strings.Cut(p, kind)
+		if !ok {
+			continue
+		}
+		switch kind {
+		case "/manifests/":
+			reg.serveManifest(w, req, repo, ref)
+		case "/blobs/uploads/":
+			reg.serveUpload(w, req, repo)
+		case "/blobs/":
+			reg.serveBlob(w, req, digest.Digest(ref))
+		case "/referrers/":
+			reg.serveReferrers(w, repo, ref)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func (reg *testRegistry) serveManifest(w http.ResponseWriter, req *http.Request, repo, ref string) {
+	if req.Method == http.MethodDelete {
+		reg.mu.Lock()
+		defer reg.mu.Unlock()
+		if _, ok := reg.manifests[repo][ref]; !ok || !strings.HasPrefix(ref, "sha256:") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for k, m := range reg.manifests[repo] {
+			if digest.FromBytes(m.dt).String() == ref {
+				delete(reg.manifests[repo], k)
+			}
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if req.Method == http.MethodPut {
+		dt, err := io.ReadAll(req.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		tag := ref
+		if strings.HasPrefix(ref, "sha256:") {
+			tag = ""
+		}
+		dgst := reg.putManifest(repo, tag, testManifest{mediaType: req.Header.Get("Content-Type"), dt: dt})
+		w.Header().Set("Docker-Content-Digest", dgst.String())
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	reg.mu.Lock()
+	m, ok := reg.manifests[repo][ref]
+	reg.mu.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", m.mediaType)
+	w.Header().Set("Docker-Content-Digest", digest.FromBytes(m.dt).String())
+	w.Header().Set("Content-Length", strconv.Itoa(len(m.dt)))
+	w.WriteHeader(http.StatusOK)
+	if req.Method == http.MethodGet {
+		w.Write(m.dt)
+	}
+}
+
+func (reg *testRegistry) serveBlob(w http.ResponseWriter, req *http.Request, dgst digest.Digest) {
+	reg.mu.Lock()
+	dt, ok := reg.blobs[dgst]
+	reg.mu.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Docker-Content-Digest", dgst.String())
+	w.Header().Set("Content-Length", strconv.Itoa(len(dt)))
+	w.WriteHeader(http.StatusOK)
+	if req.Method == http.MethodGet {
+		w.Write(dt)
+	}
+}
+
+func (reg *testRegistry) serveUpload(w http.ResponseWriter, req *http.Request, repo string) {
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Set("Location", "/v2/"+repo+"/blobs/uploads/upload")
+		w.WriteHeader(http.StatusAccepted)
+	case http.MethodPut:
+		dt, err := io.ReadAll(req.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		dgst := digest.FromBytes(dt)
+		reg.mu.Lock()
+		reg.blobs[dgst] = dt
+		reg.mu.Unlock()
+		w.Header().Set("Docker-Content-Digest", dgst.String())
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (reg *testRegistry) serveReferrers(w http.ResponseWriter, repo, ref string) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.noReferrers[repo] {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	idx := ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{},
+	}
+	for k, m := range reg.manifests[repo] {
+		if !strings.HasPrefix(k, "sha256:") {
+			continue
+		}
+		var mfst ocispec.Manifest
+		if err := json.Unmarshal(m.dt, &mfst); err != nil || mfst.Subject == nil || mfst.Subject.Digest.String() != ref {
+			continue
+		}
+		idx.Manifests = append(idx.Manifests, ocispec.Descriptor{
+			MediaType:    m.mediaType,
+			ArtifactType: mfst.ArtifactType,
+			Digest:       digest.FromBytes(m.dt),
+			Size:         int64(len(m.dt)),
+		})
+	}
+	dt, _ := json.Marshal(idx)
+	w.Header().Set("Content-Type", ocispec.MediaTypeImageIndex)
+	w.WriteHeader(http.StatusOK)
+	w.Write(dt)
+}
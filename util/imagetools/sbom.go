@@ -0,0 +1,354 @@
+package imagetools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/containerd/platforms"
+	"github.com/google/uuid"
+	"github.com/moby/buildkit/identity"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+const noAssertion = "NOASSERTION"
+
+// SBOM is the normalized SBOM of an image, from the SPDX documents of its
+// SBOM attestations.
+type SBOM struct {
+	Name      string        `json:"name"`
+	Digest    digest.Digest `json:"digest"`
+	Platforms []string      `json:"platforms"`
+	Packages  []SBOMPackage `json:"packages"`
+}
+
+// SBOMPackage is a package of an SBOM.
+type SBOMPackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	// Type is the type of the package URL, like "apk" or "golang".
+	Type    string `json:"type,omitempty"`
+	PURL    string `json:"purl,omitempty"`
+	License string `json:"license,omitempty"`
+}
+
+// LicenseCount is the number of packages of an SBOM with a license.
+type LicenseCount struct {
+	License  string `json:"license"`
+	Packages int    `json:"packages"`
+}
+
+// SBOM returns the SBOM of the image name for the platform, or merged for all
+// the platforms if nil.
+func (r *Resolver) SBOM(ctx context.Context, name string, platform *ocispec.Platform) (*SBOM, error) {
+	ctx = withDiscardLogger(ctx)
+
+	res, desc, err := r.loadImage(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	sboms, err := res.SBOM()
+	if err != nil {
+		return nil, err
+	}
+
+	var m platforms.MatchComparer
+	if platform != nil {
+		m = platforms.Only(*platform)
+	}
+
+	s := &SBOM{Name: name, Digest: desc.Digest}
+	seen := map[SBOMPackage]struct{}{}
+	for _, p := range res.platforms {
+		if m != nil {
+			pp, err := platforms.Parse(p)
+			if err != nil || !m.Match(pp) {
+				continue
+			}
+		}
+		sbom, ok := sboms[p]
+		if !ok {
+			continue
+		}
+		s.Platforms = append(s.Platforms, p)
+		for _, pkg := range spdxPackages(sbom) {
+			if _, ok := seen[pkg]; ok {
+				continue
+			}
+			seen[pkg] = struct{}{}
+			s.Packages = append(s.Packages, pkg)
+		}
+	}
+	if len(s.Platforms) == 0 {
+		if platform != nil {
+			return nil, errors.Errorf("no SBOM attestation found in %s for %s", name, platforms.Format(*platform))
+		}
+		return nil, errors.Errorf("no SBOM attestation found in %s", name)
+	}
+	sort.Slice(s.Packages, func(i, j int) bool {
+		if s.Packages[i].Name != s.Packages[j].Name {
+			return s.Packages[i].Name < s.Packages[j].Name
+		}
+		if s.Packages[i].Version != s.Packages[j].Version {
+			return s.Packages[i].Version < s.Packages[j].Version
+		}
+		return s.Packages[i].PURL < s.Packages[j].PURL
+	})
+	return s, nil
+}
+
+// spdxPackages returns the packages of the SPDX documents of an SBOM
+// attestation.
+func spdxPackages(sbom sbomStub) []SBOMPackage {
+	var pkgs []SBOMPackage
+	for _, doc := range append([]interface{}{sbom.SPDX}, sbom.AdditionalSPDXs...) {
+		dt, err := json.Marshal(doc)
+		if err != nil {
+			continue
+		}
+		var spdx struct {
+			Packages []struct {
+				Name             string `json:"name"`
+				VersionInfo      string `json:"versionInfo"`
+				LicenseConcluded string `json:"licenseConcluded"`
+				LicenseDeclared  string `json:"licenseDeclared"`
+				ExternalRefs     []struct {
+					ReferenceType    string `json:"referenceType"`
+					ReferenceLocator string `json:"referenceLocator"`
+				} `json:"externalRefs"`
+			} `json:"packages"`
+		}
+		if err := json.Unmarshal(dt, &spdx); err != nil {
+			continue
+		}
+		for _, p := range spdx.Packages {
+			if p.Name == "" {
+				continue
+			}
+			pkg := SBOMPackage{
+				Name:    p.Name,
+				Version: p.VersionInfo,
+				License: spdxLicense(p.LicenseConcluded),
+			}
+			if pkg.License == "" {
+				pkg.License = spdxLicense(p.LicenseDeclared)
+			}
+			for _, ref := range p.ExternalRefs {
+				if ref.ReferenceType == "purl" {
+					pkg.PURL = ref.ReferenceLocator
+					pkg.Type = purlType(pkg.PURL)
+					break
+				}
+			}
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs
+}
+
+func spdxLicense(v string) string {
+	if v == noAssertion || v == "NONE" {
+		return ""
+	}
+	return v
+}
+
+func purlType(purl string) string {
+	t, _, ok := strings.Cut(strings.TrimPrefix(purl, "pkg:"), "/")
+	if !ok || !strings.HasPrefix(purl, "pkg:") {
+		return ""
+	}
+	return t
+}
+
+// Licenses returns the number of packages by license, packages without
+// license being counted as "unknown".
+func (s *SBOM) Licenses() []LicenseCount {
+	counts := map[string]int{}
+	for _, p := range s.Packages {
+		l := p.License
+		if l == "" {
+			l = "unknown"
+		}
+		counts[l]++
+	}
+	out := make([]LicenseCount, 0, len(counts))
+	for l, n := range counts {
+		out = append(out, LicenseCount{License: l, Packages: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Packages != out[j].Packages {
+			return out[i].Packages > out[j].Packages
+		}
+		return out[i].License < out[j].License
+	})
+	return out
+}
+
+// Diff returns the packages added, removed or changed in the SBOM to.
+func (s *SBOM) Diff(to *SBOM) *Diff {
+	return &Diff{
+		From:     DiffImage{Name: s.Name, Digest: s.Digest},
+		To:       DiffImage{Name: to.Name, Digest: to.Digest},
+		Packages: diffPackages(packageVersions(s.Packages), packageVersions(to.Packages)),
+	}
+}
+
+// PrintTable writes the packages and the license summary of the SBOM.
+func (s *SBOM) PrintTable(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", s.Name)
+	fmt.Fprintf(w, "Digest:\t%s\n", s.Digest)
+	fmt.Fprintf(w, "Platforms:\t%s\n", strings.Join(s.Platforms, ", "))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVERSION\tTYPE\tLICENSE")
+	for _, p := range s.Packages {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", p.Name, p.Version, p.Type, p.License)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "LICENSE\tPACKAGES")
+	for _, l := range s.Licenses() {
+		fmt.Fprintf(w, "%s\t%d\n", l.License, l.Packages)
+	}
+	return w.Flush()
+}
+
+// SPDX returns the SBOM as an SPDX 2.3 JSON document.
+func (s *SBOM) SPDX() ([]byte, error) {
+	type externalRef struct {
+		ReferenceCategory string `json:"referenceCategory"`
+		ReferenceType     string `json:"referenceType"`
+		ReferenceLocator  string `json:"referenceLocator"`
+	}
+	type pkg struct {
+		Name             string        `json:"name"`
+		SPDXID           string        `json:"SPDXID"`
+		VersionInfo      string        `json:"versionInfo,omitempty"`
+		DownloadLocation string        `json:"downloadLocation"`
+		FilesAnalyzed    bool          `json:"filesAnalyzed"`
+		LicenseConcluded string        `json:"licenseConcluded"`
+		LicenseDeclared  string        `json:"licenseDeclared"`
+		ExternalRefs     []externalRef `json:"externalRefs,omitempty"`
+	}
+	doc := struct {
+		SPDXVersion       string `json:"spdxVersion"`
+		DataLicense       string `json:"dataLicense"`
+		SPDXID            string `json:"SPDXID"`
+		Name              string `json:"name"`
+		DocumentNamespace string `json:"documentNamespace"`
+		CreationInfo      struct {
+			Created  string   `json:"created"`
+			Creators []string `json:"creators"`
+		} `json:"creationInfo"`
+		Packages []pkg `json:"packages"`
+	}{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              s.Name,
+		DocumentNamespace: "https://docs.docker.com/build/sbom/" + s.Digest.Encoded() + "-" + identity.NewID(),
+		Packages:          make([]pkg, 0, len(s.Packages)),
+	}
+	doc.CreationInfo.Created = time.Now().UTC().Format(time.RFC3339)
+	doc.CreationInfo.Creators = []string{"Tool: buildx"}
+
+	for i, p := range s.Packages {
+		license := p.License
+		if license == "" {
+			license = noAssertion
+		}
+		sp := pkg{
+			Name:             p.Name,
+			SPDXID:           fmt.Sprintf("SPDXRef-Package-%d", i+1),
+			VersionInfo:      p.Version,
+			DownloadLocation: noAssertion,
+			LicenseConcluded: license,
+			LicenseDeclared:  noAssertion,
+		}
+		if p.PURL != "" {
+			sp.ExternalRefs = []externalRef{{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  p.PURL,
+			}}
+		}
+		doc.Packages = append(doc.Packages, sp)
+	}
+	dt, err := json.MarshalIndent(doc, "", "  ")
+	return dt, errors.WithStack(err)
+}
+
+// CycloneDX returns the SBOM as a CycloneDX 1.5 JSON document.
+func (s *SBOM) CycloneDX() ([]byte, error) {
+	type license struct {
+		Expression string `json:"expression"`
+	}
+	type component struct {
+		Type     string    `json:"type"`
+		BOMRef   string    `json:"bom-ref,omitempty"`
+		Name     string    `json:"name"`
+		Version  string    `json:"version,omitempty"`
+		PURL     string    `json:"purl,omitempty"`
+		Licenses []license `json:"licenses,omitempty"`
+	}
+	doc := struct {
+		BOMFormat    string `json:"bomFormat"`
+		SpecVersion  string `json:"specVersion"`
+		SerialNumber string `json:"serialNumber"`
+		Version      int    `json:"version"`
+		Metadata     struct {
+			Timestamp string    `json:"timestamp"`
+			Component component `json:"component"`
+		} `json:"metadata"`
+		Components []component `json:"components"`
+	}{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.5",
+		Version:     1,
+		Components:  make([]component, 0, len(s.Packages)),
+	}
+	doc.SerialNumber = "urn:uuid:" + uuid.New().String()
+	doc.Metadata.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	doc.Metadata.Component = component{
+		Type:    "container",
+		BOMRef:  s.Digest.String(),
+		Name:    s.Name,
+		Version: s.Digest.String(),
+	}
+
+	for i, p := range s.Packages {
+		c := component{
+			Type:    "library",
+			BOMRef:  fmt.Sprintf("pkg-%d", i+1),
+			Name:    p.Name,
+			Version: p.Version,
+			PURL:    p.PURL,
+		}
+		if p.PURL != "" {
+			c.BOMRef = p.PURL
+		}
+		if p.License != "" {
+			c.Licenses = []license{{Expression: p.License}}
+		}
+		doc.Components = append(doc.Components, c)
+	}
+	dt, err := json.MarshalIndent(doc, "", "  ")
+	return dt, errors.WithStack(err)
+}
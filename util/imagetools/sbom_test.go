@@ -0,0 +1,109 @@
+package imagetools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/containerd/platforms"
+	"github.com/stretchr/testify/require"
+)
+
+const testSPDX = `{"packages":[
+	{"name":"openssl","versionInfo":"3.1.4","licenseConcluded":"Apache-2.0","externalRefs":[{"referenceType":"purl","referenceLocator":"pkg:apk/alpine/openssl@3.1.4"}]},
+	{"name":"zlib","versionInfo":"1.3","licenseConcluded":"NOASSERTION","licenseDeclared":"Zlib"},
+	{"name":"busybox","versionInfo":"1.36","licenseConcluded":"GPL-2.0-only"},
+	{"name":"musl","versionInfo":"1.2.4","licenseConcluded":"NOASSERTION"}
+]}`
+
+func TestResolverSBOM(t *testing.T) {
+	dir := t.TempDir()
+	idx := writeTestLayoutWith(t, dir, `{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`, testSPDX)
+
+	r := New(Opt{})
+	s, err := r.SBOM(context.TODO(), "oci-layout://"+dir, nil)
+	require.NoError(t, err)
+	require.Equal(t, idx.Digest, s.Digest)
+	require.Equal(t, []string{"linux/amd64"}, s.Platforms)
+	require.Equal(t, []SBOMPackage{
+		{Name: "busybox", Version: "1.36", License: "GPL-2.0-only"},
+		{Name: "musl", Version: "1.2.4"},
+		{Name: "openssl", Version: "3.1.4", Type: "apk", PURL: "pkg:apk/alpine/openssl@3.1.4", License: "Apache-2.0"},
+		{Name: "zlib", Version: "1.3", License: "Zlib"},
+	}, s.Packages)
+	require.Equal(t, []LicenseCount{
+		{License: "Apache-2.0", Packages: 1},
+		{License: "GPL-2.0-only", Packages: 1},
+		{License: "Zlib", Packages: 1},
+		{License: "unknown", Packages: 1},
+	}, s.Licenses())
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, s.PrintTable(buf))
+	require.Contains(t, buf.String(), "openssl   3.1.4     apk    Apache-2.0\n")
+	require.Contains(t, buf.String(), "LICENSE        PACKAGES\n")
+
+	arm64 := platforms.MustParse("linux/arm64")
+	_, err = r.SBOM(context.TODO(), "oci-layout://"+dir, &arm64)
+	require.ErrorContains(t, err, "no SBOM attestation found")
+
+	dt, err := s.SPDX()
+	require.NoError(t, err)
+	var spdx struct {
+		SPDXVersion string `json:"spdxVersion"`
+		Packages    []struct {
+			Name             string `json:"name"`
+			LicenseConcluded string `json:"licenseConcluded"`
+		} `json:"packages"`
+	}
+	require.NoError(t, json.Unmarshal(dt, &spdx))
+	require.Equal(t, "SPDX-2.3", spdx.SPDXVersion)
+	require.Len(t, spdx.Packages, 4)
+	require.Equal(t, "NOASSERTION", spdx.Packages[1].LicenseConcluded)
+
+	dt, err = s.CycloneDX()
+	require.NoError(t, err)
+	var cdx struct {
+		BOMFormat  string `json:"bomFormat"`
+		Components []struct {
+			Name     string `json:"name"`
+			PURL     string `json:"purl"`
+			Licenses []struct {
+				Expression string `json:"expression"`
+			} `json:"licenses"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(dt, &cdx))
+	require.Equal(t, "CycloneDX", cdx.BOMFormat)
+	require.Len(t, cdx.Components, 4)
+	require.Equal(t, "pkg:apk/alpine/openssl@3.1.4", cdx.Components[2].PURL)
+	require.Equal(t, "Apache-2.0", cdx.Components[2].Licenses[0].Expression)
+	require.Empty(t, cdx.Components[1].Licenses)
+}
+
+func TestSBOMDiff(t *testing.T) {
+	from, to := t.TempDir(), t.TempDir()
+	writeTestLayoutWith(t, from, `{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`, testSPDX)
+	writeTestLayoutWith(t, to, `{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`,
+		`{"packages":[{"name":"openssl","versionInfo":"3.1.5"},{"name":"zlib","versionInfo":"1.3"},{"name":"curl","versionInfo":"8.5.0"}]}`)
+
+	r := New(Opt{})
+	fromSBOM, err := r.SBOM(context.TODO(), "oci-layout://"+from, nil)
+	require.NoError(t, err)
+	toSBOM, err := r.SBOM(context.TODO(), "oci-layout://"+to, nil)
+	require.NoError(t, err)
+
+	d := fromSBOM.Diff(toSBOM)
+	require.Equal(t, &PackageDiff{
+		Added:   []Package{{Name: "curl", Version: "8.5.0"}},
+		Removed: []Package{{Name: "busybox", Version: "1.36"}, {Name: "musl", Version: "1.2.4"}},
+		Changed: []PackageChange{{Name: "openssl", From: "3.1.4", To: "3.1.5"}},
+	}, d.Packages)
+	require.Nil(t, fromSBOM.Diff(fromSBOM).Packages)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, d.Print(buf))
+	require.Contains(t, buf.String(), "\nPackages:\n  + curl 8.5.0\n")
+	require.Contains(t, buf.String(), "  ~ openssl 3.1.4 -> 3.1.5\n")
+}
@@ -0,0 +1,291 @@
+package imagetools
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+
+	"github.com/containerd/errdefs"
+	"github.com/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+const (
+	cosignSignatureMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	cosignSignatureType      = "cosign container image signature"
+
+	annotationCosignSignature   = "dev.cosignproject.cosign/signature"
+	annotationCosignCertificate = "dev.sigstore.cosign/certificate"
+	annotationCosignChain       = "dev.sigstore.cosign/chain"
+	annotationCosignBundle      = "dev.sigstore.cosign/bundle"
+)
+
+// Signer signs the payloads of cosign-compatible image signatures.
+type Signer interface {
+	// Sign returns the annotations of the signature layer of payload, holding
+	// the signature itself and the material needed to verify it.
+	Sign(ctx context.Context, payload []byte) (map[string]string, error)
+}
+
+// SignatureStatus is the result of the verification of a signature.
+type SignatureStatus string
+
+const (
+	// SignatureVerified is a signature made by the verification key.
+	SignatureVerified SignatureStatus = "verified"
+	// SignatureUnverified is a valid signature that could not be checked
+	// against a verification key.
+	SignatureUnverified SignatureStatus = "unverified"
+	// SignatureInvalid is a signature that doesn't match its payload, or a
+	// payload that doesn't refer to the signed image.
+	SignatureInvalid SignatureStatus = "invalid"
+)
+
+// Signature is a cosign signature of an image manifest. Keyless signatures
+// are never verified, as their certificate and transparency log entry aren't
+// checked.
+type Signature struct {
+	Digest   digest.Digest   `json:"digest"`
+	Identity string          `json:"identity,omitempty"`
+	Keyless  bool            `json:"keyless,omitempty"`
+	Status   SignatureStatus `json:"status"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// simpleSigning is the payload of cosign image signatures, based on the
+// containers/image simple signing format.
+type simpleSigning struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest digest.Digest `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+	Optional map[string]interface{} `json:"optional"`
+}
+
+// Sign signs the manifest dgst in the repository of ref and pushes the
+// signature with the cosign tag schema, along with the existing signatures of
+// the manifest.
+func (r *Resolver) Sign(ctx context.Context, ref reference.Named, dgst digest.Digest, s Signer) (ocispec.Descriptor, error) {
+	ctx = withDiscardLogger(ctx)
+
+	var payload simpleSigning
+	payload.Critical.Identity.DockerReference = reference.TrimNamed(ref).String()
+	payload.Critical.Image.DockerManifestDigest = dgst
+	payload.Critical.Type = cosignSignatureType
+	dt, err := json.Marshal(payload)
+	if err != nil {
+		return ocispec.Descriptor{}, errors.WithStack(err)
+	}
+
+	annotations, err := s.Sign(ctx, dt)
+	if err != nil {
+		return ocispec.Descriptor{}, errors.Wrapf(err, "failed to sign %s", dgst)
+	}
+	layer := ocispec.Descriptor{
+		MediaType:   cosignSignatureMediaType,
+		Digest:      digest.FromBytes(dt),
+		Size:        int64(len(dt)),
+		Annotations: annotations,
+	}
+
+	tag, err := reference.WithTag(reference.TrimNamed(ref), referrersTag(dgst)+".sig")
+	if err != nil {
+		return ocispec.Descriptor{}, errors.WithStack(err)
+	}
+	mfst, err := r.signatureManifest(ctx, tag)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if mfst == nil {
+		mfst = &ocispec.Manifest{
+			Versioned: specs.Versioned{SchemaVersion: 2},
+			MediaType: ocispec.MediaTypeImageManifest,
+		}
+	}
+	mfst.Layers = append(mfst.Layers, layer)
+
+	config := ocispec.Image{
+		RootFS: ocispec.RootFS{Type: "layers"},
+	}
+	for _, l := range mfst.Layers {
+		config.RootFS.DiffIDs = append(config.RootFS.DiffIDs, l.Digest)
+	}
+	configDt, err := json.Marshal(config)
+	if err != nil {
+		return ocispec.Descriptor{}, errors.WithStack(err)
+	}
+	mfst.Config = ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageConfig,
+		Digest:    digest.FromBytes(configDt),
+		Size:      int64(len(configDt)),
+	}
+	mfstDt, err := json.Marshal(mfst)
+	if err != nil {
+		return ocispec.Descriptor{}, errors.WithStack(err)
+	}
+	desc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Digest:    digest.FromBytes(mfstDt),
+		Size:      int64(len(mfstDt)),
+	}
+
+	if err := r.push(ctx, tag, layer, dt); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if err := r.push(ctx, tag, mfst.Config, configDt); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if err := r.push(ctx, tag, desc, mfstDt); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	return desc, nil
+}
+
+// Signatures returns the cosign signatures of the manifest dgst in the
+// repository of ref. Signatures are verified with key if set.
+func (r *Resolver) Signatures(ctx context.Context, ref reference.Named, dgst digest.Digest, key crypto.PublicKey) ([]Signature, error) {
+	ctx = withDiscardLogger(ctx)
+
+	tag, err := reference.WithTag(reference.TrimNamed(ref), referrersTag(dgst)+".sig")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	mfst, err := r.signatureManifest(ctx, tag)
+	if err != nil || mfst == nil {
+		return nil, err
+	}
+
+	sigs := make([]Signature, 0, len(mfst.Layers))
+	for _, l := range mfst.Layers {
+		if l.MediaType != cosignSignatureMediaType {
+			continue
+		}
+		dt, err := r.GetDescriptor(ctx, tag.String(), l)
+		if err != nil {
+			return nil, err
+		}
+		sigs = append(sigs, verifySignature(l, dt, dgst, key))
+	}
+	return sigs, nil
+}
+
+func (r *Resolver) signatureManifest(ctx context.Context, tag reference.Named) (*ocispec.Manifest, error) {
+	_, desc, err := r.Resolve(ctx, tag.String())
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	dt, err := r.GetDescriptor(ctx, tag.String(), desc)
+	if err != nil {
+		return nil, err
+	}
+	var mfst ocispec.Manifest
+	if err := json.Unmarshal(dt, &mfst); err != nil {
+		return nil, errors.Wrapf(err, "invalid signature manifest %s", tag)
+	}
+	return &mfst, nil
+}
+
+func verifySignature(layer ocispec.Descriptor, payload []byte, dgst digest.Digest, key crypto.PublicKey) Signature {
+	sig := Signature{
+		Digest:  layer.Digest,
+		Keyless: layer.Annotations[annotationCosignCertificate] != "",
+		Status:  SignatureUnverified,
+	}
+	invalid := func(err error) Signature {
+		sig.Status = SignatureInvalid
+		sig.Error = err.Error()
+		return sig
+	}
+
+	var ss simpleSigning
+	if err := json.Unmarshal(payload, &ss); err != nil {
+		return invalid(errors.Wrap(err, "invalid payload"))
+	}
+	sig.Identity = ss.Critical.Identity.DockerReference
+	if ss.Critical.Image.DockerManifestDigest != dgst {
+		return invalid(errors.Errorf("payload refers to %s", ss.Critical.Image.DockerManifestDigest))
+	}
+
+	sigDt, err := base64.StdEncoding.DecodeString(layer.Annotations[annotationCosignSignature])
+	if err != nil || len(sigDt) == 0 {
+		return invalid(errors.New("missing signature"))
+	}
+
+	if sig.Keyless {
+		sig.Error = "keyless signatures are not verified"
+		return sig
+	}
+	if key == nil {
+		return sig
+	}
+	if err := verifyPayload(key, payload, sigDt); err != nil {
+		sig.Error = "not signed by the verification key"
+		return sig
+	}
+	sig.Status = SignatureVerified
+	return sig
+}
+
+func verifyPayload(pub crypto.PublicKey, payload, sig []byte) error {
+	h := sha256.Sum256(payload)
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(k, h[:], sig) {
+			return errors.New("signature mismatch")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, h[:], sig); err != nil {
+			return errors.New("signature mismatch")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, payload, sig) {
+			return errors.New("signature mismatch")
+		}
+	default:
+		return errors.Errorf("unsupported public key type %T", pub)
+	}
+	return nil
+}
+
+// LoadPublicKey loads a PEM public key or certificate to verify signatures
+// with, like the cosign.pub file of a cosign key pair.
+func LoadPublicKey(dt []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(dt)
+	if block == nil {
+		return nil, errors.New("invalid public key, no PEM data found")
+	}
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid public key")
+		}
+		return key, nil
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid certificate")
+		}
+		return cert.PublicKey, nil
+	default:
+		return nil, errors.Errorf("unsupported PEM block %q for public key", block.Type)
+	}
+}
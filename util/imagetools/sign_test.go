@@ -0,0 +1,214 @@
+package imagetools
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignKey(t *testing.T) {
+	reg := newTestRegistry(t)
+	r := New(Opt{})
+	ctx := context.TODO()
+
+	img := reg.pushImage(t, "app", "latest")
+	key, pub := testSigningKey(t)
+	_, otherPub := testSigningKey(t)
+
+	s, err := NewKeySigner(key, "")
+	require.NoError(t, err)
+	_, err = r.Sign(ctx, reg.ref(t, "app"), img.Digest, s)
+	require.NoError(t, err)
+	_, err = r.Sign(ctx, reg.ref(t, "app"), img.Digest, s)
+	require.NoError(t, err)
+	require.True(t, reg.hasManifest("app", referrersTag(img.Digest)+".sig"))
+
+	sigs, err := r.Signatures(ctx, reg.ref(t, "app"), img.Digest, pub)
+	require.NoError(t, err)
+	require.Len(t, sigs, 2)
+	for _, sig := range sigs {
+		require.Equal(t, SignatureVerified, sig.Status)
+		require.Equal(t, reg.ref(t, "app").String(), sig.Identity)
+		require.False(t, sig.Keyless)
+	}
+
+	sigs, err = r.Signatures(ctx, reg.ref(t, "app"), img.Digest, otherPub)
+	require.NoError(t, err)
+	require.Len(t, sigs, 2)
+	require.Equal(t, SignatureUnverified, sigs[0].Status)
+	require.Equal(t, "not signed by the verification key", sigs[0].Error)
+
+	p, err := NewPrinter(ctx, Opt{}, reg.ref(t, "app").String(), "")
+	require.NoError(t, err)
+	buf := &bytes.Buffer{}
+	require.NoError(t, p.Print(false, buf))
+	require.NotContains(t, buf.String(), "Signatures:")
+
+	p.SetVerifyKey(pub)
+	buf.Reset()
+	require.NoError(t, p.Print(false, buf))
+	require.Contains(t, buf.String(), "Signatures:")
+	require.Contains(t, buf.String(), "verified")
+
+	other := reg.pushImage(t, "other", "latest")
+	sigs, err = r.Signatures(ctx, reg.ref(t, "other"), other.Digest, pub)
+	require.NoError(t, err)
+	require.Empty(t, sigs)
+}
+
+func TestSignKeyless(t *testing.T) {
+	reg := newTestRegistry(t)
+	r := New(Opt{})
+	ctx := context.TODO()
+
+	img := reg.pushImage(t, "app", "latest")
+	fulcio := newTestFulcio(t)
+	var rekorEntries int
+	rekor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		require.Equal(t, "/api/v1/log/entries", req.URL.Path)
+		rekorEntries++
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"abc": map[string]interface{}{
+				"body":           "e30=",
+				"integratedTime": 1,
+				"logID":          "01",
+				"logIndex":       2,
+				"verification":   map[string]string{"signedEntryTimestamp": "c2V0"},
+			},
+		})
+	}))
+	t.Cleanup(rekor.Close)
+
+	s := NewKeylessSigner(fulcio.URL, rekor.URL).(*keylessSigner)
+	s.token = func(context.Context) (string, error) {
+		claims := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"1234","email":"dev@example.com"}`))
+		return "e30." + claims + ".sig", nil
+	}
+	_, err := r.Sign(ctx, reg.ref(t, "app"), img.Digest, s)
+	require.NoError(t, err)
+	require.Equal(t, 1, rekorEntries)
+
+	sigs, err := r.Signatures(ctx, reg.ref(t, "app"), img.Digest, nil)
+	require.NoError(t, err)
+	require.Len(t, sigs, 1)
+	require.Equal(t, SignatureUnverified, sigs[0].Status)
+	require.Equal(t, "keyless signatures are not verified", sigs[0].Error)
+	require.True(t, sigs[0].Keyless)
+	require.Equal(t, reg.ref(t, "app").String(), sigs[0].Identity)
+
+	p, err := NewPrinter(ctx, Opt{}, reg.ref(t, "app").String(), "")
+	require.NoError(t, err)
+	_, pub := testSigningKey(t)
+	p.SetVerifyKey(pub)
+	buf := &bytes.Buffer{}
+	require.NoError(t, p.Print(false, buf))
+	require.Contains(t, buf.String(), "unverified (keyless signatures are not verified)")
+}
+
+func TestVerifySignature(t *testing.T) {
+	key, pub := testSigningKey(t)
+	s, err := NewKeySigner(key, "")
+	require.NoError(t, err)
+
+	payload := []byte(`{"critical":{"identity":{"docker-reference":"docker.io/library/app"},"image":{"docker-manifest-digest":"` + digest.FromString("other").String() + `"},"type":"cosign container image signature"},"optional":null}`)
+	annotations, err := s.Sign(context.TODO(), payload)
+	require.NoError(t, err)
+	layer := ocispec.Descriptor{Digest: digest.FromBytes(payload), Annotations: annotations}
+
+	sig := verifySignature(layer, payload, digest.FromString("other"), pub)
+	require.Equal(t, SignatureVerified, sig.Status)
+
+	sig = verifySignature(layer, payload, digest.FromString("image"), pub)
+	require.Equal(t, SignatureInvalid, sig.Status)
+	require.Contains(t, sig.Error, "payload refers to")
+
+	layer.Annotations = map[string]string{annotationCosignSignature: base64.StdEncoding.EncodeToString([]byte("bogus"))}
+	sig = verifySignature(layer, payload, digest.FromString("other"), pub)
+	require.Equal(t, SignatureUnverified, sig.Status)
+
+	_, err = NewKeySigner(pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED SIGSTORE PRIVATE KEY", Bytes: []byte("x")}), "")
+	require.ErrorContains(t, err, "encrypted cosign keys are not supported")
+}
+
+func testSigningKey(t *testing.T) ([]byte, *ecdsa.PublicKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	dt, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: dt}), &key.PublicKey
+}
+
+// newTestFulcio returns a Fulcio server issuing certificates from a test CA
+// for the email of the identity token.
+func newTestFulcio(t *testing.T) *httptest.Server {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDt, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDt)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		require.Equal(t, "/api/v2/signingCert", req.URL.Path)
+		var in struct {
+			PublicKeyRequest struct {
+				PublicKey struct {
+					Content string `json:"content"`
+				} `json:"publicKey"`
+			} `json:"publicKeyRequest"`
+		}
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&in))
+		block, _ := pem.Decode([]byte(in.PublicKeyRequest.PublicKey.Content))
+		require.NotNil(t, block)
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		require.NoError(t, err)
+
+		dt, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+			SerialNumber:   big.NewInt(2),
+			NotBefore:      time.Now().Add(-time.Minute),
+			NotAfter:       time.Now().Add(10 * time.Minute),
+			EmailAddresses: []string{"dev@example.com"},
+			KeyUsage:       x509.KeyUsageDigitalSignature,
+		}, ca, pub, caKey)
+		require.NoError(t, err)
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"signedCertificateEmbeddedSct": map[string]interface{}{
+				"chain": map[string]interface{}{
+					"certificates": []string{
+						string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: dt})),
+						string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})),
+					},
+				},
+			},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
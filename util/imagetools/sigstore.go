@@ -0,0 +1,369 @@
+package imagetools
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/moby/buildkit/util/tracing"
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultFulcioURL = "https://fulcio.sigstore.dev"
+	DefaultRekorURL  = "https://rekor.sigstore.dev"
+)
+
+// keySigner signs with a local ECDSA private key, optionally recording the
+// signatures in a Rekor transparency log.
+type keySigner struct {
+	key      *ecdsa.PrivateKey
+	rekorURL string
+}
+
+// NewKeySigner returns a signer for the unencrypted PEM ECDSA private key dt.
+// Signatures are uploaded to the Rekor transparency log at rekorURL if set.
+func NewKeySigner(dt []byte, rekorURL string) (Signer, error) {
+	key, err := parseSigningKey(dt)
+	if err != nil {
+		return nil, err
+	}
+	return &keySigner{key: key, rekorURL: rekorURL}, nil
+}
+
+func (s *keySigner) Sign(ctx context.Context, payload []byte) (map[string]string, error) {
+	sig, err := signPayload(s.key, payload)
+	if err != nil {
+		return nil, err
+	}
+	annotations := map[string]string{
+		annotationCosignSignature: base64.StdEncoding.EncodeToString(sig),
+	}
+	if s.rekorURL == "" {
+		return annotations, nil
+	}
+
+	pubDt, err := x509.MarshalPKIXPublicKey(s.key.Public())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	bundle, err := uploadRekor(ctx, s.rekorURL, payload, sig, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDt}))
+	if err != nil {
+		return nil, err
+	}
+	annotations[annotationCosignBundle] = bundle
+	return annotations, nil
+}
+
+// keylessSigner signs with an ephemeral key certified by Fulcio for the
+// identity of an OIDC token, and records the signatures in Rekor.
+type keylessSigner struct {
+	fulcioURL string
+	rekorURL  string
+	token     func(context.Context) (string, error)
+}
+
+// NewKeylessSigner returns a signer getting signing certificates from the
+// Fulcio instance at fulcioURL and recording signatures in the Rekor
+// transparency log at rekorURL. The OIDC identity token is read from the
+// SIGSTORE_ID_TOKEN environment variable or requested from GitHub Actions.
+func NewKeylessSigner(fulcioURL, rekorURL string) Signer {
+	if fulcioURL == "" {
+		fulcioURL = DefaultFulcioURL
+	}
+	if rekorURL == "" {
+		rekorURL = DefaultRekorURL
+	}
+	return &keylessSigner{
+		fulcioURL: fulcioURL,
+		rekorURL:  rekorURL,
+		token:     identityToken,
+	}
+}
+
+func (s *keylessSigner) Sign(ctx context.Context, payload []byte) (map[string]string, error) {
+	token, err := s.token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	chain, err := fulcioCertificate(ctx, s.fulcioURL, key, token)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := signPayload(key, payload)
+	if err != nil {
+		return nil, err
+	}
+	bundle, err := uploadRekor(ctx, s.rekorURL, payload, sig, []byte(chain[0]))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		annotationCosignSignature:   base64.StdEncoding.EncodeToString(sig),
+		annotationCosignCertificate: chain[0],
+		annotationCosignChain:       strings.Join(chain[1:], ""),
+		annotationCosignBundle:      bundle,
+	}, nil
+}
+
+func signPayload(key *ecdsa.PrivateKey, payload []byte) ([]byte, error) {
+	h := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, h[:])
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return sig, nil
+}
+
+func parseSigningKey(dt []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(dt)
+	if block == nil {
+		return nil, errors.New("invalid signing key, no PEM data found")
+	}
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid signing key")
+		}
+		return key, nil
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid signing key")
+		}
+		ecKey, ok := key.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, errors.Errorf("unsupported signing key type %T, only ECDSA keys are supported", key)
+		}
+		return ecKey, nil
+	case "ENCRYPTED COSIGN PRIVATE KEY", "ENCRYPTED SIGSTORE PRIVATE KEY":
+		return nil, errors.New("encrypted cosign keys are not supported, use an unencrypted PEM ECDSA key")
+	default:
+		return nil, errors.Errorf("unsupported PEM block %q for signing key", block.Type)
+	}
+}
+
+// identityToken returns the OIDC identity token to get a signing certificate
+// for.
+func identityToken(ctx context.Context) (string, error) {
+	if v := os.Getenv("SIGSTORE_ID_TOKEN"); v != "" {
+		return v, nil
+	}
+	reqURL, reqToken := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL"), os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")
+	if reqURL == "" || reqToken == "" {
+		return "", errors.New("keyless signing requires an OIDC identity token, set SIGSTORE_ID_TOKEN or run in GitHub Actions with id-token permission")
+	}
+
+	u, err := url.Parse(reqURL)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid ACTIONS_ID_TOKEN_REQUEST_URL")
+	}
+	q := u.Query()
+	q.Set("audience", "sigstore")
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+reqToken)
+	var resp struct {
+		Value string `json:"value"`
+	}
+	if err := doJSON(req, http.StatusOK, &resp); err != nil {
+		return "", errors.Wrap(err, "failed to get GitHub Actions identity token")
+	}
+	return resp.Value, nil
+}
+
+// tokenSubject returns the identity of an OIDC token that Fulcio requires a
+// proof of possession of the signing key for.
+func tokenSubject(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errors.New("invalid identity token")
+	}
+	dt, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", errors.Wrap(err, "invalid identity token")
+	}
+	var claims struct {
+		Subject string `json:"sub"`
+		Email   string `json:"email"`
+	}
+	if err := json.Unmarshal(dt, &claims); err != nil {
+		return "", errors.Wrap(err, "invalid identity token")
+	}
+	if claims.Email != "" {
+		return claims.Email, nil
+	}
+	if claims.Subject == "" {
+		return "", errors.New("identity token has no subject")
+	}
+	return claims.Subject, nil
+}
+
+// fulcioCertificate requests a signing certificate for key and returns its
+// PEM chain, starting with the certificate itself.
+func fulcioCertificate(ctx context.Context, fulcioURL string, key *ecdsa.PrivateKey, token string) ([]string, error) {
+	subject, err := tokenSubject(token)
+	if err != nil {
+		return nil, err
+	}
+	proof, err := signPayload(key, []byte(subject))
+	if err != nil {
+		return nil, err
+	}
+	pubDt, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var in struct {
+		Credentials struct {
+			OIDCIdentityToken string `json:"oidcIdentityToken"`
+		} `json:"credentials"`
+		PublicKeyRequest struct {
+			PublicKey struct {
+				Algorithm string `json:"algorithm"`
+				Content   string `json:"content"`
+			} `json:"publicKey"`
+			ProofOfPossession []byte `json:"proofOfPossession"`
+		} `json:"publicKeyRequest"`
+	}
+	in.Credentials.OIDCIdentityToken = token
+	in.PublicKeyRequest.PublicKey.Algorithm = "ECDSA"
+	in.PublicKeyRequest.PublicKey.Content = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDt}))
+	in.PublicKeyRequest.ProofOfPossession = proof
+
+	req, err := newJSONRequest(ctx, strings.TrimSuffix(fulcioURL, "/")+"/api/v2/signingCert", in)
+	if err != nil {
+		return nil, err
+	}
+	type chain struct {
+		Chain struct {
+			Certificates []string `json:"certificates"`
+		} `json:"chain"`
+	}
+	var out struct {
+		EmbeddedSct *chain `json:"signedCertificateEmbeddedSct"`
+		DetachedSct *chain `json:"signedCertificateDetachedSct"`
+	}
+	if err := doJSON(req, http.StatusOK, &out); err != nil {
+		return nil, errors.Wrap(err, "failed to get signing certificate from Fulcio")
+	}
+	c := out.EmbeddedSct
+	if c == nil {
+		c = out.DetachedSct
+	}
+	if c == nil || len(c.Chain.Certificates) == 0 {
+		return nil, errors.New("no signing certificate returned by Fulcio")
+	}
+	return c.Chain.Certificates, nil
+}
+
+// uploadRekor records a signature made with the PEM public key or
+// certificate pub in the Rekor transparency log. It returns the cosign bundle
+// annotation proving the inclusion.
+func uploadRekor(ctx context.Context, rekorURL string, payload, sig, pub []byte) (string, error) {
+	h := sha256.Sum256(payload)
+	entry := map[string]interface{}{
+		"apiVersion": "0.0.1",
+		"kind":       "hashedrekord",
+		"spec": map[string]interface{}{
+			"data": map[string]interface{}{
+				"hash": map[string]string{
+					"algorithm": "sha256",
+					"value":     hex.EncodeToString(h[:]),
+				},
+			},
+			"signature": map[string]interface{}{
+				"content": base64.StdEncoding.EncodeToString(sig),
+				"publicKey": map[string]string{
+					"content": base64.StdEncoding.EncodeToString(pub),
+				},
+			},
+		},
+	}
+	req, err := newJSONRequest(ctx, strings.TrimSuffix(rekorURL, "/")+"/api/v1/log/entries", entry)
+	if err != nil {
+		return "", err
+	}
+	var out map[string]struct {
+		Body           string `json:"body"`
+		IntegratedTime int64  `json:"integratedTime"`
+		LogID          string `json:"logID"`
+		LogIndex       int64  `json:"logIndex"`
+		Verification   struct {
+			SignedEntryTimestamp string `json:"signedEntryTimestamp"`
+		} `json:"verification"`
+	}
+	if err := doJSON(req, http.StatusCreated, &out); err != nil {
+		return "", errors.Wrap(err, "failed to upload signature to Rekor")
+	}
+	for _, e := range out {
+		dt, err := json.Marshal(map[string]interface{}{
+			"SignedEntryTimestamp": e.Verification.SignedEntryTimestamp,
+			"Payload": map[string]interface{}{
+				"body":           e.Body,
+				"integratedTime": e.IntegratedTime,
+				"logIndex":       e.LogIndex,
+				"logID":          e.LogID,
+			},
+		})
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		return string(dt), nil
+	}
+	return "", errors.New("no log entry returned by Rekor")
+}
+
+func newJSONRequest(ctx context.Context, u string, v interface{}) (*http.Request, error) {
+	dt, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(dt))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+func doJSON(req *http.Request, status int, v interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := tracing.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	dt, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != status {
+		return errors.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(dt)))
+	}
+	return errors.WithStack(json.Unmarshal(dt, v))
+}
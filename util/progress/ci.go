@@ -0,0 +1,270 @@
+package progress
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/solver/errdefs"
+	"github.com/moby/buildkit/util/progress/progressui"
+	"github.com/opencontainers/go-digest"
+)
+
+// CIMode prints the output of each build step in a collapsible group of the
+// CI provider detected from the environment, and reports warnings and errors
+// as annotations pointing at their source. GitHub Actions and GitLab CI are
+// supported; other environments fall back to progressui.PlainMode.
+const CIMode progressui.DisplayMode = "ci"
+
+type ciProvider interface {
+	startGroup(w io.Writer, id string, title string)
+	endGroup(w io.Writer, id string)
+	annotate(w io.Writer, level string, file string, line int32, title string, msg string)
+}
+
+func detectCIProvider() ciProvider {
+	if os.Getenv("GITHUB_ACTIONS") == "true" {
+		return &githubActions{}
+	}
+	if os.Getenv("GITLAB_CI") == "true" {
+		return &gitlabCI{}
+	}
+	return nil
+}
+
+// githubActions implements ciProvider with workflow commands.
+// https://docs.github.com/en/actions/writing-workflows/choosing-what-your-workflow-does/workflow-commands-for-github-actions
+type githubActions struct{}
+
+func (githubActions) startGroup(w io.Writer, _ string, title string) {
+	fmt.Fprintf(w, "::group::%s\n", escapeGitHubData(title))
+}
+
+func (githubActions) endGroup(w io.Writer, _ string) {
+	fmt.Fprintln(w, "::endgroup::")
+}
+
+func (githubActions) annotate(w io.Writer, level string, file string, line int32, title string, msg string) {
+	var props []string
+	if file != "" {
+		props = append(props, "file="+escapeGitHubProperty(file))
+		if line > 0 {
+			props = append(props, fmt.Sprintf("line=%d", line))
+		}
+	}
+	if title != "" {
+		props = append(props, "title="+escapeGitHubProperty(title))
+	}
+	cmd := "::" + level
+	if len(props) > 0 {
+		cmd += " " + strings.Join(props, ",")
+	}
+	fmt.Fprintf(w, "%s::%s\n", cmd, escapeGitHubData(msg))
+}
+
+func escapeGitHubData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func escapeGitHubProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// gitlabCI implements ciProvider with collapsible sections. GitLab has no
+// annotations in job logs, so they are printed as highlighted lines.
+// https://docs.gitlab.com/ee/ci/jobs/job_logs.html#custom-collapsible-sections
+type gitlabCI struct{}
+
+func (gitlabCI) startGroup(w io.Writer, id string, title string) {
+	fmt.Fprintf(w, "\x1b[0Ksection_start:%d:%s[collapsed=true]\r\x1b[0K%s\n", time.Now().Unix(), id, title)
+}
+
+func (gitlabCI) endGroup(w io.Writer, id string) {
+	fmt.Fprintf(w, "\x1b[0Ksection_end:%d:%s\r\x1b[0K\n", time.Now().Unix(), id)
+}
+
+func (gitlabCI) annotate(w io.Writer, level string, file string, line int32, title string, msg string) {
+	color := "\x1b[33m"
+	if level == "error" {
+		color = "\x1b[31m"
+	}
+	var loc string
+	if file != "" {
+		loc = file
+		if line > 0 {
+			loc += fmt.Sprintf(":%d", line)
+		}
+		loc += ": "
+	}
+	if title != "" && title != msg {
+		msg = title + ": " + msg
+	}
+	fmt.Fprintf(w, "%s%s:\x1b[0m %s%s\n", color, strings.ToUpper(level), loc, msg)
+}
+
+type ciVertex struct {
+	name      string
+	started   *time.Time
+	completed bool
+	logs      bytes.Buffer
+}
+
+// ciDisplay buffers the logs of each build step and prints them in a group
+// once the step completes, so that the groups of concurrent steps do not
+// interleave.
+type ciDisplay struct {
+	mu       sync.Mutex
+	out      io.Writer
+	ci       ciProvider
+	vertexes map[digest.Digest]*ciVertex
+	order    []digest.Digest
+	groups   int
+}
+
+func newCIDisplay(out io.Writer, ci ciProvider) *ciDisplay {
+	return &ciDisplay{
+		out:      out,
+		ci:       ci,
+		vertexes: map[digest.Digest]*ciVertex{},
+	}
+}
+
+func (d *ciDisplay) UpdateFrom(ctx context.Context, ch chan *client.SolveStatus) ([]client.VertexWarning, error) {
+	var warnings []client.VertexWarning
+	defer func() {
+		d.done(warnings)
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		case ss, ok := <-ch:
+			if !ok {
+				return warnings, nil
+			}
+			d.update(ss)
+			for _, w := range ss.Warnings {
+				warnings = append(warnings, *w)
+			}
+		}
+	}
+}
+
+func (d *ciDisplay) update(ss *client.SolveStatus) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, v := range ss.Vertexes {
+		vtx, ok := d.vertexes[v.Digest]
+		if !ok {
+			vtx = &ciVertex{}
+			d.vertexes[v.Digest] = vtx
+			d.order = append(d.order, v.Digest)
+		}
+		if vtx.completed {
+			continue
+		}
+		vtx.name = v.Name
+		if v.Started != nil {
+			vtx.started = v.Started
+		}
+	}
+	for _, l := range ss.Logs {
+		if vtx, ok := d.vertexes[l.Vertex]; ok && !vtx.completed {
+			vtx.logs.Write(l.Data)
+		}
+	}
+	for _, v := range ss.Vertexes {
+		vtx := d.vertexes[v.Digest]
+		if vtx.completed || v.Completed == nil {
+			continue
+		}
+		vtx.completed = true
+
+		var status string
+		switch {
+		case v.Error != "":
+			status = "ERROR: " + v.Error
+		case v.Cached:
+			status = "CACHED"
+		case vtx.started != nil:
+			status = fmt.Sprintf("DONE %.1fs", v.Completed.Sub(*vtx.started).Seconds())
+		default:
+			status = "DONE"
+		}
+		d.printVertex(vtx, status)
+	}
+}
+
+func (d *ciDisplay) printVertex(vtx *ciVertex, status string) {
+	title := vtx.name + " " + status
+	if vtx.logs.Len() == 0 {
+		fmt.Fprintln(d.out, title)
+		return
+	}
+	d.groups++
+	id := fmt.Sprintf("buildx_step_%d", d.groups)
+	d.ci.startGroup(d.out, id, title)
+	d.out.Write(vtx.logs.Bytes())
+	if !bytes.HasSuffix(vtx.logs.Bytes(), []byte{'\n'}) {
+		fmt.Fprintln(d.out)
+	}
+	d.ci.endGroup(d.out, id)
+	vtx.logs.Reset()
+}
+
+func (d *ciDisplay) done(warnings []client.VertexWarning) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// steps that did not complete, e.g. because the build was canceled
+	for _, dgst := range d.order {
+		if vtx := d.vertexes[dgst]; !vtx.completed && vtx.started != nil {
+			vtx.completed = true
+			d.printVertex(vtx, "CANCELED")
+		}
+	}
+	for _, w := range dedupWarnings(warnings) {
+		var file string
+		var line int32
+		if w.SourceInfo != nil {
+			file = w.SourceInfo.Filename
+			if len(w.Range) > 0 && w.Range[0].Start != nil {
+				line = w.Range[0].Start.Line
+			}
+		}
+		msg := string(w.Short)
+		if len(w.Detail) > 0 {
+			msg = string(bytes.Join(w.Detail, []byte{'\n'}))
+		}
+		d.ci.annotate(d.out, "warning", file, line, string(w.Short), msg)
+	}
+}
+
+// writeError reports a build error at the location of its source, if any.
+func (d *ciDisplay) writeError(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var annotated bool
+	for _, s := range errdefs.Sources(err) {
+		if s.Info == nil {
+			continue
+		}
+		var line int32
+		if len(s.Ranges) > 0 && s.Ranges[0].Start != nil {
+			line = s.Ranges[0].Start.Line
+		}
+		d.ci.annotate(d.out, "error", s.Info.Filename, line, "", err.Error())
+		annotated = true
+	}
+	if !annotated {
+		d.ci.annotate(d.out, "error", "", 0, "", err.Error())
+	}
+}
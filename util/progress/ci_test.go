@@ -0,0 +1,130 @@
+package progress
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/solver/errdefs"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/moby/buildkit/util/progress/progressui"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCIDisplayGitHubActions(t *testing.T) {
+	buf := &bytes.Buffer{}
+	d := newCIDisplay(buf, &githubActions{})
+
+	ch := make(chan *client.SolveStatus)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := d.UpdateFrom(context.TODO(), ch)
+		require.NoError(t, err)
+	}()
+
+	run := digest.FromString("run")
+	from := digest.FromString("from")
+	started := time.Now()
+	completed := started.Add(1500 * time.Millisecond)
+
+	ch <- &client.SolveStatus{
+		Vertexes: []*client.Vertex{
+			{Digest: run, Name: "[2/2] RUN make", Started: &started},
+			{Digest: from, Name: "[1/2] FROM alpine", Started: &started, Completed: &started, Cached: true},
+		},
+		Logs: []*client.VertexLog{
+			{Vertex: run, Stream: 1, Data: []byte("compiling\n")},
+		},
+	}
+	ch <- &client.SolveStatus{
+		Vertexes: []*client.Vertex{
+			{Digest: run, Name: "[2/2] RUN make", Started: &started, Completed: &completed},
+		},
+		Logs: []*client.VertexLog{
+			{Vertex: run, Stream: 1, Data: []byte("done")},
+		},
+		Warnings: []*client.VertexWarning{{
+			Vertex:     run,
+			Short:      []byte("StageNameCasing: Stage name 'Build' should be lowercase"),
+			SourceInfo: &pb.SourceInfo{Filename: "Dockerfile"},
+			Range:      []*pb.Range{{Start: &pb.Position{Line: 2}}},
+		}},
+	}
+	close(ch)
+	<-done
+	d.writeError(errdefs.WithSource(errors.New("process did not complete successfully"), &errdefs.Source{
+		Info:   &pb.SourceInfo{Filename: "Dockerfile"},
+		Ranges: []*pb.Range{{Start: &pb.Position{Line: 4}}},
+	}))
+
+	require.Equal(t, `[1/2] FROM alpine CACHED
+::group::[2/2] RUN make DONE 1.5s
+compiling
+done
+::endgroup::
+::warning file=Dockerfile,line=2,title=StageNameCasing%3A Stage name 'Build' should be lowercase::StageNameCasing: Stage name 'Build' should be lowercase
+::error file=Dockerfile,line=4::process did not complete successfully
+`, buf.String())
+}
+
+func TestCIDisplayGitLab(t *testing.T) {
+	buf := &bytes.Buffer{}
+	d := newCIDisplay(buf, &gitlabCI{})
+
+	ch := make(chan *client.SolveStatus)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := d.UpdateFrom(context.TODO(), ch)
+		require.NoError(t, err)
+	}()
+
+	run := digest.FromString("run")
+	started := time.Now()
+	ch <- &client.SolveStatus{
+		Vertexes: []*client.Vertex{
+			{Digest: run, Name: "[1/1] RUN make", Started: &started},
+		},
+		Logs: []*client.VertexLog{
+			{Vertex: run, Stream: 2, Data: []byte("make: *** No targets.  Stop.\n")},
+		},
+	}
+	ch <- &client.SolveStatus{
+		Vertexes: []*client.Vertex{
+			{Digest: run, Name: "[1/1] RUN make", Started: &started, Completed: &started, Error: "exit code: 2"},
+		},
+	}
+	close(ch)
+	<-done
+
+	require.Contains(t, buf.String(), ":buildx_step_1[collapsed=true]\r\x1b[0K[1/1] RUN make ERROR: exit code: 2\n")
+	require.Contains(t, buf.String(), "make: *** No targets.  Stop.\n")
+	require.Contains(t, buf.String(), ":buildx_step_1\r\x1b[0K\n")
+}
+
+func TestPrinterDisplayModeCI(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "progress"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	t.Setenv("GITHUB_ACTIONS", "true")
+	p := &Printer{}
+	_, err = p.newDisplay(f, CIMode)
+	require.NoError(t, err)
+	require.Equal(t, CIMode, p.DisplayMode())
+
+	// falls back to the plain display outside of a CI provider
+	t.Setenv("GITHUB_ACTIONS", "")
+	t.Setenv("GITLAB_CI", "")
+	p = &Printer{}
+	_, err = p.newDisplay(f, CIMode)
+	require.NoError(t, err)
+	require.Equal(t, progressui.PlainMode, p.DisplayMode())
+}
@@ -0,0 +1,280 @@
+package progress
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/util/progress/progressui"
+	"github.com/opencontainers/go-digest"
+)
+
+// JSONMode prints one JSONEvent per line. Unlike progressui.RawJSONMode, the
+// events have a stable schema that does not depend on the BuildKit API.
+const JSONMode progressui.DisplayMode = "json"
+
+// JSONEventType is the type of a JSONEvent.
+type JSONEventType string
+
+const (
+	JSONEventVertexStarted   JSONEventType = "vertex.started"
+	JSONEventVertexCompleted JSONEventType = "vertex.completed"
+	JSONEventVertexCached    JSONEventType = "vertex.cached"
+	JSONEventVertexError     JSONEventType = "vertex.error"
+	JSONEventLog             JSONEventType = "log"
+	JSONEventWarning         JSONEventType = "warning"
+	JSONEventResult          JSONEventType = "result"
+)
+
+// JSONEvent is a progress event printed in JSONMode.
+type JSONEvent struct {
+	Time time.Time     `json:"time"`
+	Type JSONEventType `json:"type"`
+
+	// Target and Node are the build target and builder node the event
+	// belongs to, if known.
+	Target string `json:"target,omitempty"`
+	Node   string `json:"node,omitempty"`
+
+	// Vertex and Name identify the build step of vertex, log and warning
+	// events.
+	Vertex digest.Digest `json:"vertex,omitempty"`
+	Name   string        `json:"name,omitempty"`
+
+	// Duration is the duration of the build step in seconds for completed,
+	// cached and errored vertexes.
+	Duration float64 `json:"duration,omitempty"`
+	Error    string  `json:"error,omitempty"`
+
+	// Stream and Data are set on log events, one event per line.
+	Stream int    `json:"stream,omitempty"`
+	Data   string `json:"data,omitempty"`
+
+	// Warning is set on warning events.
+	Warning *JSONWarning `json:"warning,omitempty"`
+
+	// Result is the exporter response of the target on result events.
+	Result map[string]string `json:"result,omitempty"`
+}
+
+// JSONWarning is a build warning, such as a Dockerfile lint violation.
+type JSONWarning struct {
+	Level   int      `json:"level"`
+	Message string   `json:"message"`
+	Detail  []string `json:"detail,omitempty"`
+	URL     string   `json:"url,omitempty"`
+	File    string   `json:"file,omitempty"`
+	Line    int32    `json:"line,omitempty"`
+}
+
+type vertexTarget struct {
+	target string
+	node   string
+}
+
+type jsonVertex struct {
+	name      string
+	started   bool
+	completed bool
+}
+
+// jsonVertexKey identifies a vertex in the build of a target. The events of
+// a vertex shared by several targets are printed once for each of them.
+type jsonVertexKey struct {
+	dgst   digest.Digest
+	target vertexTarget
+}
+
+type logKey struct {
+	dgst   digest.Digest
+	stream int
+}
+
+type jsonDisplay struct {
+	mu       sync.Mutex
+	enc      *json.Encoder
+	targetOf func(digest.Digest) []vertexTarget
+	vertexes map[jsonVertexKey]*jsonVertex
+	partial  map[logKey][]byte
+}
+
+func newJSONDisplay(out io.Writer, targetOf func(digest.Digest) []vertexTarget) *jsonDisplay {
+	return &jsonDisplay{
+		enc:      json.NewEncoder(out),
+		targetOf: targetOf,
+		vertexes: map[jsonVertexKey]*jsonVertex{},
+		partial:  map[logKey][]byte{},
+	}
+}
+
+func (d *jsonDisplay) UpdateFrom(ctx context.Context, ch chan *client.SolveStatus) ([]client.VertexWarning, error) {
+	var warnings []client.VertexWarning
+	defer d.flush()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		case ss, ok := <-ch:
+			if !ok {
+				return warnings, nil
+			}
+			d.update(ss)
+			for _, w := range ss.Warnings {
+				warnings = append(warnings, *w)
+			}
+		}
+	}
+}
+
+func (d *jsonDisplay) update(ss *client.SolveStatus) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, v := range ss.Vertexes {
+		for _, t := range d.targets(v.Digest) {
+			k := jsonVertexKey{dgst: v.Digest, target: t}
+			vtx, ok := d.vertexes[k]
+			if !ok {
+				vtx = &jsonVertex{}
+				d.vertexes[k] = vtx
+			}
+			vtx.name = v.Name
+			if v.Started != nil && !vtx.started {
+				vtx.started = true
+				d.write(d.vertexEvent(JSONEventVertexStarted, v.Digest, t, *v.Started))
+			}
+			if v.Completed != nil && !vtx.completed {
+				vtx.completed = true
+				d.flushLogs(v.Digest)
+				ev := d.vertexEvent(JSONEventVertexCompleted, v.Digest, t, *v.Completed)
+				switch {
+				case v.Error != "":
+					ev.Type = JSONEventVertexError
+					ev.Error = v.Error
+				case v.Cached:
+					ev.Type = JSONEventVertexCached
+				}
+				if v.Started != nil {
+					ev.Duration = v.Completed.Sub(*v.Started).Seconds()
+				}
+				d.write(ev)
+			}
+		}
+	}
+	for _, l := range ss.Logs {
+		k := logKey{dgst: l.Vertex, stream: l.Stream}
+		dt := append(d.partial[k], l.Data...)
+		for {
+			i := bytes.IndexByte(dt, '\n')
+			if i < 0 {
+				break
+			}
+			d.writeLog(l.Vertex, l.Stream, string(bytes.TrimSuffix(dt[:i], []byte{'\r'})), l.Timestamp)
+			dt = dt[i+1:]
+		}
+		if len(dt) > 0 {
+			d.partial[k] = dt
+		} else {
+			delete(d.partial, k)
+		}
+	}
+	for _, w := range ss.Warnings {
+		jw := &JSONWarning{
+			Level:   w.Level,
+			Message: string(w.Short),
+			URL:     w.URL,
+		}
+		for _, dt := range w.Detail {
+			jw.Detail = append(jw.Detail, string(dt))
+		}
+		if w.SourceInfo != nil {
+			jw.File = w.SourceInfo.Filename
+			if len(w.Range) > 0 && w.Range[0].Start != nil {
+				jw.Line = w.Range[0].Start.Line
+			}
+		}
+		for _, t := range d.targets(w.Vertex) {
+			ev := d.vertexEvent(JSONEventWarning, w.Vertex, t, time.Now())
+			ev.Warning = jw
+			d.write(ev)
+		}
+	}
+}
+
+// writeResult prints the exporter response of a build target.
+func (d *jsonDisplay) writeResult(target string, resp map[string]string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.write(JSONEvent{
+		Time:   time.Now(),
+		Type:   JSONEventResult,
+		Target: target,
+		Result: resp,
+	})
+}
+
+// targets returns the targets of a vertex, or a single unknown target if
+// the vertex is not associated with a target.
+func (d *jsonDisplay) targets(dgst digest.Digest) []vertexTarget {
+	var targets []vertexTarget
+	if d.targetOf != nil {
+		targets = d.targetOf(dgst)
+	}
+	if len(targets) == 0 {
+		return []vertexTarget{{}}
+	}
+	return targets
+}
+
+func (d *jsonDisplay) vertexEvent(typ JSONEventType, dgst digest.Digest, t vertexTarget, tm time.Time) JSONEvent {
+	ev := JSONEvent{
+		Time:   tm,
+		Type:   typ,
+		Vertex: dgst,
+		Target: t.target,
+		Node:   t.node,
+	}
+	if vtx, ok := d.vertexes[jsonVertexKey{dgst: dgst, target: t}]; ok {
+		ev.Name = vtx.name
+	}
+	return ev
+}
+
+// writeLog prints a log line of a vertex for each of its targets.
+func (d *jsonDisplay) writeLog(dgst digest.Digest, stream int, data string, tm time.Time) {
+	for _, t := range d.targets(dgst) {
+		ev := d.vertexEvent(JSONEventLog, dgst, t, tm)
+		ev.Stream = stream
+		ev.Data = data
+		d.write(ev)
+	}
+}
+
+// flushLogs prints the pending log lines of a vertex that are not terminated
+// by a newline.
+func (d *jsonDisplay) flushLogs(dgst digest.Digest) {
+	for k, dt := range d.partial {
+		if k.dgst != dgst {
+			continue
+		}
+		d.writeLog(k.dgst, k.stream, string(dt), time.Now())
+		delete(d.partial, k)
+	}
+}
+
+func (d *jsonDisplay) flush() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for k := range d.partial {
+		d.flushLogs(k.dgst)
+	}
+}
+
+func (d *jsonDisplay) write(ev JSONEvent) {
+	// errors are ignored like in the other displays
+	_ = d.enc.Encode(ev)
+}
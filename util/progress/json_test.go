@@ -0,0 +1,154 @@
+package progress
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONDisplay(t *testing.T) {
+	buf := &bytes.Buffer{}
+	p := &Printer{}
+	d := newJSONDisplay(buf, p.vertexTargets)
+	w := WithTarget(p, "app", "builder0")
+	p.status = make(chan *client.SolveStatus)
+
+	run := make(chan struct{})
+	go func() {
+		defer close(run)
+		warnings, err := d.UpdateFrom(context.TODO(), p.status)
+		require.NoError(t, err)
+		require.Len(t, warnings, 1)
+	}()
+
+	dgst := digest.FromString("run")
+	cached := digest.FromString("cached")
+	started := time.Now()
+	completed := started.Add(2 * time.Second)
+
+	w.Write(&client.SolveStatus{
+		Vertexes: []*client.Vertex{
+			{Digest: dgst, Name: "[2/2] RUN make", Started: &started},
+			{Digest: cached, Name: "[1/2] FROM alpine", Started: &started, Completed: &started, Cached: true},
+		},
+	})
+	w.Write(&client.SolveStatus{
+		Logs: []*client.VertexLog{
+			{Vertex: dgst, Stream: 1, Data: []byte("line1\nli"), Timestamp: started},
+			{Vertex: dgst, Stream: 1, Data: []byte("ne2\npartial"), Timestamp: started},
+		},
+		Warnings: []*client.VertexWarning{{
+			Vertex:     dgst,
+			Level:      1,
+			Short:      []byte("JSONArgsRecommended"),
+			SourceInfo: &pb.SourceInfo{Filename: "Dockerfile"},
+			Range:      []*pb.Range{{Start: &pb.Position{Line: 3}}},
+		}},
+	})
+	w.Write(&client.SolveStatus{
+		Vertexes: []*client.Vertex{
+			{Digest: dgst, Name: "[2/2] RUN make", Started: &started, Completed: &completed, Error: "exit code: 2"},
+		},
+	})
+	close(p.status)
+	<-run
+	d.writeResult("app", map[string]string{"containerimage.digest": "sha256:abc"})
+
+	var events []JSONEvent
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var ev JSONEvent
+		require.NoError(t, dec.Decode(&ev))
+		events = append(events, ev)
+	}
+
+	var types []JSONEventType
+	for _, ev := range events {
+		types = append(types, ev.Type)
+	}
+	require.Equal(t, []JSONEventType{
+		JSONEventVertexStarted,
+		JSONEventVertexStarted,
+		JSONEventVertexCached,
+		JSONEventLog,
+		JSONEventLog,
+		JSONEventWarning,
+		JSONEventLog,
+		JSONEventVertexError,
+		JSONEventResult,
+	}, types)
+
+	require.Equal(t, "app", events[0].Target)
+	require.Equal(t, "builder0", events[0].Node)
+	require.Equal(t, dgst, events[0].Vertex)
+	require.Equal(t, "[2/2] RUN make", events[0].Name)
+
+	require.Equal(t, "line1", events[3].Data)
+	require.Equal(t, "line2", events[4].Data)
+	require.Equal(t, 1, events[4].Stream)
+
+	require.Equal(t, &JSONWarning{Level: 1, Message: "JSONArgsRecommended", File: "Dockerfile", Line: 3}, events[5].Warning)
+
+	require.Equal(t, "partial", events[6].Data)
+	require.Equal(t, "exit code: 2", events[7].Error)
+	require.Equal(t, 2.0, events[7].Duration)
+
+	require.Equal(t, "app", events[8].Target)
+	require.Equal(t, map[string]string{"containerimage.digest": "sha256:abc"}, events[8].Result)
+}
+
+func TestJSONDisplaySharedVertex(t *testing.T) {
+	buf := &bytes.Buffer{}
+	p := &Printer{}
+	d := newJSONDisplay(buf, p.vertexTargets)
+	app := WithTarget(p, "app", "builder0")
+	docs := WithTarget(p, "docs", "builder0")
+	p.status = make(chan *client.SolveStatus)
+
+	run := make(chan struct{})
+	go func() {
+		defer close(run)
+		_, err := d.UpdateFrom(context.TODO(), p.status)
+		require.NoError(t, err)
+	}()
+
+	// the base stage is solved once for both targets
+	dgst := digest.FromString("base")
+	started := time.Now()
+	completed := started.Add(time.Second)
+	vtx := func() *client.SolveStatus {
+		return &client.SolveStatus{
+			Vertexes: []*client.Vertex{
+				{Digest: dgst, Name: "[base 1/1] RUN make", Started: &started, Completed: &completed},
+			},
+		}
+	}
+	app.Write(vtx())
+	docs.Write(vtx())
+	close(p.status)
+	<-run
+
+	var events []JSONEvent
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var ev JSONEvent
+		require.NoError(t, dec.Decode(&ev))
+		events = append(events, ev)
+	}
+	require.Len(t, events, 4)
+	require.Equal(t, JSONEventVertexStarted, events[0].Type)
+	require.Equal(t, "app", events[0].Target)
+	require.Equal(t, JSONEventVertexCompleted, events[1].Type)
+	require.Equal(t, "app", events[1].Target)
+	require.Equal(t, JSONEventVertexStarted, events[2].Type)
+	require.Equal(t, "docs", events[2].Target)
+	require.Equal(t, JSONEventVertexCompleted, events[3].Type)
+	require.Equal(t, "docs", events[3].Target)
+}
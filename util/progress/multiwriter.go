@@ -4,6 +4,7 @@ import (
 	"strings"
 
 	"github.com/moby/buildkit/client"
+	"github.com/opencontainers/go-digest"
 )
 
 func WithPrefix(w Writer, pfx string, force bool) Writer {
@@ -32,9 +33,56 @@ func (p *prefixed) Write(v *client.SolveStatus) {
 	p.Writer.Write(v)
 }
 
+func (p *prefixed) WriteTarget(dgst digest.Digest, target, node string) {
+	WriteTarget(p.Writer, dgst, target, node)
+}
+
 func addPrefix(pfx, name string) string {
 	if strings.HasPrefix(name, "[") {
 		return "[" + pfx + " " + name[1:]
 	}
 	return "[" + pfx + "] " + name
 }
+
+// WithTarget returns a writer that associates the vertexes written to it with
+// the build of target on node. The association is reported by displays that
+// support it, such as JSONMode.
+func WithTarget(w Writer, target, node string) Writer {
+	return &targeted{
+		Writer: w,
+		target: target,
+		node:   node,
+	}
+}
+
+type targeted struct {
+	Writer
+	target string
+	node   string
+}
+
+func (t *targeted) Write(v *client.SolveStatus) {
+	for _, vtx := range v.Vertexes {
+		WriteTarget(t.Writer, vtx.Digest, t.target, t.node)
+	}
+	t.Writer.Write(v)
+}
+
+func (t *targeted) WriteTarget(dgst digest.Digest, target, node string) {
+	WriteTarget(t.Writer, dgst, target, node)
+}
+
+// TargetWriter is implemented by the writers recording the target and the
+// node of the vertexes written with WithTarget. Writers wrapping another
+// writer implement it to pass the targets to the writer they wrap.
+type TargetWriter interface {
+	WriteTarget(dgst digest.Digest, target, node string)
+}
+
+// WriteTarget associates the vertex dgst with the build of target on node
+// if w implements TargetWriter.
+func WriteTarget(w Writer, dgst digest.Digest, target, node string) {
+	if tw, ok := w.(TargetWriter); ok {
+		tw.WriteTarget(dgst, target, node)
+	}
+}
@@ -0,0 +1,19 @@
+package progress
+
+import (
+	"testing"
+
+	"github.com/moby/buildkit/client"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTargetWrapped(t *testing.T) {
+	p := &Printer{status: make(chan *client.SolveStatus, 1)}
+	var w Writer = ResetTime(Tee(WithPrefix(p, "app", true), make(chan *client.SolveStatus, 1)))
+	w = WithPrefix(WithTarget(w, "app", "builder0"), "app", false)
+
+	dgst := digest.FromString("run")
+	w.Write(&client.SolveStatus{Vertexes: []*client.Vertex{{Digest: dgst, Name: "RUN make"}}})
+	require.Equal(t, []vertexTarget{{target: "app", node: "builder0"}}, p.vertexTargets(dgst))
+}
@@ -3,6 +3,7 @@ package progress
 import (
 	"context"
 	"os"
+	"slices"
 	"sync"
 
 	"github.com/containerd/console"
@@ -29,11 +30,25 @@ type Printer struct {
 	logMu        sync.Mutex
 	logSourceMap map[digest.Digest]interface{}
 	metrics      *metricWriter
+	summary      *summaryWriter
 
 	// TODO: remove once we can use result context to pass build ref
 	//  see https://github.com/docker/buildx/pull/1861
 	buildRefsMu sync.Mutex
 	buildRefs   map[string]string
+
+	targetsMu sync.Mutex
+	targets   map[digest.Digest][]vertexTarget
+
+	// displayMu guards the displays, that are replaced on Unpause
+	displayMu sync.Mutex
+	json      *jsonDisplay
+	ci        *ciDisplay
+	mode      progressui.DisplayMode
+}
+
+type display interface {
+	UpdateFrom(ctx context.Context, ch chan *client.SolveStatus) ([]client.VertexWarning, error)
 }
 
 func (p *Printer) Wait() error {
@@ -68,6 +83,9 @@ func (p *Printer) Write(s *client.SolveStatus) {
 	if p.metrics != nil {
 		p.metrics.Write(s)
 	}
+	if p.summary != nil {
+		p.summary.Write(s)
+	}
 }
 
 func (p *Printer) Warnings() []client.VertexWarning {
@@ -109,15 +127,18 @@ func NewPrinter(ctx context.Context, out console.File, mode progressui.DisplayMo
 		mode = progressui.DisplayMode(v)
 	}
 
-	d, err := progressui.NewDisplay(out, mode, opt.displayOpts...)
-	if err != nil {
-		return nil, err
-	}
-
 	pw := &Printer{
 		ready:   make(chan struct{}),
 		metrics: opt.mw,
 	}
+	if opt.summary {
+		pw.summary = newSummaryWriter()
+	}
+
+	d, err := pw.newDisplay(out, mode, opt.displayOpts...)
+	if err != nil {
+		return nil, err
+	}
 	go func() {
 		for {
 			pw.status = make(chan *client.SolveStatus)
@@ -145,13 +166,96 @@ func NewPrinter(ctx context.Context, out console.File, mode progressui.DisplayMo
 			<-pw.paused
 			pw.paused = nil
 
-			d, _ = progressui.NewDisplay(out, mode, opt.displayOpts...)
+			d, _ = pw.newDisplay(out, mode, opt.displayOpts...)
 		}
 	}()
 	<-pw.ready
 	return pw, nil
 }
 
+// DisplayMode returns the mode of the display. CIMode falls back to
+// progressui.PlainMode when no CI provider is detected.
+func (p *Printer) DisplayMode() progressui.DisplayMode {
+	p.displayMu.Lock()
+	defer p.displayMu.Unlock()
+	return p.mode
+}
+
+func (p *Printer) newDisplay(out console.File, mode progressui.DisplayMode, opts ...progressui.DisplayOpt) (display, error) {
+	p.displayMu.Lock()
+	defer p.displayMu.Unlock()
+	p.json, p.ci = nil, nil
+	switch mode {
+	case JSONMode:
+		p.mode = mode
+		p.json = newJSONDisplay(out, p.vertexTargets)
+		return p.json, nil
+	case CIMode:
+		if ci := detectCIProvider(); ci != nil {
+			p.mode = mode
+			p.ci = newCIDisplay(out, ci)
+			return p.ci, nil
+		}
+		mode = progressui.PlainMode
+	}
+	p.mode = mode
+	return progressui.NewDisplay(out, mode, opts...)
+}
+
+// WriteResult reports the exporter response of a build target. It is only
+// printed in JSONMode.
+func (p *Printer) WriteResult(target string, resp map[string]string) {
+	p.displayMu.Lock()
+	json := p.json
+	p.displayMu.Unlock()
+	if json != nil {
+		json.writeResult(target, resp)
+	}
+}
+
+// WriteError reports the error of a build. It is only printed in CIMode, as
+// an annotation pointing at the source of the error. It must be called after
+// Wait, so that the error is printed after the last steps of the build.
+func (p *Printer) WriteError(err error) {
+	p.displayMu.Lock()
+	ci := p.ci
+	p.displayMu.Unlock()
+	if ci != nil && err != nil {
+		ci.writeError(err)
+	}
+}
+
+// Summary returns the timings and the cache statistics of the build. It is
+// only available when the printer is created with WithSummary.
+func (p *Printer) Summary() *Summary {
+	if p.summary == nil {
+		return nil
+	}
+	return p.summary.summary(p.vertexTargets)
+}
+
+// WriteTarget records that the vertex dgst is part of the build of target on
+// node.
+func (p *Printer) WriteTarget(dgst digest.Digest, target, node string) {
+	p.targetsMu.Lock()
+	defer p.targetsMu.Unlock()
+	if p.targets == nil {
+		p.targets = map[digest.Digest][]vertexTarget{}
+	}
+	t := vertexTarget{target: target, node: node}
+	if !slices.Contains(p.targets[dgst], t) {
+		p.targets[dgst] = append(p.targets[dgst], t)
+	}
+}
+
+// vertexTargets returns the targets a vertex is part of, in the order they
+// reported it. A vertex shared by several targets is part of each of them.
+func (p *Printer) vertexTargets(dgst digest.Digest) []vertexTarget {
+	p.targetsMu.Lock()
+	defer p.targetsMu.Unlock()
+	return slices.Clone(p.targets[dgst])
+}
+
 func (p *Printer) WriteBuildRef(target string, ref string) {
 	p.buildRefsMu.Lock()
 	defer p.buildRefsMu.Unlock()
@@ -168,6 +272,7 @@ func (p *Printer) BuildRefs() map[string]string {
 type printerOpts struct {
 	displayOpts []progressui.DisplayOpt
 	mw          *metricWriter
+	summary     bool
 
 	onclose func()
 }
@@ -192,6 +297,13 @@ func WithMetrics(mp metric.MeterProvider, attrs attribute.Set) PrinterOpt {
 	}
 }
 
+// WithSummary records the progress of the build to produce a Summary.
+func WithSummary() PrinterOpt {
+	return func(opt *printerOpts) {
+		opt.summary = true
+	}
+}
+
 func WithOnClose(onclose func()) PrinterOpt {
 	return func(opt *printerOpts) {
 		opt.onclose = onclose
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/moby/buildkit/client"
+	"github.com/opencontainers/go-digest"
 )
 
 func ResetTime(in Writer) Writer {
@@ -73,6 +74,10 @@ type pw struct {
 	status chan *client.SolveStatus
 }
 
+func (w *pw) WriteTarget(dgst digest.Digest, target, node string) {
+	WriteTarget(w.Writer, dgst, target, node)
+}
+
 func (w *pw) Status() chan *client.SolveStatus {
 	return w.status
 }
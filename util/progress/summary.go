@@ -0,0 +1,293 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moby/buildkit/client"
+	"github.com/opencontainers/go-digest"
+)
+
+// maxSummarySteps is the number of slowest steps reported in a Summary.
+const maxSummarySteps = 10
+
+// Summary reports the timings and the cache usage of a build. Durations are
+// in seconds.
+type Summary struct {
+	Duration float64 `json:"duration"`
+	IdleTime float64 `json:"idleTime"`
+
+	Steps       int     `json:"steps"`
+	CachedSteps int     `json:"cachedSteps"`
+	CacheRatio  float64 `json:"cacheRatio"`
+
+	LocalSourceTransferBytes int64   `json:"localSourceTransferBytes"`
+	LocalSourceTransferTime  float64 `json:"localSourceTransferTime"`
+	ImagePullBytes           int64   `json:"imagePullBytes"`
+	ImagePullTime            float64 `json:"imagePullTime"`
+	ExecTime                 float64 `json:"execTime"`
+	ExportTime               float64 `json:"exportTime"`
+
+	Targets      []SummaryTarget `json:"targets,omitempty"`
+	SlowestSteps []SummaryStep   `json:"slowestSteps,omitempty"`
+}
+
+// SummaryTarget reports the cache usage of the steps of a build target.
+type SummaryTarget struct {
+	Name        string `json:"name"`
+	Steps       int    `json:"steps"`
+	CachedSteps int    `json:"cachedSteps"`
+	Errors      int    `json:"errors,omitempty"`
+}
+
+// SummaryStep reports a build step.
+type SummaryStep struct {
+	Name string `json:"name"`
+	// Target is the first target of the step, kept for the readers of the
+	// summaries without Targets.
+	Target   string   `json:"target,omitempty"`
+	Targets  []string `json:"targets,omitempty"`
+	Duration float64  `json:"duration"`
+	Cached   bool     `json:"cached,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+type summaryVertex struct {
+	name      string
+	started   *time.Time
+	completed *time.Time
+	cached    bool
+	err       string
+}
+
+// summaryWriter records the progress of a build to produce a Summary. It
+// detects the same kinds of steps as the OTEL metrics.
+type summaryWriter struct {
+	mu       sync.Mutex
+	vertexes map[digest.Digest]*summaryVertex
+	order    []digest.Digest
+
+	localSources   map[digest.Digest]int64
+	imageSources   map[digest.Digest]struct{}
+	imagePullBytes int64
+	imagePullTime  time.Duration
+}
+
+func newSummaryWriter() *summaryWriter {
+	return &summaryWriter{
+		vertexes:     map[digest.Digest]*summaryVertex{},
+		localSources: map[digest.Digest]int64{},
+		imageSources: map[digest.Digest]struct{}{},
+	}
+}
+
+func (sw *summaryWriter) Write(ss *client.SolveStatus) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	for _, v := range ss.Vertexes {
+		vtx, ok := sw.vertexes[v.Digest]
+		if !ok {
+			vtx = &summaryVertex{}
+			sw.vertexes[v.Digest] = vtx
+			sw.order = append(sw.order, v.Digest)
+			if detectLocalSourceType(v.Name).Valid() {
+				sw.localSources[v.Digest] = 0
+			}
+			if detectImageSourceType(v.Name) {
+				sw.imageSources[v.Digest] = struct{}{}
+			}
+		}
+		vtx.name = v.Name
+		if v.Started != nil {
+			vtx.started = v.Started
+		}
+		if v.Completed != nil {
+			vtx.completed = v.Completed
+		}
+		vtx.cached = v.Cached
+		vtx.err = v.Error
+	}
+
+	for _, status := range ss.Statuses {
+		if _, ok := sw.localSources[status.Vertex]; ok && strings.HasPrefix(status.Name, "transferring") {
+			if status.Current > sw.localSources[status.Vertex] {
+				sw.localSources[status.Vertex] = status.Current
+			}
+			continue
+		}
+		if _, ok := sw.imageSources[status.Vertex]; ok && status.Completed != nil && status.Started != nil && strings.HasPrefix(status.ID, "sha256:") {
+			sw.imagePullBytes += status.Total
+			sw.imagePullTime += status.Completed.Sub(*status.Started)
+		}
+	}
+}
+
+func (sw *summaryWriter) summary(targetOf func(digest.Digest) []vertexTarget) *Summary {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	s := &Summary{}
+	var first, last time.Time
+	var started, completed []time.Time
+	var steps []SummaryStep
+	targets := map[string]*SummaryTarget{}
+
+	for _, dgst := range sw.order {
+		vtx := sw.vertexes[dgst]
+		if vtx.started == nil || vtx.completed == nil {
+			continue
+		}
+		if first.IsZero() || vtx.started.Before(first) {
+			first = *vtx.started
+		}
+		if vtx.completed.After(last) {
+			last = *vtx.completed
+		}
+		started = append(started, *vtx.started)
+		completed = append(completed, *vtx.completed)
+
+		dur := vtx.completed.Sub(*vtx.started).Seconds()
+		switch {
+		case detectExecType(vtx.name):
+			s.ExecTime += dur
+		case detectExportImageType(vtx.name) != "":
+			s.ExportTime += dur
+		}
+		if _, ok := sw.localSources[dgst]; ok {
+			s.LocalSourceTransferTime += dur
+		}
+
+		step := SummaryStep{
+			Name:     vtx.name,
+			Duration: dur,
+			Cached:   vtx.cached,
+			Error:    vtx.err,
+		}
+		if targetOf != nil {
+			// a step shared by several targets counts for each of them
+			for _, t := range targetOf(dgst) {
+				if !slices.Contains(step.Targets, t.target) {
+					step.Targets = append(step.Targets, t.target)
+				}
+			}
+			if len(step.Targets) > 0 {
+				step.Target = step.Targets[0]
+			}
+		}
+		steps = append(steps, step)
+
+		s.Steps++
+		if vtx.cached {
+			s.CachedSteps++
+		}
+		for _, name := range step.Targets {
+			t, ok := targets[name]
+			if !ok {
+				t = &SummaryTarget{Name: name}
+				targets[name] = t
+			}
+			t.Steps++
+			if vtx.cached {
+				t.CachedSteps++
+			}
+			if vtx.err != "" {
+				t.Errors++
+			}
+		}
+	}
+
+	s.Duration = last.Sub(first).Seconds()
+	s.IdleTime = calculateIdleTime(started, completed).Seconds()
+	if s.Steps > 0 {
+		s.CacheRatio = float64(s.CachedSteps) / float64(s.Steps)
+	}
+	for _, n := range sw.localSources {
+		s.LocalSourceTransferBytes += n
+	}
+	s.ImagePullBytes = sw.imagePullBytes
+	s.ImagePullTime = sw.imagePullTime.Seconds()
+
+	for _, t := range targets {
+		s.Targets = append(s.Targets, *t)
+	}
+	sort.Slice(s.Targets, func(i, j int) bool {
+		return s.Targets[i].Name < s.Targets[j].Name
+	})
+
+	sort.SliceStable(steps, func(i, j int) bool {
+		return steps[i].Duration > steps[j].Duration
+	})
+	if len(steps) > maxSummarySteps {
+		steps = steps[:maxSummarySteps]
+	}
+	s.SlowestSteps = steps
+	return s
+}
+
+// WriteMarkdown writes the summary as a Markdown document, for example to be
+// used as the summary of a CI job.
+func (s *Summary) WriteMarkdown(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("## Build summary\n\n")
+	sb.WriteString("| Metric | Value |\n|:--|--:|\n")
+	fmt.Fprintf(&sb, "| Duration | %s |\n", formatSeconds(s.Duration))
+	fmt.Fprintf(&sb, "| Idle time | %s |\n", formatSeconds(s.IdleTime))
+	fmt.Fprintf(&sb, "| Steps | %d |\n", s.Steps)
+	fmt.Fprintf(&sb, "| Cached steps | %d (%.0f%%) |\n", s.CachedSteps, s.CacheRatio*100)
+	fmt.Fprintf(&sb, "| Local context transfer | %s in %s |\n", formatBytes(s.LocalSourceTransferBytes), formatSeconds(s.LocalSourceTransferTime))
+	fmt.Fprintf(&sb, "| Image pull | %s in %s |\n", formatBytes(s.ImagePullBytes), formatSeconds(s.ImagePullTime))
+	fmt.Fprintf(&sb, "| RUN steps | %s |\n", formatSeconds(s.ExecTime))
+	fmt.Fprintf(&sb, "| Image export | %s |\n", formatSeconds(s.ExportTime))
+
+	if len(s.Targets) > 0 {
+		sb.WriteString("\n### Targets\n\n")
+		sb.WriteString("| Target | Steps | Cached | Errors |\n|:--|--:|--:|--:|\n")
+		for _, t := range s.Targets {
+			fmt.Fprintf(&sb, "| %s | %d | %d | %d |\n", escapeMarkdownCell(t.Name), t.Steps, t.CachedSteps, t.Errors)
+		}
+	}
+
+	if len(s.SlowestSteps) > 0 {
+		sb.WriteString("\n### Slowest steps\n\n")
+		sb.WriteString("| Step | Duration | Status |\n|:--|--:|:--|\n")
+		for _, st := range s.SlowestSteps {
+			status := "done"
+			switch {
+			case st.Error != "":
+				status = "error"
+			case st.Cached:
+				status = "cached"
+			}
+			fmt.Fprintf(&sb, "| `%s` | %s | %s |\n", escapeMarkdownCell(st.Name), formatSeconds(st.Duration), status)
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func formatSeconds(s float64) string {
+	return fmt.Sprintf("%.1fs", s)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func escapeMarkdownCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
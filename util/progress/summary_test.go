@@ -0,0 +1,99 @@
+package progress
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/moby/buildkit/client"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummary(t *testing.T) {
+	p := &Printer{summary: newSummaryWriter()}
+	p.status = make(chan *client.SolveStatus, 10)
+	w := WithTarget(p, "app", "builder0")
+
+	ctx := digest.FromString("context")
+	from := digest.FromString("from")
+	run := digest.FromString("run")
+	export := digest.FromString("export")
+
+	t0 := time.Now()
+	at := func(d time.Duration) *time.Time {
+		t := t0.Add(d)
+		return &t
+	}
+
+	w.Write(&client.SolveStatus{
+		Vertexes: []*client.Vertex{
+			{Digest: ctx, Name: "[internal] load build context", Started: at(0), Completed: at(time.Second)},
+			{Digest: from, Name: "[1/3] FROM docker.io/library/alpine:latest", Started: at(0), Completed: at(2 * time.Second)},
+		},
+		Statuses: []*client.VertexStatus{
+			{Vertex: ctx, ID: "transferring context:", Name: "transferring context:", Current: 1024},
+			{Vertex: ctx, ID: "transferring context:", Name: "transferring context:", Current: 2048},
+			{Vertex: from, ID: "sha256:abc", Total: 4096, Started: at(0), Completed: at(2 * time.Second)},
+		},
+	})
+	w.Write(&client.SolveStatus{
+		Vertexes: []*client.Vertex{
+			{Digest: run, Name: "[2/3] RUN make", Started: at(3 * time.Second), Completed: at(8 * time.Second)},
+			{Digest: export, Name: "exporting to image", Started: at(8 * time.Second), Completed: at(9 * time.Second), Cached: true},
+		},
+	})
+
+	s := p.Summary()
+	require.Equal(t, 4, s.Steps)
+	require.Equal(t, 1, s.CachedSteps)
+	require.Equal(t, 0.25, s.CacheRatio)
+	require.Equal(t, 9.0, s.Duration)
+	require.Equal(t, 1.0, s.IdleTime)
+	require.Equal(t, int64(2048), s.LocalSourceTransferBytes)
+	require.Equal(t, 1.0, s.LocalSourceTransferTime)
+	require.Equal(t, int64(4096), s.ImagePullBytes)
+	require.Equal(t, 2.0, s.ImagePullTime)
+	require.Equal(t, 5.0, s.ExecTime)
+	require.Equal(t, 1.0, s.ExportTime)
+	require.Equal(t, []SummaryTarget{{Name: "app", Steps: 4, CachedSteps: 1}}, s.Targets)
+
+	require.Len(t, s.SlowestSteps, 4)
+	require.Equal(t, "[2/3] RUN make", s.SlowestSteps[0].Name)
+	require.Equal(t, "app", s.SlowestSteps[0].Target)
+	require.Equal(t, []string{"app"}, s.SlowestSteps[0].Targets)
+	require.Equal(t, 5.0, s.SlowestSteps[0].Duration)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, s.WriteMarkdown(buf))
+	require.Contains(t, buf.String(), "| Cached steps | 1 (25%) |\n")
+	require.Contains(t, buf.String(), "| Local context transfer | 2.0KiB in 1.0s |\n")
+	require.Contains(t, buf.String(), "| app | 4 | 1 | 0 |\n")
+	require.Contains(t, buf.String(), "| `[2/3] RUN make` | 5.0s | done |\n")
+}
+
+func TestSummarySharedStep(t *testing.T) {
+	p := &Printer{summary: newSummaryWriter()}
+	p.status = make(chan *client.SolveStatus, 10)
+
+	base := digest.FromString("base")
+	docs := digest.FromString("docs")
+	t0 := time.Now()
+	t1 := t0.Add(time.Second)
+
+	vtx := &client.Vertex{Digest: base, Name: "[base 1/1] RUN make", Started: &t0, Completed: &t1}
+	WithTarget(p, "app", "builder0").Write(&client.SolveStatus{Vertexes: []*client.Vertex{vtx}})
+	WithTarget(p, "docs", "builder0").Write(&client.SolveStatus{Vertexes: []*client.Vertex{
+		vtx,
+		{Digest: docs, Name: "[docs 1/1] RUN mkdocs", Started: &t1, Completed: &t1, Cached: true},
+	}})
+
+	s := p.Summary()
+	require.Equal(t, 2, s.Steps)
+	require.Equal(t, []SummaryTarget{
+		{Name: "app", Steps: 1},
+		{Name: "docs", Steps: 2, CachedSteps: 1},
+	}, s.Targets)
+	require.Equal(t, "app", s.SlowestSteps[0].Target)
+	require.Equal(t, []string{"app", "docs"}, s.SlowestSteps[0].Targets)
+}
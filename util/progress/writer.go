@@ -87,6 +87,10 @@ func (t *tee) Write(v *client.SolveStatus) {
 	t.Writer.Write(v)
 }
 
+func (t *tee) WriteTarget(dgst digest.Digest, target, node string) {
+	WriteTarget(t.Writer, dgst, target, node)
+}
+
 func Tee(w Writer, ch chan *client.SolveStatus) Writer {
 	if ch == nil {
 		return w